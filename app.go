@@ -2,16 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fm-opencode-tinyapp/internal/api"
 	"fm-opencode-tinyapp/internal/models"
 	"fm-opencode-tinyapp/internal/services"
 	"fmt"
 	"log"
-	"net"
-	"net/url"
 	"os"
-	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -20,22 +22,88 @@ import (
 
 // App struct holds the application's state and services.
 type App struct {
-	ctx              context.Context
-	sessionService   *services.SessionService
-	messageService   *services.MessageService
-	configService    *services.ConfigService
-	fileService      *services.FileService
-	appConfigService *services.AppConfigService
-	streamClient     *api.StreamClient
-	llmClient        *api.LLMClient
-	logger           *logrus.Logger
-	eventEmitter     func(event *models.Event)
-	opencodeProcess  *exec.Cmd
+	ctx                context.Context
+	sessionService     *services.SessionService
+	messageService     *services.MessageService
+	configService      *services.ConfigService
+	fileService        *services.FileService
+	appConfigService   *services.AppConfigService
+	exportService      *services.ExportService
+	importService      *services.ImportService
+	searchService      *services.SearchService
+	usageService       *services.UsageService
+	budgetService      *services.BudgetService
+	compactionService  *services.CompactionService
+	permissionPolicy   *services.PermissionPolicyService
+	permissionAudit    *services.PermissionAuditService
+	hookService        *services.HookService
+	translationService *services.TranslationService
+	secretService      *services.SecretService
+	polishMu           sync.Mutex
+	polishCalls        map[string]*polishCall
+	sendMu             sync.Mutex
+	pendingSends       map[string]*pendingSend // By ID of the sent user message
+	apiClient          *api.Client
+	streamClient       *api.StreamClient
+	llmMu              sync.RWMutex
+	llmClient          *api.LLMClient
+	configMu           sync.Mutex
+	currentConfig      *models.AppConfig // Last applied config
+	logger             *logrus.Logger
+	eventEmitter       func(event *models.Event)
+	projectService     *services.ProjectService
+	serverMu           sync.Mutex
+	server             *serverInstance            // Instance the clients are connected to
+	servers            map[string]*serverInstance // By project ID; "" is the config's server URL
+	activeProject      *models.Project
+	configPath         string                  // --config, empty for the default location
+	configOverrides    []models.ConfigOverride // From flags and the environment
+}
+
+func containsSection(sections []string, section string) bool {
+	for _, s := range sections {
+		if s == section {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// configWatchInterval is how often config.json is checked for changes.
+	configWatchInterval = 2 * time.Second
+	// diagnosticsLogLines is the number of server output lines in a diagnostics bundle.
+	diagnosticsLogLines = 200
+	// eventActionTimeout bounds the server calls made in response to an event.
+	eventActionTimeout = 30 * time.Second
+)
+
+// serverInstance is a supervised opencode server and its output log.
+type serverInstance struct {
+	supervisor *services.ServerSupervisor
+	log        *services.ServerLog
+}
+
+// pendingSend is an asynchronous send waiting for the reply to its message.
+type pendingSend struct {
+	sessionID  string
+	startedAt  int64  // Unix milliseconds
+	answered   bool   // A reply to the message has finished
+	replyError string // Error of the finished reply, if any
+}
+
+// polishCall is a running PolishTextStream call that can be cancelled.
+type polishCall struct {
+	cancel context.CancelFunc
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
-	return &App{}
+	return &App{
+		polishCalls:  make(map[string]*polishCall),
+		pendingSends: make(map[string]*pendingSend),
+		servers:      make(map[string]*serverInstance),
+	}
 }
 
 // startup is called when the app starts. The context is saved
@@ -48,7 +116,7 @@ func (a *App) startup(ctx context.Context) {
 	a.logger.SetLevel(logrus.InfoLevel)
 
 	// Initialize the app config service first
-	appConfigService, err := services.NewAppConfigService()
+	appConfigService, err := services.OpenAppConfigService(a.configPath, a.configOverrides)
 	if err != nil {
 		log.Fatalf("Failed to initialize app config service: %v", err)
 	}
@@ -60,31 +128,118 @@ func (a *App) startup(ctx context.Context) {
 		log.Fatalf("Failed to load app config: %v", err)
 	}
 
-	if err := a.ensureOpenCodeServer(appConfig.ServerURL); err != nil {
-		a.logger.Warnf("failed to ensure opencode server: %v", err)
+	// Move plain secrets out of config.json
+	secretService, err := services.NewSecretService(a.logger)
+	if err != nil {
+		log.Fatalf("Failed to initialize secret store: %v", err)
+	}
+	a.secretService = secretService
+	if changed, err := a.secretService.Protect(appConfig, nil); err != nil {
+		a.logger.Warnf("failed to protect secrets: %v", err)
+	} else if changed {
+		if err := a.appConfigService.UpdateAppConfig(appConfig); err != nil {
+			a.logger.Warnf("failed to save config: %v", err)
+		}
 	}
 
+	projectService, err := services.NewProjectService()
+	if err != nil {
+		log.Fatalf("Failed to initialize project registry: %v", err)
+	}
+	a.projectService = projectService
+
 	// Initialize API client with the loaded URL
 	apiClient := api.NewClient(appConfig.ServerURL)
+	a.apiClient = apiClient
 	a.streamClient = api.NewStreamClient(appConfig.ServerURL, a.logger)
 
 	// Initialize LLM client
-	if appConfig.LLM.BaseURL != "" && appConfig.LLM.APIKey != "" {
-		a.llmClient = api.NewLLMClient(appConfig.LLM.BaseURL, appConfig.LLM.APIKey)
-	}
+	a.llmClient = a.newLLMClient(appConfig.LLM)
 
 	a.sessionService = services.NewSessionService(apiClient)
 	a.messageService = services.NewMessageService(apiClient)
 	a.configService = services.NewConfigService(apiClient)
 	a.fileService = services.NewFileService(apiClient)
+	a.exportService = services.NewExportService(apiClient)
+	a.importService = services.NewImportService(apiClient)
+	a.searchService = services.NewSearchService(apiClient)
+	a.usageService = services.NewUsageService(apiClient)
+	a.budgetService = services.NewBudgetService(apiClient)
+	a.budgetService.SetBudget(appConfig.Budget)
+	compactionService, err := services.NewCompactionService(apiClient)
+	if err != nil {
+		log.Fatalf("Failed to initialize compaction history: %v", err)
+	}
+	a.compactionService = compactionService
+	a.compactionService.SetConfig(appConfig.Compaction)
+	a.permissionPolicy = services.NewPermissionPolicyService(apiClient)
+	a.permissionPolicy.SetPolicy(appConfig.Permission)
+	permissionAudit, err := services.NewPermissionAuditService()
+	if err != nil {
+		log.Fatalf("Failed to initialize permission audit log: %v", err)
+	}
+	a.permissionAudit = permissionAudit
+	a.hookService = services.NewHookService()
+	a.hookService.SetConfig(appConfig.Hooks)
+	translationService, err := services.NewTranslationService()
+	if err != nil {
+		log.Fatalf("Failed to initialize translation store: %v", err)
+	}
+	a.translationService = translationService
+
+	// Start the server of the last active project, or the one at the config's URL
+	a.currentConfig = appConfig
+	project, err := a.projectService.Active()
+	if err != nil {
+		a.logger.Warnf("failed to load projects: %v", err)
+	}
+	if project != nil {
+		err = a.activateProject(project, false)
+	} else {
+		err = a.activateDefaultServer(false)
+	}
+	if err != nil {
+		a.logger.Warnf("failed to start opencode server: %v", err)
+	}
+
 	a.eventEmitter = func(event *models.Event) {
 		runtime.EventsEmit(a.ctx, "server-event", event)
 	}
 
+	// Pick up edits to config.json made outside the app
+	go a.appConfigService.Watch(a.ctx, configWatchInterval, a.onConfigFileChanged, func(err error) {
+		a.logger.Warnf("ignoring changed config file: %v", err)
+	})
+}
+
+// getLLMClient returns the current LLM client, or nil if the LLM is not configured.
+func (a *App) getLLMClient() *api.LLMClient {
+	a.llmMu.RLock()
+	defer a.llmMu.RUnlock()
+	return a.llmClient
+}
+
+// newLLMClient creates an LLM client with the API key resolved from the
+// secret store, or returns nil if the LLM is not configured.
+func (a *App) newLLMClient(config models.LLMConfig) *api.LLMClient {
+	resolved, err := a.secretService.ResolveLLM(config)
+	if err != nil {
+		a.logger.Warnf("failed to resolve LLM API key: %v", err)
+		return nil
+	}
+	if !resolved.Configured() {
+		return nil
+	}
+	return api.NewLLMClient(resolved)
 }
 
 func (a *App) startEventForwardingAsync() {
-	go a.startEventForwarding()
+	go func() {
+		if err := a.activeServer().supervisor.WaitReady(a.ctx); err != nil {
+			a.logger.Warnf("%v; subscribing to events anyway", err)
+		}
+		a.startEventForwarding()
+	}()
 }
 
 func (a *App) startupWails(ctx context.Context) {
@@ -98,6 +253,18 @@ func (a *App) startEventForwarding() {
 		a.logger.Fatalf("Failed to subscribe to events: %v", err)
 	}
 
+	// Build the search index in the background; events keep it up to date afterwards.
+	go func() {
+		if err := a.searchService.BuildIndex(a.ctx); err != nil {
+			a.logger.Warnf("failed to build search index: %v", err)
+		}
+	}()
+	go func() {
+		if err := a.budgetService.Seed(a.ctx); err != nil {
+			a.logger.Warnf("failed to load usage for budgets: %v", err)
+		}
+	}()
+
 	for {
 		select {
 		case <-a.ctx.Done():
@@ -112,6 +279,14 @@ func (a *App) startEventForwarding() {
 			a.logger.Infof("Forwarding event: Type=%s, Properties=%+v",
 				event.Type, event.Properties)
 
+			a.searchService.HandleEvent(event)
+			a.enforceBudget(event)
+			a.autoCompact(event)
+			a.permissionAudit.HandleEvent(event)
+			a.autoRespondPermission(event)
+			a.hookService.HandleEvent(event, a.hookDir())
+			a.trackAsyncSend(event)
+
 			// Forward the original event to the frontend
 			if a.eventEmitter != nil {
 				a.eventEmitter(event)
@@ -120,43 +295,117 @@ func (a *App) startEventForwarding() {
 	}
 }
 
-func (a *App) ensureOpenCodeServer(serverURL string) error {
-	parsedURL, err := url.Parse(serverURL)
-	if err != nil {
-		return fmt.Errorf("invalid server URL %q: %w", serverURL, err)
+// hookDir returns the working directory of event hooks: the active project's
+// directory, or the configured server directory.
+func (a *App) hookDir() string {
+	if project, _ := a.GetActiveProject(); project != nil {
+		return project.Path
 	}
-
-	if parsedURL.Host == "" {
-		return fmt.Errorf("server URL %q has no host", serverURL)
+	a.configMu.Lock()
+	defer a.configMu.Unlock()
+	if a.currentConfig == nil {
+		return ""
 	}
+	return a.currentConfig.Server.Dir
+}
 
-	if isTCPPortOpen(parsedURL.Host, 500*time.Millisecond) {
-		return nil
+// enforceBudget stops the session of a message.updated event when it exceeds
+// the configured budget and notifies the frontend with a "budget.exceeded" event.
+func (a *App) enforceBudget(event *models.Event) {
+	msg := a.budgetService.Track(event)
+	if msg == nil {
+		return
 	}
 
-	cwd, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get current directory: %w", err)
+	// Loading the session usage and stopping the session call the server,
+	// so keep them off the event loop.
+	go func() {
+		ctx, cancel := context.WithTimeout(a.ctx, eventActionTimeout)
+		defer cancel()
+		exceeded, err := a.budgetService.Enforce(ctx, *msg)
+		if err != nil {
+			a.logger.Warnf("budget check failed: %v", err)
+		}
+		if exceeded != nil {
+			a.logger.Warnf("budget %s exceeded in session %s (%.4f > %.4f), session stopped",
+				exceeded.Kind, exceeded.SessionID, exceeded.Actual, exceeded.Limit)
+			a.emitAppEvent("budget.exceeded", exceeded)
+		}
+	}()
+}
+
+// autoCompact summarizes an idle session whose context usage is above the
+// configured threshold and notifies the frontend with a "session.compacted" event.
+func (a *App) autoCompact(event *models.Event) {
+	if event.Type != "session.idle" || !a.compactionService.Enabled() {
+		return
+	}
+	sessionID, _ := event.Properties["sessionID"].(string)
+	if sessionID == "" {
+		return
 	}
 
-	cmd := exec.Command("opencode", "serve", "--port", "23450")
-	cmd.Dir = cwd
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start opencode api process: %w", err)
+	// Summarizing blocks until the model finishes, so keep it off the event loop.
+	go func() {
+		tokens, err := a.GetSessionTokens(sessionID)
+		if err != nil {
+			a.logger.Warnf("failed to get session tokens for compaction: %v", err)
+			return
+		}
+		record, err := a.compactionService.MaybeCompact(a.ctx, sessionID, tokens)
+		if err != nil {
+			a.logger.Warnf("auto compaction failed: %v", err)
+		}
+		if record != nil {
+			a.logger.Infof("compacted session %s at %.1f%% context usage", sessionID, record.Percentage)
+			a.emitAppEvent("session.compacted", record)
+		}
+	}()
+}
+
+// autoRespondPermission answers a permission.updated event according to the
+// permission policy and notifies the frontend with a "permission.auto" event.
+func (a *App) autoRespondPermission(event *models.Event) {
+	if event.Type != "permission.updated" {
+		return
 	}
 
-	a.opencodeProcess = cmd
-	a.logger.Infof("started opencode api process (pid=%d) in %s", cmd.Process.Pid, cwd)
-	return nil
+	// Responding calls the server, so keep it off the event loop.
+	go func() {
+		ctx, cancel := context.WithTimeout(a.ctx, eventActionTimeout)
+		defer cancel()
+		decision, err := a.permissionPolicy.HandleEvent(ctx, event)
+		if err != nil {
+			a.logger.Warnf("permission auto-response failed: %v", err)
+		}
+		if decision == nil || decision.Action == models.PermissionAsk {
+			return
+		}
+		if err := a.permissionAudit.RecordDecision(decision); err != nil {
+			a.logger.Warnf("failed to write permission audit log: %v", err)
+		}
+		if decision.DryRun {
+			a.logger.Infof("[dry-run] permission %s (%s: %s) would be answered %q by rule %s",
+				decision.Request.ID, decision.Request.Type, decision.Request.Title, decision.Action, decision.Rule)
+		} else {
+			a.logger.Infof("permission %s (%s: %s) answered %q by rule %s",
+				decision.Request.ID, decision.Request.Type, decision.Request.Title, decision.Action, decision.Rule)
+		}
+		a.emitAppEvent("permission.auto", decision)
+	}()
 }
 
-func isTCPPortOpen(address string, timeout time.Duration) bool {
-	conn, err := net.DialTimeout("tcp", address, timeout)
-	if err != nil {
-		return false
+// emitAppEvent sends an app-originated event to the frontend through the same
+// channel as server events. payload is converted to the event properties.
+func (a *App) emitAppEvent(eventType string, payload interface{}) {
+	if a.eventEmitter == nil {
+		return
+	}
+	properties := map[string]interface{}{}
+	if data, err := json.Marshal(payload); err == nil {
+		_ = json.Unmarshal(data, &properties)
 	}
-	_ = conn.Close()
-	return true
+	a.eventEmitter(&models.Event{Type: eventType, Properties: properties})
 }
 
 // Shutdown is called when the app is shutting down.
@@ -165,84 +414,549 @@ func (a *App) shutdown(ctx context.Context) {
 	if a.streamClient != nil {
 		a.streamClient.Stop()
 	}
-	if a.opencodeProcess != nil && a.opencodeProcess.Process != nil {
-		if err := a.opencodeProcess.Process.Kill(); err != nil {
-			a.logger.Warnf("failed to stop opencode api process: %v", err)
+	a.serverMu.Lock()
+	instances := make([]*serverInstance, 0, len(a.servers))
+	for _, instance := range a.servers {
+		instances = append(instances, instance)
+	}
+	a.serverMu.Unlock()
+	for _, instance := range instances {
+		if err := instance.supervisor.Stop(); err != nil {
+			a.logger.Warnf("failed to stop opencode server: %v", err)
 		}
-		_, _ = a.opencodeProcess.Process.Wait()
-		a.opencodeProcess = nil
+		instance.log.Close()
 	}
 }
 
 // === アプリケーション設定関連 ===
 
-// GetAppConfig returns the local application configuration.
+// GetAppConfig returns the local application configuration with secrets masked.
 func (a *App) GetAppConfig() (*models.AppConfig, error) {
-	return a.appConfigService.GetAppConfig()
+	config, err := a.appConfigService.GetAppConfig()
+	if err != nil {
+		return nil, err
+	}
+	masked := config.Masked()
+	return &masked, nil
 }
 
-// UpdateAppConfig updates the local application configuration.
+// UpdateAppConfig replaces the local application configuration. Secrets are
+// moved to the secret store; masked values keep the stored secret.
 func (a *App) UpdateAppConfig(config *models.AppConfig) error {
-	return a.appConfigService.UpdateAppConfig(config)
+	if err := services.ValidateAppConfig(config); err != nil {
+		return err
+	}
+	previous, err := a.appConfigService.GetAppConfig()
+	if err != nil {
+		return fmt.Errorf("failed to get app config: %w", err)
+	}
+	if _, err := a.secretService.Protect(config, previous); err != nil {
+		return err
+	}
+	if err := a.appConfigService.UpdateAppConfig(config); err != nil {
+		return err
+	}
+	a.applyAppConfig(config, models.ConfigSourceAPI)
+	return nil
+}
+
+// PatchAppConfig changes only the fields present in patch, a JSON merge
+// patch of the config (e.g. {"serverURL": "...", "llm": {"model": "..."}}),
+// and saves the result like UpdateAppConfig. Other settings are kept.
+func (a *App) PatchAppConfig(patch map[string]interface{}) error {
+	config, err := a.appConfigService.MergeAppConfig(patch)
+	if err != nil {
+		return err
+	}
+	return a.UpdateAppConfig(config)
+}
+
+// onConfigFileChanged applies a config.json edited outside the app.
+func (a *App) onConfigFileChanged(config *models.AppConfig) {
+	a.logger.Infof("config file changed, reloading %s", a.appConfigService.Path())
+	a.configMu.Lock()
+	previous := a.currentConfig
+	a.configMu.Unlock()
+	if changed, err := a.secretService.Protect(config, previous); err != nil {
+		a.logger.Warnf("failed to protect secrets: %v", err)
+	} else if changed {
+		if err := a.appConfigService.UpdateAppConfig(config); err != nil {
+			a.logger.Warnf("failed to save config: %v", err)
+		}
+	}
+	a.applyAppConfig(config, models.ConfigSourceFile)
+}
+
+// applyAppConfig propagates a saved config to the LLM client, the server
+// connection and the services, then emits a "config.changed" event.
+func (a *App) applyAppConfig(config *models.AppConfig, source string) {
+	a.configMu.Lock()
+	defer a.configMu.Unlock()
+	previous := a.currentConfig
+	a.currentConfig = config
+
+	llmClient := a.newLLMClient(config.LLM)
+	a.llmMu.Lock()
+	a.llmClient = llmClient
+	a.llmMu.Unlock()
+
+	sections := models.ChangedConfigSections(previous, config)
+	a.serverMu.Lock()
+	project := a.activeProject
+	a.serverMu.Unlock()
+	if project != nil {
+		// The project's server does not use the config's server URL.
+		if containsSection(sections, "server") {
+			a.restartServer(a.serverFor(project.ID), project.ServerURL(), projectServerProcess(project, config.Server))
+		}
+	} else {
+		if containsSection(sections, "serverURL") || containsSection(sections, "server") {
+			a.restartServer(a.serverFor(""), config.ServerURL, config.Server)
+		}
+		if previous == nil || previous.ServerURL != config.ServerURL {
+			a.connectServer(config.ServerURL)
+		}
+	}
+
+	a.budgetService.SetBudget(config.Budget)
+	a.compactionService.SetConfig(config.Compaction)
+	a.permissionPolicy.SetPolicy(config.Permission)
+	a.hookService.SetConfig(config.Hooks)
+
+	a.emitAppEvent("config.changed", models.ConfigChange{
+		Source:   source,
+		Sections: sections,
+		Config:   config.Masked(),
+	})
+}
+
+// GetConfigOverrides returns the config fields overridden by command-line
+// flags or FMOC_* environment variables. Overridden fields are not saved.
+func (a *App) GetConfigOverrides() []models.ConfigOverride {
+	return a.appConfigService.Overrides()
+}
+
+// ValidateAppConfig returns the field errors of the given configuration
+// without saving it. An empty result means the config is valid.
+func (a *App) ValidateAppConfig(config *models.AppConfig) []models.ConfigFieldError {
+	var validationErr *models.ConfigValidationError
+	if err := services.ValidateAppConfig(config); errors.As(err, &validationErr) {
+		return validationErr.Errors
+	}
+	return []models.ConfigFieldError{}
+}
+
+// GetHookLog returns the most recent event hook runs. limit <= 0 returns all kept runs.
+func (a *App) GetHookLog(limit int) []models.HookRun {
+	return a.hookService.Log(limit)
 }
 
 // === サーバー設定関連 ===
 
 // GetConfig returns the server configuration.
 func (a *App) GetConfig() (*models.ServerConfig, error) {
-	return a.configService.GetConfig()
+	return a.configService.GetConfig(a.ctx)
 }
 
 // UpdateConfigModel updates the server default model configuration.
 func (a *App) UpdateConfigModel(model string) error {
-	return a.configService.UpdateConfigModel(model)
+	return a.configService.UpdateConfigModel(a.ctx, model)
 }
 
 // GetProviders returns the list of available providers and models.
 func (a *App) GetProviders() (*models.ProvidersResponse, error) {
-	return a.configService.GetProviders()
+	return a.configService.GetProviders(a.ctx)
 }
 
 // GetAgents returns the list of available agents.
 func (a *App) GetAgents() ([]models.Agent, error) {
-	return a.configService.GetAgents()
+	return a.configService.GetAgents(a.ctx)
+}
+
+// === サーバープロセス関連 ===
+
+// GetServerStatus returns the status of the managed opencode server of the
+// active project.
+func (a *App) GetServerStatus() models.ServerStatus {
+	return a.activeServer().supervisor.Status()
+}
+
+// GetServerLogs returns the last n output lines of the managed opencode
+// server, oldest first. n <= 0 returns all kept lines. New lines are also
+// emitted as "server-log" events.
+func (a *App) GetServerLogs(n int) []models.ServerLogLine {
+	return a.activeServer().log.Lines(n)
+}
+
+// StartServer starts the opencode server if the server URL is local and
+// nothing is listening there.
+func (a *App) StartServer() error {
+	return a.activeServer().supervisor.Start()
+}
+
+// StopServer stops the managed opencode server.
+func (a *App) StopServer() error {
+	return a.activeServer().supervisor.Stop()
+}
+
+// RestartServer restarts the managed opencode server.
+func (a *App) RestartServer() error {
+	return a.activeServer().supervisor.Restart()
+}
+
+// activeServer returns the server instance the clients are connected to.
+func (a *App) activeServer() *serverInstance {
+	a.serverMu.Lock()
+	defer a.serverMu.Unlock()
+	return a.server
+}
+
+// serverFor returns the server instance of a project, creating it on first
+// use. The empty ID is the server at the config's URL. Output and status
+// changes are emitted as events only while the instance is active.
+func (a *App) serverFor(projectID string) *serverInstance {
+	a.serverMu.Lock()
+	defer a.serverMu.Unlock()
+	if instance, ok := a.servers[projectID]; ok {
+		return instance
+	}
+	instance := &serverInstance{}
+	instance.log = services.NewServerLog(func(line models.ServerLogLine) {
+		if a.activeServer() == instance {
+			a.emitAppEvent("server-log", line)
+		}
+	})
+	instance.supervisor = services.NewServerSupervisor(a.logger, instance.log, func(status models.ServerStatus) {
+		if a.activeServer() == instance {
+			a.emitAppEvent("server.status", status)
+		}
+	})
+	a.servers[projectID] = instance
+	return instance
+}
+
+// restartServer applies new settings to a server instance and restarts it
+// in the background.
+func (a *App) restartServer(instance *serverInstance, serverURL string, config models.ServerProcess) {
+	instance.supervisor.Configure(serverURL, config)
+	go func() {
+		if err := instance.supervisor.Restart(); err != nil {
+			a.logger.Warnf("failed to restart opencode server: %v", err)
+		}
+	}()
+}
+
+// connectServer points the API and event stream clients at serverURL and,
+// once the server is ready, reloads the data the services keep from the
+// previous server.
+func (a *App) connectServer(serverURL string) {
+	a.logger.Infof("switching server to %s", serverURL)
+	a.apiClient.SetBaseURL(serverURL)
+	a.streamClient.SetBaseURL(serverURL)
+	// Usage tracked for budgets belongs to the previous server.
+	a.budgetService.Reset()
+	a.abandonAsyncSends()
+	go func() {
+		if err := a.activeServer().supervisor.WaitReady(a.ctx); err != nil {
+			a.logger.Warnf("%v; loading data anyway", err)
+		}
+		if err := a.searchService.BuildIndex(a.ctx); err != nil {
+			a.logger.Warnf("failed to build search index: %v", err)
+		}
+		if err := a.budgetService.Seed(a.ctx); err != nil {
+			a.logger.Warnf("failed to load usage for budgets: %v", err)
+		}
+	}()
+}
+
+// === プロジェクト関連 ===
+
+// AddProject registers a project directory. Adding a registered directory
+// returns the existing project.
+func (a *App) AddProject(path string) (*models.Project, error) {
+	return a.projectService.Add(path)
+}
+
+// RemoveProject unregisters a project and stops its server. Removing the
+// active project switches back to the server URL from the config.
+func (a *App) RemoveProject(id string) error {
+	if active, _ := a.GetActiveProject(); active != nil && active.ID == id {
+		if err := a.SwitchProject(""); err != nil {
+			return err
+		}
+	}
+	if err := a.projectService.Remove(id); err != nil {
+		return err
+	}
+
+	a.serverMu.Lock()
+	instance, ok := a.servers[id]
+	delete(a.servers, id)
+	a.serverMu.Unlock()
+	if ok {
+		if err := instance.supervisor.Stop(); err != nil {
+			return fmt.Errorf("failed to stop project server: %w", err)
+		}
+		instance.log.Close()
+	}
+	return nil
+}
+
+// GetProjects returns the registered projects, most recently opened first.
+func (a *App) GetProjects() ([]models.Project, error) {
+	return a.projectService.List()
+}
+
+// GetRecentProjects returns up to limit projects that have been opened, most
+// recent first. limit <= 0 returns all of them.
+func (a *App) GetRecentProjects(limit int) ([]models.Project, error) {
+	projects, err := a.projectService.List()
+	if err != nil {
+		return nil, err
+	}
+	recent := make([]models.Project, 0, len(projects))
+	for _, project := range projects {
+		if project.LastOpened == 0 || (limit > 0 && len(recent) >= limit) {
+			break
+		}
+		recent = append(recent, project)
+	}
+	return recent, nil
+}
+
+// GetActiveProject returns the active project, or nil if the server URL from
+// the config is used.
+func (a *App) GetActiveProject() (*models.Project, error) {
+	a.serverMu.Lock()
+	defer a.serverMu.Unlock()
+	if a.activeProject == nil {
+		return nil, nil
+	}
+	project := *a.activeProject
+	return &project, nil
+}
+
+// SwitchProject makes a project active, starting its server if necessary,
+// and connects the clients to it. Other projects' servers keep running. An
+// empty id switches back to the server URL from the config. A
+// "project.switched" event is emitted.
+func (a *App) SwitchProject(id string) error {
+	if id == "" {
+		if err := a.activateDefaultServer(true); err != nil {
+			return err
+		}
+	} else {
+		project, err := a.projectService.Get(id)
+		if err != nil {
+			return err
+		}
+		if err := a.activateProject(project, true); err != nil {
+			return err
+		}
+	}
+	// Only remember the project once its server is running, so that a failed
+	// switch is not repeated on the next start.
+	if _, err := a.projectService.SetActive(id); err != nil {
+		return fmt.Errorf("failed to save active project: %w", err)
+	}
+	return nil
+}
+
+// activateProject starts the server of a project on its port, allocating a
+// free one if it has none or the port was taken by another program, and
+// makes it the active instance. connect rewires the clients to it.
+func (a *App) activateProject(project *models.Project, connect bool) error {
+	a.configMu.Lock()
+	config := a.currentConfig.Server
+	a.configMu.Unlock()
+
+	instance := a.serverFor(project.ID)
+	if !instance.supervisor.Status().Managed && (project.Port == 0 || services.PortInUse(project.Port)) {
+		port, err := services.FreePort()
+		if err != nil {
+			return err
+		}
+		if err := a.projectService.SetPort(project.ID, port); err != nil {
+			return err
+		}
+		project.Port = port
+	}
+	instance.supervisor.Configure(project.ServerURL(), projectServerProcess(project, config))
+	if err := instance.supervisor.Start(); err != nil {
+		return err
+	}
+	a.setActiveServer(instance, project, project.ServerURL(), connect)
+	return nil
+}
+
+// activateDefaultServer makes the server at the config's URL the active
+// instance. connect rewires the clients to it.
+func (a *App) activateDefaultServer(connect bool) error {
+	a.configMu.Lock()
+	config := a.currentConfig
+	a.configMu.Unlock()
+
+	instance := a.serverFor("")
+	instance.supervisor.Configure(config.ServerURL, config.Server)
+	if err := instance.supervisor.Start(); err != nil {
+		return err
+	}
+	a.setActiveServer(instance, nil, config.ServerURL, connect)
+	return nil
+}
+
+func (a *App) setActiveServer(instance *serverInstance, project *models.Project, serverURL string, connect bool) {
+	a.serverMu.Lock()
+	a.server = instance
+	a.activeProject = project
+	a.serverMu.Unlock()
+
+	if connect {
+		a.connectServer(serverURL)
+	} else {
+		a.apiClient.SetBaseURL(serverURL)
+		a.streamClient.SetBaseURL(serverURL)
+	}
+	a.emitAppEvent("project.switched", models.ProjectSwitch{Project: project, ServerURL: serverURL})
+	a.emitAppEvent("server.status", instance.supervisor.Status())
+}
+
+// projectServerProcess returns the server settings for a project: the
+// process runs in the project directory and logs to a file of its own.
+func projectServerProcess(project *models.Project, config models.ServerProcess) models.ServerProcess {
+	config.Dir = project.Path
+	if config.LogFile != "" {
+		ext := filepath.Ext(config.LogFile)
+		config.LogFile = strings.TrimSuffix(config.LogFile, ext) + "-" + project.ID + ext
+	}
+	return config
+}
+
+// === 診断関連 ===
+
+// GetDiagnostics checks the connections the app depends on: the opencode
+// server and its /config endpoint, the event stream, the managed server
+// process and the LLM endpoint. The checks run concurrently and each takes
+// at most a few seconds.
+func (a *App) GetDiagnostics() *models.Diagnostics {
+	a.configMu.Lock()
+	config := a.currentConfig
+	a.configMu.Unlock()
+	project, _ := a.GetActiveProject()
+	instance := a.activeServer()
+	serverURL := a.apiClient.URL()
+
+	diagnostics := &models.Diagnostics{
+		Time:      time.Now().UnixMilli(),
+		ServerURL: serverURL,
+		Project:   project,
+		Server:    instance.supervisor.Status(),
+		Stream:    a.streamClient.Status(),
+		Checks:    make([]models.DiagnosticCheck, 6),
+	}
+	checks := diagnostics.Checks
+	checks[2] = services.CheckEventStream(diagnostics.Stream)
+	checks[4] = services.CheckServerProcess(diagnostics.Server)
+
+	var wg sync.WaitGroup
+	run := func(check func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			check()
+		}()
+	}
+	run(func() { checks[0] = services.CheckServerReachability(serverURL) })
+	run(func() { checks[1] = services.CheckServerConfig(serverURL) })
+	run(func() {
+		if diagnostics.Server.Managed {
+			checks[3], diagnostics.OpenCodeVersion = services.CheckOpenCodeVersion(a.ctx, config.Server)
+		} else {
+			checks[3], diagnostics.OpenCodeVersion = services.CheckServerVersion(serverURL)
+		}
+	})
+	run(func() { checks[5] = services.CheckLLMEndpoint(a.ctx, a.getLLMClient()) })
+	wg.Wait()
+	return diagnostics
+}
+
+// GetDiagnosticsBundle returns the diagnostics together with the app
+// environment, the config and the recent server output as JSON text for bug
+// reports. API keys, server environment values and anything that looks like
+// a credential are redacted.
+func (a *App) GetDiagnosticsBundle() (string, error) {
+	config, err := a.appConfigService.GetAppConfig()
+	if err != nil {
+		return "", fmt.Errorf("failed to get app config: %w", err)
+	}
+	bundle := &models.DiagnosticsBundle{
+		App: models.DiagnosticsAppInfo{
+			OS:         goruntime.GOOS,
+			Arch:       goruntime.GOARCH,
+			GoVersion:  goruntime.Version(),
+			ConfigPath: a.appConfigService.Path(),
+		},
+		Diagnostics: a.GetDiagnostics(),
+		Config:      *config,
+		Overrides:   a.appConfigService.Overrides(),
+		ServerLog:   a.activeServer().log.Lines(diagnosticsLogLines),
+	}
+
+	var secrets []string
+	if resolved, err := a.secretService.ResolveLLM(config.LLM); err == nil {
+		secrets = append(secrets, resolved.APIKey)
+	}
+	if passphrase := os.Getenv(services.SecretPassphraseEnv); passphrase != "" {
+		secrets = append(secrets, passphrase)
+	}
+	data, err := services.RedactBundle(bundle, secrets)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
 }
 
 // === セッション関連 ===
 
 // GetSessions returns all sessions.
 func (a *App) GetSessions() ([]models.Session, error) {
-	return a.sessionService.GetSessions()
+	return a.sessionService.GetSessions(a.ctx)
 }
 
 // GetSession returns a single session by ID.
 func (a *App) GetSession(id string) (*models.Session, error) {
-	return a.sessionService.GetSession(id)
+	return a.sessionService.GetSession(a.ctx, id)
 }
 
 // CreateSession creates a new session.
 func (a *App) CreateSession(title string) (*models.Session, error) {
-	return a.sessionService.CreateSession(title)
+	return a.sessionService.CreateSession(a.ctx, title)
 }
 
 // UpdateSession updates a session.
 func (a *App) UpdateSession(id string, title string) (*models.Session, error) {
-	return a.sessionService.UpdateSession(id, title)
+	return a.sessionService.UpdateSession(a.ctx, id, title)
 }
 
 // DeleteSession deletes a session.
 func (a *App) DeleteSession(id string) error {
-	return a.sessionService.DeleteSession(id)
+	return a.sessionService.DeleteSession(a.ctx, id)
 }
 
 // SummarizeSession summarizes a session (generates session title/summary).
 func (a *App) SummarizeSession(sessionID string, providerID string, modelID string) error {
-	return a.sessionService.SummarizeSession(sessionID, providerID, modelID)
+	return a.sessionService.SummarizeSession(a.ctx, sessionID, providerID, modelID)
+}
+
+// GetCompactionHistory returns the most recent automatic compactions. An
+// empty sessionID returns the records of all sessions.
+func (a *App) GetCompactionHistory(sessionID string) ([]models.CompactionRecord, error) {
+	return a.compactionService.History(sessionID)
 }
 
 // SummarizeSessionTitle generates a one-line session summary and updates the session title.
 func (a *App) SummarizeSessionTitle(sessionID string) (string, error) {
-	messages, err := a.messageService.GetMessages(sessionID)
+	messages, err := a.messageService.GetMessages(a.ctx, sessionID)
 	if err != nil {
 		return "", fmt.Errorf("failed to get messages: %w", err)
 	}
@@ -255,7 +969,7 @@ func (a *App) SummarizeSessionTitle(sessionID string) (string, error) {
 	title := ""
 
 	// Prefer LLM-based title generation when configured.
-	if a.llmClient != nil {
+	if llmClient := a.getLLMClient(); llmClient != nil {
 		appConfig, cfgErr := a.appConfigService.GetAppConfig()
 		if cfgErr == nil && appConfig.LLM.Model != "" {
 			req := &models.PolishTextRequest{
@@ -269,7 +983,7 @@ func (a *App) SummarizeSessionTitle(sessionID string) (string, error) {
 					"会話:\n{text}",
 				Model: appConfig.LLM.Model,
 			}
-			resp, llmErr := a.llmClient.PolishText(req)
+			resp, llmErr := llmClient.PolishTextContext(a.ctx, req)
 			if llmErr == nil && resp != nil {
 				title = sanitizeSingleLineTitle(resp.PolishedText)
 			}
@@ -284,7 +998,7 @@ func (a *App) SummarizeSessionTitle(sessionID string) (string, error) {
 		return "", fmt.Errorf("failed to generate session title")
 	}
 
-	if _, err := a.sessionService.UpdateSession(sessionID, title); err != nil {
+	if _, err := a.sessionService.UpdateSession(a.ctx, sessionID, title); err != nil {
 		return "", fmt.Errorf("failed to update session title: %w", err)
 	}
 
@@ -345,112 +1059,491 @@ func sanitizeSingleLineTitle(input string) string {
 
 // GetMessages returns all messages for a session.
 func (a *App) GetMessages(sessionID string) ([]models.MessageWithParts, error) {
-	return a.messageService.GetMessages(sessionID)
+	return a.messageService.GetMessages(a.ctx, sessionID)
 }
 
-// SendMessage sends a message to a session.
+// SendMessage sends a message to a session and waits for the agent's reply.
+// When translation mode is enabled, text parts are translated before sending
+// and the originals are kept locally.
 func (a *App) SendMessage(sessionID string, req *models.ChatInput) (*models.MessageWithParts, error) {
-	return a.messageService.SendMessage(sessionID, req)
+	outgoing, err := a.prepareOutgoing(sessionID, req)
+	if err != nil {
+		return nil, err
+	}
+	return a.messageService.SendMessage(a.ctx, sessionID, outgoing)
+}
+
+// SendMessageAsync sends a message to a session and returns the ID of the
+// sent message as soon as the server has accepted it. The reply arrives
+// through the usual message events; a "message.completed" event with the
+// returned ID is emitted once the reply has finished and the session is
+// idle, or when the session fails. Translation mode applies as in SendMessage.
+func (a *App) SendMessageAsync(sessionID string, req *models.ChatInput) (string, error) {
+	outgoing, err := a.prepareOutgoing(sessionID, req)
+	if err != nil {
+		return "", err
+	}
+
+	a.sendMu.Lock()
+	a.pendingSends[outgoing.MessageID] = &pendingSend{sessionID: sessionID, startedAt: time.Now().UnixMilli()}
+	a.sendMu.Unlock()
+	if err := a.messageService.SendMessageAsync(a.ctx, sessionID, outgoing); err != nil {
+		a.sendMu.Lock()
+		delete(a.pendingSends, outgoing.MessageID)
+		a.sendMu.Unlock()
+		return "", err
+	}
+	return outgoing.MessageID, nil
+}
+
+// trackAsyncSend follows the events of sessions with an asynchronous send
+// in flight. A send finishes with a "message.completed" event on
+// session.idle once an assistant message replying to it has finished, or on
+// session.error.
+func (a *App) trackAsyncSend(event *models.Event) {
+	sessionID := event.SessionID()
+	if sessionID == "" {
+		return
+	}
+
+	switch event.Type {
+	case "message.updated":
+		var info struct {
+			Role     string `json:"role"`
+			ParentID string `json:"parentID"`
+			Time     struct {
+				Completed int64 `json:"completed"`
+			} `json:"time"`
+			Error interface{} `json:"error"`
+		}
+		data, _ := json.Marshal(event.Properties["info"])
+		if json.Unmarshal(data, &info) != nil || info.Role != "assistant" {
+			return
+		}
+		if info.Time.Completed == 0 && info.Error == nil {
+			return
+		}
+		a.sendMu.Lock()
+		if pending, ok := a.pendingSends[info.ParentID]; ok {
+			pending.answered = true
+			pending.replyError = ""
+			if info.Error != nil {
+				pending.replyError = apiErrorMessage(info.Error)
+			}
+		}
+		a.sendMu.Unlock()
+	case "session.idle", "session.error":
+		var completions []models.MessageCompletion
+		a.sendMu.Lock()
+		for messageID, pending := range a.pendingSends {
+			if pending.sessionID != sessionID {
+				continue
+			}
+			// On idle, sends not answered yet belong to a later run.
+			if event.Type == "session.idle" && !pending.answered {
+				continue
+			}
+			delete(a.pendingSends, messageID)
+			completion := pending.completion(messageID)
+			if event.Type == "session.error" {
+				completion.Error = sessionErrorMessage(event)
+			}
+			completions = append(completions, completion)
+		}
+		a.sendMu.Unlock()
+		for _, completion := range completions {
+			a.emitAppEvent("message.completed", completion)
+		}
+	}
+}
+
+// abandonAsyncSends finishes the asynchronous sends in flight with an error
+// after switching servers, whose events no longer arrive.
+func (a *App) abandonAsyncSends() {
+	a.sendMu.Lock()
+	pendingSends := a.pendingSends
+	a.pendingSends = make(map[string]*pendingSend)
+	a.sendMu.Unlock()
+	for messageID, pending := range pendingSends {
+		completion := pending.completion(messageID)
+		completion.Error = "switched to another server before the reply finished"
+		a.emitAppEvent("message.completed", completion)
+	}
+}
+
+func (p *pendingSend) completion(messageID string) models.MessageCompletion {
+	return models.MessageCompletion{
+		SessionID:  p.sessionID,
+		MessageID:  messageID,
+		StartedAt:  p.startedAt,
+		FinishedAt: time.Now().UnixMilli(),
+		Error:      p.replyError,
+	}
+}
+
+// sessionErrorMessage returns a readable message for a session.error event.
+func sessionErrorMessage(event *models.Event) string {
+	return apiErrorMessage(event.Properties["error"])
+}
+
+// apiErrorMessage returns the message of an error object of the server API.
+func apiErrorMessage(value interface{}) string {
+	errInfo, ok := value.(map[string]interface{})
+	if !ok {
+		return "session error"
+	}
+	if data, ok := errInfo["data"].(map[string]interface{}); ok {
+		if message, ok := data["message"].(string); ok && message != "" {
+			return message
+		}
+	}
+	if name, ok := errInfo["name"].(string); ok && name != "" {
+		return name
+	}
+	return "session error"
+}
+
+// prepareOutgoing returns a copy of the request to send with a new message
+// ID. When translation mode is enabled, text parts are translated and the
+// pairs are stored under that message ID.
+func (a *App) prepareOutgoing(sessionID string, req *models.ChatInput) (*models.ChatInput, error) {
+	outgoing := models.ChatInput{}
+	if req != nil {
+		outgoing = *req
+	}
+	if outgoing.MessageID == "" {
+		outgoing.MessageID = api.NewMessageID()
+	}
+	appConfig, err := a.appConfigService.GetAppConfig()
+	if err != nil || !appConfig.Translation.Enabled {
+		return &outgoing, nil
+	}
+
+	translation := appConfig.Translation
+	language := translation.TargetLanguage
+	if language == "" {
+		language = "English"
+	}
+
+	parts := outgoing.Parts
+	outgoing.Parts = make([]models.TextInputPart, len(parts))
+	var entries []models.TranslationEntry
+	for i, part := range parts {
+		outgoing.Parts[i] = part
+		if part.Type != "text" || strings.TrimSpace(part.Text) == "" {
+			continue
+		}
+		translated, err := a.translateText(appConfig, part.Text, language)
+		if err != nil {
+			return nil, fmt.Errorf("failed to translate message: %w", err)
+		}
+		outgoing.Parts[i].Text = translated
+		entries = append(entries, models.TranslationEntry{
+			SessionID:  sessionID,
+			MessageID:  outgoing.MessageID,
+			Direction:  models.TranslationOutgoing,
+			Language:   language,
+			Original:   part.Text,
+			Translated: translated,
+			Time:       time.Now().UnixMilli(),
+		})
+	}
+	if err := a.translationService.Save(entries...); err != nil {
+		a.logger.Warnf("failed to store translation: %v", err)
+	}
+	return &outgoing, nil
+}
+
+// TranslateMessagePart translates a completed text part back to the source
+// language and stores both versions.
+func (a *App) TranslateMessagePart(sessionID string, messageID string, partID string) (*models.TranslationEntry, error) {
+	llmClient := a.getLLMClient()
+	if llmClient == nil {
+		return nil, fmt.Errorf("LLM client not initialized")
+	}
+	appConfig, err := a.appConfigService.GetAppConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get app config: %w", err)
+	}
+
+	messages, err := a.messageService.GetMessages(a.ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+	var original string
+	found := false
+	for _, msg := range messages {
+		if msg.Info == nil || msg.Info.GetID() != messageID {
+			continue
+		}
+		for _, part := range msg.Parts {
+			if textPart, ok := part.(models.TextPart); ok && textPart.ID == partID {
+				original = textPart.Text
+				found = true
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("text part %s not found in message %s", partID, messageID)
+	}
+
+	language := appConfig.Translation.SourceLanguage
+	if language == "" {
+		language = "日本語"
+	}
+	translated, err := a.translateText(appConfig, original, language)
+	if err != nil {
+		return nil, fmt.Errorf("failed to translate message: %w", err)
+	}
+
+	entry := models.TranslationEntry{
+		SessionID:  sessionID,
+		MessageID:  messageID,
+		PartID:     partID,
+		Direction:  models.TranslationIncoming,
+		Language:   language,
+		Original:   original,
+		Translated: translated,
+		Time:       time.Now().UnixMilli(),
+	}
+	if err := a.translationService.Save(entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetTranslations returns the stored translations of a session.
+func (a *App) GetTranslations(sessionID string) ([]models.TranslationEntry, error) {
+	return a.translationService.List(sessionID)
+}
+
+func (a *App) translateText(appConfig *models.AppConfig, text string, language string) (string, error) {
+	llmClient := a.getLLMClient()
+	if llmClient == nil {
+		return "", fmt.Errorf("LLM client not initialized")
+	}
+	req := &models.PolishTextRequest{
+		Text:      text,
+		Prompt:    appConfig.Translation.Prompt,
+		Model:     appConfig.Translation.Model,
+		Variables: map[string]string{"language": language},
+	}
+	if req.Prompt == "" {
+		req.Prompt = models.DefaultTranslationPrompt
+	}
+	if req.Model == "" {
+		req.Model = appConfig.LLM.Model
+	}
+	resp, err := llmClient.PolishTextContext(a.ctx, req)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(resp.PolishedText), nil
 }
 
 // StopMessage stops the current agent execution in a session.
 func (a *App) StopMessage(sessionID string) error {
-	return a.messageService.StopMessage(sessionID)
+	return a.messageService.StopMessage(a.ctx, sessionID)
 }
 
 // RespondPermission responds to a pending permission/question request in a session.
 func (a *App) RespondPermission(sessionID string, permissionID string, response string) error {
-	return a.messageService.RespondPermission(sessionID, permissionID, response)
+	req := a.permissionAudit.Pending(permissionID)
+	err := a.messageService.RespondPermission(a.ctx, sessionID, permissionID, response)
+	if auditErr := a.permissionAudit.RecordUser(req, sessionID, permissionID, response, err); auditErr != nil {
+		a.logger.Warnf("failed to write permission audit log: %v", auditErr)
+	}
+	return err
+}
+
+// GetPermissionAudit returns the permission audit log entries matching filter.
+func (a *App) GetPermissionAudit(filter *models.PermissionAuditFilter) ([]models.PermissionAuditEntry, error) {
+	return a.permissionAudit.Query(filter)
+}
+
+// EvaluatePermissionPolicy returns what the permission policy would answer for
+// a permission request, without responding to it.
+func (a *App) EvaluatePermissionPolicy(req *models.PermissionRequest) *models.PermissionDecision {
+	return a.permissionPolicy.Evaluate(req)
 }
 
 // SendTUIControlResponse sends a response body for interactive TUI control requests.
 func (a *App) SendTUIControlResponse(body interface{}) error {
-	return a.messageService.SendTUIControlResponse(body)
+	return a.messageService.SendTUIControlResponse(a.ctx, body)
 }
 
-// GetSessionTokens returns token usage information for a session.
+// GetSessionTokens returns context window usage for a session. Only the most
+// recent turn counts towards the context, since every turn re-sends the history.
 func (a *App) GetSessionTokens(sessionID string) (*models.SessionTokens, error) {
 	// Get all messages for the session
-	messages, err := a.messageService.GetMessages(sessionID)
+	messages, err := a.messageService.GetMessages(a.ctx, sessionID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get messages: %w", err)
 	}
 
-	// Calculate total tokens used
-	totalInput := 0
-	totalOutput := 0
-	var lastModelID, lastProviderID string
+	result := &models.SessionTokens{Turns: []models.TurnTokens{}}
 
 	for _, msg := range messages {
-		if assistantMsg, ok := msg.Info.(models.AssistantMessage); ok {
-			totalInput += assistantMsg.Tokens.Input
-			totalOutput += assistantMsg.Tokens.Output
-			lastModelID = assistantMsg.ModelID
-			lastProviderID = assistantMsg.ProviderID
+		assistantMsg, ok := msg.Info.(models.AssistantMessage)
+		if !ok {
+			continue
+		}
+		turn := models.TurnTokens{
+			MessageID:  assistantMsg.ID,
+			ModelID:    assistantMsg.ModelID,
+			ProviderID: assistantMsg.ProviderID,
+			Input:      assistantMsg.Tokens.Input,
+			Output:     assistantMsg.Tokens.Output,
+			Reasoning:  assistantMsg.Tokens.Reasoning,
+			CacheRead:  assistantMsg.Tokens.Cache.Read,
+			CacheWrite: assistantMsg.Tokens.Cache.Write,
+			Context:    assistantMsg.Tokens.Input + assistantMsg.Tokens.Cache.Read,
+			Cost:       assistantMsg.Cost,
+		}
+		result.Turns = append(result.Turns, turn)
+
+		// The model of the latest assistant message is the current one, even
+		// while its tokens are still zero (in progress or aborted).
+		if assistantMsg.ModelID != "" {
+			result.ModelID = assistantMsg.ModelID
+			result.ProviderID = assistantMsg.ProviderID
+		}
+		if turn.Context > 0 {
+			result.Used = turn.Context
 		}
 	}
 
-	totalUsed := totalInput + totalOutput
+	if result.ModelID == "" {
+		return result, nil
+	}
 
 	// Get provider information to find context limit
-	providersResp, err := a.configService.GetProviders()
+	providersResp, err := a.configService.GetProviders(a.ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get providers: %w", err)
 	}
 
-	// Find the model's context limit
-	var contextLimit int
+	if model, ok := findProviderModel(providersResp, result.ProviderID, result.ModelID); ok {
+		result.Max = model.Limit.Context
+		result.OutputLimit = model.Limit.Output
+	}
+
+	// Calculate percentage
+	if result.Max > 0 {
+		result.Percentage = (float64(result.Used) / float64(result.Max)) * 100
+	}
+
+	return result, nil
+}
+
+// findProviderModel looks up a model by provider and model ID. If the provider
+// is unknown (e.g. it was renamed or removed after the session switched
+// providers), the first provider offering the model ID is used instead.
+func findProviderModel(providersResp *models.ProvidersResponse, providerID string, modelID string) (models.Model, bool) {
 	for _, provider := range providersResp.Providers {
-		if provider.ID == lastProviderID {
-			if model, ok := provider.Models[lastModelID]; ok {
-				contextLimit = model.Limit.Context
-				break
+		if provider.ID == providerID {
+			if model, ok := provider.Models[modelID]; ok {
+				return model, true
 			}
 		}
 	}
-
-	// Calculate percentage
-	var percentage float64
-	if contextLimit > 0 {
-		percentage = (float64(totalUsed) / float64(contextLimit)) * 100
+	for _, provider := range providersResp.Providers {
+		if model, ok := provider.Models[modelID]; ok {
+			return model, true
+		}
 	}
+	return models.Model{}, false
+}
+
+// === 利用状況関連 ===
+
+// GetUsageReport aggregates cost and token usage across sessions in the given
+// range, grouped by "session", "model", "provider" or "day".
+func (a *App) GetUsageReport(rng *models.UsageRange, groupBy string) (*models.UsageReport, error) {
+	return a.usageService.GetUsageReport(a.ctx, rng, groupBy)
+}
+
+// ExportUsageCSV writes the usage report to path as CSV.
+func (a *App) ExportUsageCSV(rng *models.UsageRange, groupBy string, path string) error {
+	return a.usageService.ExportUsageCSV(a.ctx, rng, groupBy, path)
+}
+
+// GetBudgetStatus returns the current spending of a session against the configured budget.
+func (a *App) GetBudgetStatus(sessionID string) (*models.BudgetStatus, error) {
+	return a.budgetService.Status(a.ctx, sessionID)
+}
+
+// OverrideBudget exempts a session from the configured budgets so that it can resume.
+func (a *App) OverrideBudget(sessionID string) {
+	a.budgetService.Override(sessionID)
+}
+
+// ClearBudgetOverride makes the configured budgets apply to the session again.
+func (a *App) ClearBudgetOverride(sessionID string) {
+	a.budgetService.ClearOverride(sessionID)
+}
+
+// === エクスポート・インポート関連 ===
+
+// ExportSession exports a session to path. format is one of "markdown", "json" or "html".
+func (a *App) ExportSession(sessionID string, format string, path string) error {
+	return a.exportService.ExportSession(a.ctx, sessionID, path, &models.ExportOptions{Format: format})
+}
+
+// ExportSessionWithOptions exports a session to path using the given export options.
+func (a *App) ExportSessionWithOptions(sessionID string, path string, options *models.ExportOptions) error {
+	return a.exportService.ExportSession(a.ctx, sessionID, path, options)
+}
+
+// ImportSession imports a JSON export into a new session. mode is "replay" to
+// re-send the user messages in order or "transcript" to post a condensed
+// transcript as context. Progress is reported via "import.progress" events.
+func (a *App) ImportSession(path string, mode string) (*models.Session, error) {
+	return a.importService.ImportSession(a.ctx, path, mode, func(progress models.ImportProgress) {
+		a.emitAppEvent("import.progress", progress)
+	})
+}
+
+// === 検索関連 ===
 
-	return &models.SessionTokens{
-		Used:       totalUsed,
-		Max:        contextLimit,
-		Percentage: percentage,
-		ModelID:    lastModelID,
-		ProviderID: lastProviderID,
-	}, nil
+// SearchMessages searches the text and tool parts of all sessions' messages.
+func (a *App) SearchMessages(query string, filters *models.MessageSearchFilters) ([]models.MessageSearchResult, error) {
+	return a.searchService.Search(a.ctx, query, filters)
+}
+
+// RebuildSearchIndex rebuilds the local message search index from the server.
+func (a *App) RebuildSearchIndex() error {
+	return a.searchService.BuildIndex(a.ctx)
 }
 
 // === ファイル操作関連 ===
 
 // FindInFiles searches for a pattern in files.
 func (a *App) FindInFiles(pattern string) ([]models.SearchResult, error) {
-	return a.fileService.FindInFiles(pattern)
+	return a.fileService.FindInFiles(a.ctx, pattern)
 }
 
 // FindFiles finds files by a query.
 func (a *App) FindFiles(query string) ([]string, error) {
-	return a.fileService.FindFiles(query)
+	return a.fileService.FindFiles(a.ctx, query)
 }
 
 // FindSymbols finds symbols by a query.
 func (a *App) FindSymbols(query string) ([]models.Symbol, error) {
-	return a.fileService.FindSymbols(query)
+	return a.fileService.FindSymbols(a.ctx, query)
 }
 
 // ReadFile reads the content of a file.
 func (a *App) ReadFile(path string) (*models.FileContent, error) {
-	return a.fileService.ReadFile(path)
+	return a.fileService.ReadFile(a.ctx, path)
 }
 
 // === LLM関連 ===
 
 // PolishText polishes the given text using LLM.
 func (a *App) PolishText(text string) (string, error) {
-	if a.llmClient == nil {
+	llmClient := a.getLLMClient()
+	if llmClient == nil {
 		return "", fmt.Errorf("LLM client not initialized")
 	}
 
@@ -466,10 +1559,177 @@ func (a *App) PolishText(text string) (string, error) {
 		Model:  appConfig.LLM.Model,
 	}
 
-	resp, err := a.llmClient.PolishText(req)
+	resp, err := llmClient.PolishTextContext(a.ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("failed to polish text: %w", err)
+	}
+
+	return resp.PolishedText, nil
+}
+
+// PolishTextWithDiff polishes the given text and returns a structured diff
+// between the original and the polished text. granularity is "word"
+// (default; CJK text is still compared per character) or "char".
+func (a *App) PolishTextWithDiff(text string, granularity string) (*models.TextDiff, error) {
+	polished, err := a.PolishText(text)
+	if err != nil {
+		return nil, err
+	}
+	return services.DiffText(text, polished, models.DiffGranularity(granularity)), nil
+}
+
+// ApplyDiffHunks rebuilds the text from a diff returned by PolishTextWithDiff,
+// taking the polished version of the accepted hunks and the original of the rest.
+func (a *App) ApplyDiffHunks(diff *models.TextDiff, accepted []int) (string, error) {
+	return services.ApplyDiffHunks(diff, accepted)
+}
+
+// PolishTextStream polishes the given text using a streamed LLM completion.
+// Each chunk is emitted as a "polish.delta" event tagged with requestID, and
+// the call can be cancelled with CancelPolish(requestID).
+func (a *App) PolishTextStream(requestID string, text string) (string, error) {
+	llmClient := a.getLLMClient()
+	if llmClient == nil {
+		return "", fmt.Errorf("LLM client not initialized")
+	}
+
+	appConfig, err := a.appConfigService.GetAppConfig()
+	if err != nil {
+		return "", fmt.Errorf("failed to get app config: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(a.ctx)
+	call := &polishCall{cancel: cancel}
+	a.polishMu.Lock()
+	if prev, ok := a.polishCalls[requestID]; ok {
+		prev.cancel()
+	}
+	a.polishCalls[requestID] = call
+	a.polishMu.Unlock()
+	defer func() {
+		cancel()
+		a.polishMu.Lock()
+		if a.polishCalls[requestID] == call {
+			delete(a.polishCalls, requestID)
+		}
+		a.polishMu.Unlock()
+	}()
+
+	req := &models.PolishTextRequest{
+		Text:   text,
+		Prompt: appConfig.LLM.Prompt,
+		Model:  appConfig.LLM.Model,
+	}
+
+	resp, err := llmClient.PolishTextStream(ctx, req, func(delta string) {
+		a.emitAppEvent("polish.delta", map[string]string{
+			"requestID": requestID,
+			"delta":     delta,
+		})
+	})
 	if err != nil {
+		if ctx.Err() == context.Canceled {
+			return "", fmt.Errorf("polish cancelled")
+		}
 		return "", fmt.Errorf("failed to polish text: %w", err)
 	}
 
 	return resp.PolishedText, nil
 }
+
+// CancelPolish cancels a running PolishTextStream call.
+func (a *App) CancelPolish(requestID string) {
+	a.polishMu.Lock()
+	defer a.polishMu.Unlock()
+	if call, ok := a.polishCalls[requestID]; ok {
+		call.cancel()
+	}
+}
+
+// GetPromptTemplates returns the configured prompt templates, or the default
+// templates when none are configured.
+func (a *App) GetPromptTemplates() ([]models.PromptTemplate, error) {
+	appConfig, err := a.appConfigService.GetAppConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get app config: %w", err)
+	}
+	return promptTemplates(appConfig), nil
+}
+
+// TransformText runs the named prompt template on text.
+func (a *App) TransformText(templateName string, text string) (string, error) {
+	return a.TransformTextWithVariables(&models.TransformTextRequest{Template: templateName, Text: text})
+}
+
+// TransformTextWithVariables runs a prompt template with the variables in req.
+// {session_title} is resolved from req.SessionID.
+func (a *App) TransformTextWithVariables(req *models.TransformTextRequest) (string, error) {
+	llmClient := a.getLLMClient()
+	if llmClient == nil {
+		return "", fmt.Errorf("LLM client not initialized")
+	}
+
+	appConfig, err := a.appConfigService.GetAppConfig()
+	if err != nil {
+		return "", fmt.Errorf("failed to get app config: %w", err)
+	}
+
+	var template *models.PromptTemplate
+	for _, t := range promptTemplates(appConfig) {
+		if t.Name == req.Template {
+			template = &t
+			break
+		}
+	}
+	if template == nil {
+		return "", fmt.Errorf("unknown prompt template: %s", req.Template)
+	}
+
+	variables := map[string]string{}
+	for name, value := range template.Variables {
+		variables[name] = value
+	}
+	for name, value := range req.Variables {
+		variables[name] = value
+	}
+	if req.Selection != "" {
+		variables["selection"] = req.Selection
+	}
+	if req.Language != "" {
+		variables["language"] = req.Language
+	}
+	if req.SessionID != "" {
+		session, err := a.sessionService.GetSession(a.ctx, req.SessionID)
+		if err != nil {
+			return "", fmt.Errorf("failed to get session: %w", err)
+		}
+		variables["session_title"] = session.Title
+	}
+
+	polishReq := &models.PolishTextRequest{
+		Text:      req.Text,
+		Prompt:    template.Prompt,
+		Model:     template.Model,
+		Variables: variables,
+	}
+	if polishReq.Prompt == "" {
+		polishReq.Prompt = appConfig.LLM.Prompt
+	}
+	if polishReq.Model == "" {
+		polishReq.Model = appConfig.LLM.Model
+	}
+
+	resp, err := llmClient.PolishTextContext(a.ctx, polishReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to transform text: %w", err)
+	}
+
+	return resp.PolishedText, nil
+}
+
+func promptTemplates(appConfig *models.AppConfig) []models.PromptTemplate {
+	if len(appConfig.Templates) > 0 {
+		return appConfig.Templates
+	}
+	return models.DefaultPromptTemplates()
+}
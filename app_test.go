@@ -0,0 +1,613 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"fm-opencode-tinyapp/internal/api"
+	"fm-opencode-tinyapp/internal/models"
+	"fm-opencode-tinyapp/internal/services"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newTestApp returns an App connected to config without starting a server
+// process or the event stream.
+func newTestApp(t *testing.T, config *models.AppConfig) *App {
+	t.Helper()
+	dir := t.TempDir()
+	appConfigService, err := services.NewAppConfigServiceAt(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatalf("NewAppConfigServiceAt: %v", err)
+	}
+	if err := appConfigService.UpdateAppConfig(config); err != nil {
+		t.Fatalf("UpdateAppConfig: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+
+	a := NewApp()
+	a.ctx = ctx
+	a.logger = logger
+	a.appConfigService = appConfigService
+	a.secretService = services.NewSecretServiceWithStore(services.NewEncryptedFileStore(filepath.Join(dir, "secrets.enc"), "test", logger))
+	a.apiClient = api.NewClient(config.ServerURL)
+	a.streamClient = api.NewStreamClient(config.ServerURL, logger)
+	a.llmClient = a.newLLMClient(config.LLM)
+	a.sessionService = services.NewSessionService(a.apiClient)
+	a.messageService = services.NewMessageService(a.apiClient)
+	a.configService = services.NewConfigService(a.apiClient)
+	a.searchService = services.NewSearchService(a.apiClient)
+	a.budgetService = services.NewBudgetService(a.apiClient)
+	a.compactionService = services.NewCompactionServiceAt(a.apiClient, filepath.Join(dir, "compactions.json"))
+	a.permissionPolicy = services.NewPermissionPolicyService(a.apiClient)
+	a.hookService = services.NewHookService()
+	a.translationService = services.NewTranslationServiceAt(dir)
+	a.projectService = services.NewProjectServiceAt(filepath.Join(dir, "projects.json"))
+	a.currentConfig = config
+	if err := a.activateDefaultServer(false); err != nil {
+		t.Fatalf("activateDefaultServer: %v", err)
+	}
+	return a
+}
+
+// fakeOpenCodeServer answers every OpenCode API request with an empty list.
+func fakeOpenCodeServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// fakeLLM is an OpenAI compatible server that records the requested models
+// and prompts.
+type fakeLLM struct {
+	*httptest.Server
+	mu      sync.Mutex
+	models  []string
+	prompts []string
+}
+
+func newFakeLLM(t *testing.T, reply string) *fakeLLM {
+	t.Helper()
+	f := &fakeLLM{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model    string        `json:"model"`
+			Messages []api.Message `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		f.mu.Lock()
+		f.models = append(f.models, body.Model)
+		if len(body.Messages) > 0 {
+			f.prompts = append(f.prompts, body.Messages[len(body.Messages)-1].Content)
+		}
+		f.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": reply}}},
+		})
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeLLM) requestedModels() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.models...)
+}
+
+func (f *fakeLLM) requestedPrompts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.prompts...)
+}
+
+func TestPatchAppConfigReloadsClients(t *testing.T) {
+	oldServer, newServer := fakeOpenCodeServer(t), fakeOpenCodeServer(t)
+	oldLLM, newLLM := newFakeLLM(t, "old"), newFakeLLM(t, "new")
+
+	config := services.DefaultAppConfig()
+	config.ServerURL = oldServer.URL
+	config.Server.Disabled = true
+	config.LLM.Adapter = models.LLMAdapterOpenAI
+	config.LLM.BaseURL = oldLLM.URL
+	config.LLM.APIKey = "sk-test"
+	config.LLM.Model = "old-model"
+	config.Budget.SessionCost = 1
+	a := newTestApp(t, config)
+
+	var mu sync.Mutex
+	var changes []string
+	a.eventEmitter = func(event *models.Event) {
+		if event.Type == "config.changed" {
+			mu.Lock()
+			changes = append(changes, event.Properties["source"].(string))
+			mu.Unlock()
+		}
+	}
+
+	if got, err := a.PolishText("text"); err != nil || got != "old" {
+		t.Fatalf("PolishText before the update = %q, %v", got, err)
+	}
+
+	err := a.PatchAppConfig(map[string]interface{}{
+		"serverURL": newServer.URL,
+		"llm":       map[string]interface{}{"baseURL": newLLM.URL, "model": "new-model"},
+	})
+	if err != nil {
+		t.Fatalf("PatchAppConfig: %v", err)
+	}
+
+	if got := a.apiClient.URL(); got != newServer.URL {
+		t.Errorf("API client URL = %s, want %s", got, newServer.URL)
+	}
+	if got, err := a.PolishText("text"); err != nil || got != "new" {
+		t.Errorf("PolishText after the update = %q, %v; want the new LLM", got, err)
+	}
+	if got := newLLM.requestedModels(); len(got) != 1 || got[0] != "new-model" {
+		t.Errorf("new LLM got models %v, want [new-model]", got)
+	}
+	if got := oldLLM.requestedModels(); len(got) != 1 {
+		t.Errorf("old LLM got %d requests after the update, want none", len(got)-1)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(changes) != 1 || changes[0] != models.ConfigSourceAPI {
+		t.Errorf("config.changed sources = %v, want [%s]", changes, models.ConfigSourceAPI)
+	}
+	saved, err := a.GetAppConfig()
+	if err != nil {
+		t.Fatalf("GetAppConfig: %v", err)
+	}
+	if saved.Budget.SessionCost != 1 || !saved.Server.Disabled {
+		t.Errorf("sections not in the patch were lost: %+v", saved)
+	}
+}
+
+func assistantMessageEvent(sessionID, messageID, parentID string) *models.Event {
+	return &models.Event{
+		Type: "message.updated",
+		Properties: map[string]interface{}{
+			"info": map[string]interface{}{
+				"id": messageID, "sessionID": sessionID, "role": "assistant", "parentID": parentID,
+				"time": map[string]interface{}{"created": 1, "completed": 2},
+			},
+		},
+	}
+}
+
+func TestSendMessageAsyncCompletesBySentMessage(t *testing.T) {
+	server := fakeOpenCodeServer(t)
+	config := services.DefaultAppConfig()
+	config.ServerURL = server.URL
+	config.Server.Disabled = true
+	a := newTestApp(t, config)
+
+	var completions []models.MessageCompletion
+	a.eventEmitter = func(event *models.Event) {
+		if event.Type == "message.completed" {
+			data, _ := json.Marshal(event.Properties)
+			var completion models.MessageCompletion
+			json.Unmarshal(data, &completion)
+			completions = append(completions, completion)
+		}
+	}
+
+	first, err := a.SendMessageAsync("ses_1", &models.ChatInput{})
+	if err != nil {
+		t.Fatalf("SendMessageAsync: %v", err)
+	}
+	second, err := a.SendMessageAsync("ses_1", &models.ChatInput{})
+	if err != nil {
+		t.Fatalf("SendMessageAsync: %v", err)
+	}
+	if first == second {
+		t.Fatalf("both sends got message ID %s", first)
+	}
+
+	// Only the answered send completes when the session goes idle.
+	a.trackAsyncSend(assistantMessageEvent("ses_1", "msg_reply", first))
+	a.trackAsyncSend(sessionIdleEvent("ses_1"))
+	if len(completions) != 1 || completions[0].MessageID != first || completions[0].Error != "" {
+		t.Fatalf("completions = %+v, want %s only", completions, first)
+	}
+
+	// Switching servers finishes the remaining send with an error.
+	a.connectServer(server.URL)
+	if len(completions) != 2 || completions[1].MessageID != second || completions[1].Error == "" {
+		t.Fatalf("completions = %+v, want %s failed", completions, second)
+	}
+	a.trackAsyncSend(assistantMessageEvent("ses_1", "msg_reply2", second))
+	a.trackAsyncSend(sessionIdleEvent("ses_1"))
+	if len(completions) != 2 {
+		t.Errorf("abandoned send completed again: %+v", completions)
+	}
+}
+
+func sessionIdleEvent(sessionID string) *models.Event {
+	return &models.Event{Type: "session.idle", Properties: map[string]interface{}{"sessionID": sessionID}}
+}
+
+func TestSendMessageStoresTranslationsByMessageID(t *testing.T) {
+	var sentID, sentText string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body models.ChatInput
+		json.NewDecoder(r.Body).Decode(&body)
+		sentID = body.MessageID
+		if len(body.Parts) > 0 {
+			sentText = body.Parts[0].Text
+		}
+		w.Write([]byte(`{"info": {"id": "msg_reply", "sessionID": "ses_1", "role": "assistant"}, "parts": []}`))
+	}))
+	defer server.Close()
+	llm := newFakeLLM(t, "translated")
+
+	config := services.DefaultAppConfig()
+	config.ServerURL = server.URL
+	config.Server.Disabled = true
+	config.LLM.Adapter = models.LLMAdapterOpenAI
+	config.LLM.BaseURL = llm.URL
+	config.LLM.APIKey = "sk-test"
+	config.Translation.Enabled = true
+	a := newTestApp(t, config)
+
+	req := &models.ChatInput{Parts: []models.TextInputPart{{Type: "text", Text: "原文"}}}
+	if _, err := a.SendMessage("ses_1", req); err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if sentID == "" || sentText != "translated" {
+		t.Fatalf("sent message %q with text %q", sentID, sentText)
+	}
+	if req.Parts[0].Text != "原文" || req.MessageID != "" {
+		t.Error("SendMessage modified the request")
+	}
+
+	entries, err := a.GetTranslations("ses_1")
+	if err != nil {
+		t.Fatalf("GetTranslations: %v", err)
+	}
+	if len(entries) != 1 || entries[0].MessageID != sentID || entries[0].Original != "原文" || entries[0].Translated != "translated" {
+		t.Errorf("entries = %+v, want the pair stored under %s", entries, sentID)
+	}
+}
+
+func tokensMessageJSON(id, provider, model string, input, output, cacheRead int) string {
+	return fmt.Sprintf(`{"info": {"id": %q, "sessionID": "ses_1", "role": "assistant", "providerID": %q, "modelID": %q,
+		"cost": 0.1, "tokens": {"input": %d, "output": %d, "reasoning": 0, "cache": {"read": %d, "write": 0}},
+		"time": {"created": 1}}, "parts": []}`, id, provider, model, input, output, cacheRead)
+}
+
+func TestGetSessionTokens(t *testing.T) {
+	userMessage := `{"info": {"id": "msg_0", "sessionID": "ses_1", "role": "user", "time": {"created": 1}}, "parts": []}`
+	cases := []struct {
+		name         string
+		messages     []string
+		wantUsed     int
+		wantMax      int
+		wantModel    string
+		wantProvider string
+		wantTurns    int
+	}{
+		{
+			name:     "no assistant messages",
+			messages: []string{userMessage},
+		},
+		{
+			name: "latest turn counts",
+			messages: []string{
+				userMessage,
+				tokensMessageJSON("msg_1", "anthropic", "claude", 1000, 100, 0),
+				tokensMessageJSON("msg_2", "anthropic", "claude", 500, 50, 1500),
+			},
+			wantUsed: 2000, wantMax: 200000, wantModel: "claude", wantProvider: "anthropic", wantTurns: 2,
+		},
+		{
+			name: "turn in progress keeps the previous usage",
+			messages: []string{
+				tokensMessageJSON("msg_1", "anthropic", "claude", 1000, 100, 0),
+				tokensMessageJSON("msg_2", "openai", "gpt", 0, 0, 0),
+			},
+			wantUsed: 1000, wantMax: 100000, wantModel: "gpt", wantProvider: "openai", wantTurns: 2,
+		},
+		{
+			name:     "unknown provider falls back to the model ID",
+			messages: []string{tokensMessageJSON("msg_1", "removed", "gpt", 5000, 0, 0)},
+			wantUsed: 5000, wantMax: 100000, wantModel: "gpt", wantProvider: "removed", wantTurns: 1,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var mu sync.Mutex
+			providerRequests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/session/ses_1/message":
+					w.Write([]byte("[" + strings.Join(c.messages, ",") + "]"))
+				case "/config/providers":
+					mu.Lock()
+					providerRequests++
+					mu.Unlock()
+					w.Write([]byte(`{"providers": [
+						{"id": "anthropic", "models": {"claude": {"id": "claude", "limit": {"context": 200000, "output": 8192}}}},
+						{"id": "openai", "models": {"gpt": {"id": "gpt", "limit": {"context": 100000, "output": 4096}}}}
+					], "default": {}}`))
+				default:
+					w.Write([]byte(`[]`))
+				}
+			}))
+			defer server.Close()
+
+			config := services.DefaultAppConfig()
+			config.ServerURL = server.URL
+			config.Server.Disabled = true
+			a := newTestApp(t, config)
+
+			tokens, err := a.GetSessionTokens("ses_1")
+			if err != nil {
+				t.Fatalf("GetSessionTokens: %v", err)
+			}
+			if tokens.Used != c.wantUsed || tokens.Max != c.wantMax || tokens.ModelID != c.wantModel || tokens.ProviderID != c.wantProvider {
+				t.Errorf("tokens = %+v", tokens)
+			}
+			if len(tokens.Turns) != c.wantTurns {
+				t.Errorf("got %d turns, want %d", len(tokens.Turns), c.wantTurns)
+			}
+			if c.wantMax > 0 {
+				if want := float64(c.wantUsed) / float64(c.wantMax) * 100; tokens.Percentage != want {
+					t.Errorf("percentage = %v, want %v", tokens.Percentage, want)
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if c.wantModel == "" && providerRequests != 0 {
+				t.Error("providers were requested for a session without a model")
+			}
+		})
+	}
+}
+
+func TestGetSessionTokensTurns(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/session/ses_1/message" {
+			w.Write([]byte("[" + tokensMessageJSON("msg_1", "anthropic", "claude", 10, 20, 30) + "]"))
+			return
+		}
+		w.Write([]byte(`{"providers": [], "default": {}}`))
+	}))
+	defer server.Close()
+
+	config := services.DefaultAppConfig()
+	config.ServerURL = server.URL
+	config.Server.Disabled = true
+	a := newTestApp(t, config)
+
+	tokens, err := a.GetSessionTokens("ses_1")
+	if err != nil {
+		t.Fatalf("GetSessionTokens: %v", err)
+	}
+	want := models.TurnTokens{
+		MessageID: "msg_1", ModelID: "claude", ProviderID: "anthropic",
+		Input: 10, Output: 20, CacheRead: 30, Context: 40, Cost: 0.1,
+	}
+	if len(tokens.Turns) != 1 || tokens.Turns[0] != want {
+		t.Errorf("turns = %+v, want [%+v]", tokens.Turns, want)
+	}
+	if tokens.Max != 0 || tokens.Percentage != 0 {
+		t.Errorf("unknown model has max %d and percentage %v", tokens.Max, tokens.Percentage)
+	}
+}
+
+func TestTransformTextWithVariables(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/session/ses_1" {
+			w.Write([]byte(`{"id": "ses_1", "title": "Release notes"}`))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	llm := newFakeLLM(t, "done")
+
+	config := services.DefaultAppConfig()
+	config.ServerURL = server.URL
+	config.Server.Disabled = true
+	config.LLM.Adapter = models.LLMAdapterOpenAI
+	config.LLM.BaseURL = llm.URL
+	config.LLM.APIKey = "sk-test"
+	config.LLM.Model = "default-model"
+	config.LLM.Prompt = "polish: {text}"
+	config.Templates = []models.PromptTemplate{
+		{Name: "polish"},
+		{
+			Name:      "translate",
+			Prompt:    "{text} -> {language} ({tone}) [{session_title}] {selection}",
+			Model:     "translate-model",
+			Variables: map[string]string{"language": "English", "tone": "plain"},
+		},
+	}
+	a := newTestApp(t, config)
+
+	cases := []struct {
+		name      string
+		req       models.TransformTextRequest
+		wantModel string
+		wantText  string
+	}{
+		{
+			name:      "template defaults",
+			req:       models.TransformTextRequest{Template: "translate", Text: "hello"},
+			wantModel: "translate-model",
+			wantText:  "hello -> English (plain) [{session_title}] {selection}",
+		},
+		{
+			name: "request overrides defaults",
+			req: models.TransformTextRequest{
+				Template: "translate", Text: "hello", SessionID: "ses_1", Selection: "world", Language: "German",
+				Variables: map[string]string{"tone": "friendly", "language": "French"},
+			},
+			wantModel: "translate-model",
+			wantText:  "hello -> German (friendly) [Release notes] world",
+		},
+		{
+			name:      "polish uses the LLM settings",
+			req:       models.TransformTextRequest{Template: "polish", Text: "hello"},
+			wantModel: "default-model",
+			wantText:  "polish: hello",
+		},
+	}
+	for i, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := a.TransformTextWithVariables(&c.req)
+			if err != nil {
+				t.Fatalf("TransformTextWithVariables: %v", err)
+			}
+			if got != "done" {
+				t.Errorf("result = %q", got)
+			}
+			if requested := llm.requestedModels(); len(requested) != i+1 || requested[i] != c.wantModel {
+				t.Errorf("requested models = %v, want %s last", requested, c.wantModel)
+			}
+			if prompts := llm.requestedPrompts(); len(prompts) != i+1 || prompts[i] != c.wantText {
+				t.Errorf("prompts = %q, want %q last", prompts, c.wantText)
+			}
+		})
+	}
+
+	if _, err := a.TransformText("summarize", "hello"); err == nil || !strings.Contains(err.Error(), "unknown prompt template") {
+		t.Errorf("TransformText with a template missing from the config = %v", err)
+	}
+	if _, err := a.TransformTextWithVariables(&models.TransformTextRequest{Template: "translate", Text: "a", SessionID: "ses_missing"}); err == nil {
+		t.Error("TransformTextWithVariables ignored a missing session")
+	}
+}
+
+func TestGetPromptTemplatesDefaults(t *testing.T) {
+	config := services.DefaultAppConfig()
+	config.Server.Disabled = true
+	a := newTestApp(t, config)
+
+	templates, err := a.GetPromptTemplates()
+	if err != nil {
+		t.Fatalf("GetPromptTemplates: %v", err)
+	}
+	var names []string
+	for _, template := range templates {
+		names = append(names, template.Name)
+	}
+	if got := strings.Join(names, ","); got != "polish,formal,summarize,translate" {
+		t.Errorf("default templates = %s", got)
+	}
+}
+
+func TestSwitchProject(t *testing.T) {
+	server := fakeOpenCodeServer(t)
+	config := services.DefaultAppConfig()
+	config.ServerURL = server.URL
+	config.Server.Disabled = true
+	a := newTestApp(t, config)
+
+	var mu sync.Mutex
+	var switched []string
+	a.eventEmitter = func(event *models.Event) {
+		if event.Type == "project.switched" {
+			mu.Lock()
+			switched = append(switched, event.Properties["serverURL"].(string))
+			mu.Unlock()
+		}
+	}
+
+	project, err := a.AddProject(t.TempDir())
+	if err != nil {
+		t.Fatalf("AddProject: %v", err)
+	}
+	if err := a.SwitchProject(project.ID); err != nil {
+		t.Fatalf("SwitchProject: %v", err)
+	}
+
+	saved, err := a.projectService.Active()
+	if err != nil || saved == nil || saved.ID != project.ID || saved.Port == 0 {
+		t.Fatalf("saved active project = %+v, %v", saved, err)
+	}
+	active, _ := a.GetActiveProject()
+	if active == nil || active.ID != project.ID {
+		t.Errorf("GetActiveProject = %+v", active)
+	}
+	if a.apiClient.URL() != saved.ServerURL() {
+		t.Errorf("API client URL = %s, want %s", a.apiClient.URL(), saved.ServerURL())
+	}
+
+	if err := a.SwitchProject(""); err != nil {
+		t.Fatalf("SwitchProject(\"\"): %v", err)
+	}
+	if saved, _ := a.projectService.Active(); saved != nil {
+		t.Errorf("saved active project after switching back = %+v", saved)
+	}
+	if active, _ := a.GetActiveProject(); active != nil {
+		t.Errorf("GetActiveProject after switching back = %+v", active)
+	}
+	if a.apiClient.URL() != server.URL {
+		t.Errorf("API client URL = %s, want %s", a.apiClient.URL(), server.URL)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(switched) != 2 || switched[0] != saved.ServerURL() || switched[1] != server.URL {
+		t.Errorf("project.switched events = %v", switched)
+	}
+}
+
+func TestSwitchProjectFailureKeepsActiveProject(t *testing.T) {
+	server := fakeOpenCodeServer(t)
+	config := services.DefaultAppConfig()
+	config.ServerURL = server.URL
+	config.Server.Disabled = true
+	a := newTestApp(t, config)
+
+	storeDir := filepath.Join(t.TempDir(), "store")
+	if err := os.MkdirAll(storeDir, 0750); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	a.projectService = services.NewProjectServiceAt(filepath.Join(storeDir, "projects.json"))
+	project, err := a.AddProject(t.TempDir())
+	if err != nil {
+		t.Fatalf("AddProject: %v", err)
+	}
+
+	if err := a.SwitchProject("unknown"); err == nil {
+		t.Error("SwitchProject accepted an unknown project")
+	}
+
+	// Allocating the project's port fails once the registry cannot be written.
+	if err := os.RemoveAll(storeDir); err != nil {
+		t.Fatalf("RemoveAll: %v", err)
+	}
+	if err := a.SwitchProject(project.ID); err == nil {
+		t.Fatal("SwitchProject succeeded without a writable registry")
+	}
+
+	if saved, _ := a.projectService.Active(); saved != nil {
+		t.Errorf("failed switch was saved: %+v", saved)
+	}
+	if active, _ := a.GetActiveProject(); active != nil {
+		t.Errorf("failed switch changed the active project: %+v", active)
+	}
+	if a.apiClient.URL() != server.URL {
+		t.Errorf("failed switch changed the API client URL to %s", a.apiClient.URL())
+	}
+}
@@ -1,5 +1,5 @@
 import React, { useState, useEffect } from 'react';
-import { GetAppConfig, UpdateAppConfig } from '../../../wailsjs/go/main/App';
+import { GetAppConfig, PatchAppConfig } from '../../../wailsjs/go/main/App';
 import { models } from '../../../wailsjs/go/models';
 
 interface SettingsProps {
@@ -19,9 +19,15 @@ export const Settings: React.FC<SettingsProps> = ({ onClose }) => {
 
     const handleSave = () => {
         if (config) {
-            UpdateAppConfig(config)
+            // Send only the fields edited here so the other sections of the
+            // stored config are kept.
+            const { provider, adapter, baseURL, apiKey, model, prompt } = config.llm;
+            PatchAppConfig({
+                serverURL: config.serverURL,
+                llm: { provider, adapter, baseURL, apiKey, model, prompt },
+            })
                 .then(() => {
-                    alert("Settings saved. Please restart the application for the changes to take full effect.");
+                    alert("Settings saved and applied. No restart is needed.");
                     onClose();
                 })
                 .catch(err => {
@@ -93,6 +99,19 @@ export const Settings: React.FC<SettingsProps> = ({ onClose }) => {
                                 placeholder="OpenAI API互換"
                             />
                         </div>
+                        <div className="form-group">
+                            <label htmlFor="adapter">API</label>
+                            <select
+                                id="adapter"
+                                value={config.llm?.adapter || 'openai'}
+                                onChange={(e) => handleLLMChange('adapter', e.target.value)}
+                            >
+                                <option value="openai">OpenAI compatible</option>
+                                <option value="anthropic">Anthropic</option>
+                                <option value="azure">Azure OpenAI</option>
+                                <option value="ollama">Ollama</option>
+                            </select>
+                        </div>
                         <div className="form-group">
                             <label htmlFor="baseURL">Base URL</label>
                             <input
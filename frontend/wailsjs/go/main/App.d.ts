@@ -2,50 +2,130 @@
 // This file is automatically generated. DO NOT EDIT
 import {models} from '../models';
 
+export function AddProject(arg1:string):Promise<models.Project>;
+
+export function ApplyDiffHunks(arg1:models.TextDiff,arg2:Array<number>):Promise<string>;
+
+export function CancelPolish(arg1:string):Promise<void>;
+
+export function ClearBudgetOverride(arg1:string):Promise<void>;
+
 export function CreateSession(arg1:string):Promise<models.Session>;
 
 export function DeleteSession(arg1:string):Promise<void>;
 
+export function EvaluatePermissionPolicy(arg1:models.PermissionRequest):Promise<models.PermissionDecision>;
+
+export function ExportSession(arg1:string,arg2:string,arg3:string):Promise<void>;
+
+export function ExportSessionWithOptions(arg1:string,arg2:string,arg3:models.ExportOptions):Promise<void>;
+
+export function ExportUsageCSV(arg1:models.UsageRange,arg2:string,arg3:string):Promise<void>;
+
 export function FindFiles(arg1:string):Promise<Array<string>>;
 
 export function FindInFiles(arg1:string):Promise<Array<models.SearchResult>>;
 
 export function FindSymbols(arg1:string):Promise<Array<models.Symbol>>;
 
+export function GetActiveProject():Promise<models.Project>;
+
 export function GetAgents():Promise<Array<models.Agent>>;
 
 export function GetAppConfig():Promise<models.AppConfig>;
 
+export function GetBudgetStatus(arg1:string):Promise<models.BudgetStatus>;
+
+export function GetCompactionHistory(arg1:string):Promise<Array<models.CompactionRecord>>;
+
 export function GetConfig():Promise<models.ServerConfig>;
 
+export function GetConfigOverrides():Promise<Array<models.ConfigOverride>>;
+
+export function GetDiagnostics():Promise<models.Diagnostics>;
+
+export function GetDiagnosticsBundle():Promise<string>;
+
+export function GetHookLog(arg1:number):Promise<Array<models.HookRun>>;
+
 export function GetMessages(arg1:string):Promise<Array<models.MessageWithParts>>;
 
+export function GetPermissionAudit(arg1:models.PermissionAuditFilter):Promise<Array<models.PermissionAuditEntry>>;
+
+export function GetProjects():Promise<Array<models.Project>>;
+
+export function GetPromptTemplates():Promise<Array<models.PromptTemplate>>;
+
 export function GetProviders():Promise<models.ProvidersResponse>;
 
+export function GetRecentProjects(arg1:number):Promise<Array<models.Project>>;
+
+export function GetServerLogs(arg1:number):Promise<Array<models.ServerLogLine>>;
+
+export function GetServerStatus():Promise<models.ServerStatus>;
+
 export function GetSession(arg1:string):Promise<models.Session>;
 
 export function GetSessionTokens(arg1:string):Promise<models.SessionTokens>;
 
 export function GetSessions():Promise<Array<models.Session>>;
 
+export function GetTranslations(arg1:string):Promise<Array<models.TranslationEntry>>;
+
+export function GetUsageReport(arg1:models.UsageRange,arg2:string):Promise<models.UsageReport>;
+
+export function ImportSession(arg1:string,arg2:string):Promise<models.Session>;
+
+export function OverrideBudget(arg1:string):Promise<void>;
+
+export function PatchAppConfig(arg1:Record<string, any>):Promise<void>;
+
 export function PolishText(arg1:string):Promise<string>;
 
+export function PolishTextStream(arg1:string,arg2:string):Promise<string>;
+
+export function PolishTextWithDiff(arg1:string,arg2:string):Promise<models.TextDiff>;
+
 export function ReadFile(arg1:string):Promise<models.FileContent>;
 
+export function RebuildSearchIndex():Promise<void>;
+
+export function RemoveProject(arg1:string):Promise<void>;
+
 export function RespondPermission(arg1:string,arg2:string,arg3:string):Promise<void>;
 
+export function RestartServer():Promise<void>;
+
+export function SearchMessages(arg1:string,arg2:models.MessageSearchFilters):Promise<Array<models.MessageSearchResult>>;
+
 export function SendMessage(arg1:string,arg2:models.ChatInput):Promise<models.MessageWithParts>;
 
+export function SendMessageAsync(arg1:string,arg2:models.ChatInput):Promise<string>;
+
 export function SendTUIControlResponse(arg1:any):Promise<void>;
 
+export function StartServer():Promise<void>;
+
 export function StopMessage(arg1:string):Promise<void>;
 
+export function StopServer():Promise<void>;
+
 export function SummarizeSession(arg1:string,arg2:string,arg3:string):Promise<void>;
 
 export function SummarizeSessionTitle(arg1:string):Promise<string>;
 
+export function SwitchProject(arg1:string):Promise<void>;
+
+export function TransformText(arg1:string,arg2:string):Promise<string>;
+
+export function TransformTextWithVariables(arg1:models.TransformTextRequest):Promise<string>;
+
+export function TranslateMessagePart(arg1:string,arg2:string,arg3:string):Promise<models.TranslationEntry>;
+
 export function UpdateAppConfig(arg1:models.AppConfig):Promise<void>;
 
 export function UpdateConfigModel(arg1:string):Promise<void>;
 
 export function UpdateSession(arg1:string,arg2:string):Promise<models.Session>;
+
+export function ValidateAppConfig(arg1:models.AppConfig):Promise<Array<models.ConfigFieldError>>;
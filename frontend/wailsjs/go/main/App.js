@@ -2,6 +2,22 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function AddProject(arg1) {
+  return window['go']['main']['App']['AddProject'](arg1);
+}
+
+export function ApplyDiffHunks(arg1, arg2) {
+  return window['go']['main']['App']['ApplyDiffHunks'](arg1, arg2);
+}
+
+export function CancelPolish(arg1) {
+  return window['go']['main']['App']['CancelPolish'](arg1);
+}
+
+export function ClearBudgetOverride(arg1) {
+  return window['go']['main']['App']['ClearBudgetOverride'](arg1);
+}
+
 export function CreateSession(arg1) {
   return window['go']['main']['App']['CreateSession'](arg1);
 }
@@ -10,6 +26,22 @@ export function DeleteSession(arg1) {
   return window['go']['main']['App']['DeleteSession'](arg1);
 }
 
+export function EvaluatePermissionPolicy(arg1) {
+  return window['go']['main']['App']['EvaluatePermissionPolicy'](arg1);
+}
+
+export function ExportSession(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ExportSession'](arg1, arg2, arg3);
+}
+
+export function ExportSessionWithOptions(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ExportSessionWithOptions'](arg1, arg2, arg3);
+}
+
+export function ExportUsageCSV(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ExportUsageCSV'](arg1, arg2, arg3);
+}
+
 export function FindFiles(arg1) {
   return window['go']['main']['App']['FindFiles'](arg1);
 }
@@ -22,6 +54,10 @@ export function FindSymbols(arg1) {
   return window['go']['main']['App']['FindSymbols'](arg1);
 }
 
+export function GetActiveProject() {
+  return window['go']['main']['App']['GetActiveProject']();
+}
+
 export function GetAgents() {
   return window['go']['main']['App']['GetAgents']();
 }
@@ -30,18 +66,66 @@ export function GetAppConfig() {
   return window['go']['main']['App']['GetAppConfig']();
 }
 
+export function GetBudgetStatus(arg1) {
+  return window['go']['main']['App']['GetBudgetStatus'](arg1);
+}
+
+export function GetCompactionHistory(arg1) {
+  return window['go']['main']['App']['GetCompactionHistory'](arg1);
+}
+
 export function GetConfig() {
   return window['go']['main']['App']['GetConfig']();
 }
 
+export function GetConfigOverrides() {
+  return window['go']['main']['App']['GetConfigOverrides']();
+}
+
+export function GetDiagnostics() {
+  return window['go']['main']['App']['GetDiagnostics']();
+}
+
+export function GetDiagnosticsBundle() {
+  return window['go']['main']['App']['GetDiagnosticsBundle']();
+}
+
+export function GetHookLog(arg1) {
+  return window['go']['main']['App']['GetHookLog'](arg1);
+}
+
 export function GetMessages(arg1) {
   return window['go']['main']['App']['GetMessages'](arg1);
 }
 
+export function GetPermissionAudit(arg1) {
+  return window['go']['main']['App']['GetPermissionAudit'](arg1);
+}
+
+export function GetProjects() {
+  return window['go']['main']['App']['GetProjects']();
+}
+
+export function GetPromptTemplates() {
+  return window['go']['main']['App']['GetPromptTemplates']();
+}
+
 export function GetProviders() {
   return window['go']['main']['App']['GetProviders']();
 }
 
+export function GetRecentProjects(arg1) {
+  return window['go']['main']['App']['GetRecentProjects'](arg1);
+}
+
+export function GetServerLogs(arg1) {
+  return window['go']['main']['App']['GetServerLogs'](arg1);
+}
+
+export function GetServerStatus() {
+  return window['go']['main']['App']['GetServerStatus']();
+}
+
 export function GetSession(arg1) {
   return window['go']['main']['App']['GetSession'](arg1);
 }
@@ -54,30 +138,86 @@ export function GetSessions() {
   return window['go']['main']['App']['GetSessions']();
 }
 
+export function GetTranslations(arg1) {
+  return window['go']['main']['App']['GetTranslations'](arg1);
+}
+
+export function GetUsageReport(arg1, arg2) {
+  return window['go']['main']['App']['GetUsageReport'](arg1, arg2);
+}
+
+export function ImportSession(arg1, arg2) {
+  return window['go']['main']['App']['ImportSession'](arg1, arg2);
+}
+
+export function OverrideBudget(arg1) {
+  return window['go']['main']['App']['OverrideBudget'](arg1);
+}
+
+export function PatchAppConfig(arg1) {
+  return window['go']['main']['App']['PatchAppConfig'](arg1);
+}
+
 export function PolishText(arg1) {
   return window['go']['main']['App']['PolishText'](arg1);
 }
 
+export function PolishTextStream(arg1, arg2) {
+  return window['go']['main']['App']['PolishTextStream'](arg1, arg2);
+}
+
+export function PolishTextWithDiff(arg1, arg2) {
+  return window['go']['main']['App']['PolishTextWithDiff'](arg1, arg2);
+}
+
 export function ReadFile(arg1) {
   return window['go']['main']['App']['ReadFile'](arg1);
 }
 
+export function RebuildSearchIndex() {
+  return window['go']['main']['App']['RebuildSearchIndex']();
+}
+
+export function RemoveProject(arg1) {
+  return window['go']['main']['App']['RemoveProject'](arg1);
+}
+
 export function RespondPermission(arg1, arg2, arg3) {
   return window['go']['main']['App']['RespondPermission'](arg1, arg2, arg3);
 }
 
+export function RestartServer() {
+  return window['go']['main']['App']['RestartServer']();
+}
+
+export function SearchMessages(arg1, arg2) {
+  return window['go']['main']['App']['SearchMessages'](arg1, arg2);
+}
+
 export function SendMessage(arg1, arg2) {
   return window['go']['main']['App']['SendMessage'](arg1, arg2);
 }
 
+export function SendMessageAsync(arg1, arg2) {
+  return window['go']['main']['App']['SendMessageAsync'](arg1, arg2);
+}
+
 export function SendTUIControlResponse(arg1) {
   return window['go']['main']['App']['SendTUIControlResponse'](arg1);
 }
 
+export function StartServer() {
+  return window['go']['main']['App']['StartServer']();
+}
+
 export function StopMessage(arg1) {
   return window['go']['main']['App']['StopMessage'](arg1);
 }
 
+export function StopServer() {
+  return window['go']['main']['App']['StopServer']();
+}
+
 export function SummarizeSession(arg1, arg2, arg3) {
   return window['go']['main']['App']['SummarizeSession'](arg1, arg2, arg3);
 }
@@ -86,6 +226,22 @@ export function SummarizeSessionTitle(arg1) {
   return window['go']['main']['App']['SummarizeSessionTitle'](arg1);
 }
 
+export function SwitchProject(arg1) {
+  return window['go']['main']['App']['SwitchProject'](arg1);
+}
+
+export function TransformText(arg1, arg2) {
+  return window['go']['main']['App']['TransformText'](arg1, arg2);
+}
+
+export function TransformTextWithVariables(arg1) {
+  return window['go']['main']['App']['TransformTextWithVariables'](arg1);
+}
+
+export function TranslateMessagePart(arg1, arg2, arg3) {
+  return window['go']['main']['App']['TranslateMessagePart'](arg1, arg2, arg3);
+}
+
 export function UpdateAppConfig(arg1) {
   return window['go']['main']['App']['UpdateAppConfig'](arg1);
 }
@@ -97,3 +253,7 @@ export function UpdateConfigModel(arg1) {
 export function UpdateSession(arg1, arg2) {
   return window['go']['main']['App']['UpdateSession'](arg1, arg2);
 }
+
+export function ValidateAppConfig(arg1) {
+  return window['go']['main']['App']['ValidateAppConfig'](arg1);
+}
@@ -32,12 +32,199 @@ export namespace models {
 	        this.tools = source["tools"];
 	    }
 	}
+	export class TranslationConfig {
+	    enabled?: boolean;
+	    targetLanguage?: string;
+	    sourceLanguage?: string;
+	    model?: string;
+	    prompt?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new TranslationConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.targetLanguage = source["targetLanguage"];
+	        this.sourceLanguage = source["sourceLanguage"];
+	        this.model = source["model"];
+	        this.prompt = source["prompt"];
+	    }
+	}
+	export class PromptTemplate {
+	    name: string;
+	    prompt: string;
+	    model?: string;
+	    variables?: Record<string, string>;
+	
+	    static createFrom(source: any = {}) {
+	        return new PromptTemplate(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.prompt = source["prompt"];
+	        this.model = source["model"];
+	        this.variables = source["variables"];
+	    }
+	}
+	export class HookConfig {
+	    name?: string;
+	    event: string;
+	    sessionID?: string;
+	    tool?: string;
+	    status?: string;
+	    command: string;
+	    timeout?: number;
+	    disabled?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new HookConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.event = source["event"];
+	        this.sessionID = source["sessionID"];
+	        this.tool = source["tool"];
+	        this.status = source["status"];
+	        this.command = source["command"];
+	        this.timeout = source["timeout"];
+	        this.disabled = source["disabled"];
+	    }
+	}
+	export class HooksConfig {
+	    maxConcurrent?: number;
+	    hooks?: HookConfig[];
+	
+	    static createFrom(source: any = {}) {
+	        return new HooksConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.maxConcurrent = source["maxConcurrent"];
+	        this.hooks = this.convertValues(source["hooks"], HookConfig);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class PermissionRule {
+	    name?: string;
+	    tool?: string;
+	    command?: string;
+	    path?: string;
+	    sessionID?: string;
+	    action: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new PermissionRule(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.tool = source["tool"];
+	        this.command = source["command"];
+	        this.path = source["path"];
+	        this.sessionID = source["sessionID"];
+	        this.action = source["action"];
+	    }
+	}
+	export class PermissionPolicy {
+	    enabled?: boolean;
+	    dryRun?: boolean;
+	    rules?: PermissionRule[];
+	
+	    static createFrom(source: any = {}) {
+	        return new PermissionPolicy(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.dryRun = source["dryRun"];
+	        this.rules = this.convertValues(source["rules"], PermissionRule);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class CompactionConfig {
+	    enabled?: boolean;
+	    threshold?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new CompactionConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.threshold = source["threshold"];
+	    }
+	}
+	export class BudgetConfig {
+	    sessionCost?: number;
+	    sessionTokens?: number;
+	    dailyCost?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new BudgetConfig(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.sessionCost = source["sessionCost"];
+	        this.sessionTokens = source["sessionTokens"];
+	        this.dailyCost = source["dailyCost"];
+	    }
+	}
 	export class LLMConfig {
 	    provider?: string;
+	    adapter?: string;
 	    baseURL?: string;
 	    apiKey?: string;
 	    model?: string;
 	    prompt?: string;
+	    apiVersion?: string;
+	    timeout?: number;
+	    maxRetries?: number;
+	    maxTokens?: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new LLMConfig(source);
@@ -46,15 +233,56 @@ export namespace models {
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.provider = source["provider"];
+	        this.adapter = source["adapter"];
 	        this.baseURL = source["baseURL"];
 	        this.apiKey = source["apiKey"];
 	        this.model = source["model"];
 	        this.prompt = source["prompt"];
+	        this.apiVersion = source["apiVersion"];
+	        this.timeout = source["timeout"];
+	        this.maxRetries = source["maxRetries"];
+	        this.maxTokens = source["maxTokens"];
+	    }
+	}
+	export class ServerProcess {
+	    disabled?: boolean;
+	    binary?: string;
+	    args?: string[];
+	    env?: Record<string, string>;
+	    dir?: string;
+	    readyTimeout?: number;
+	    logFile?: string;
+	    logMaxSize?: number;
+	    logMaxFiles?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ServerProcess(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.disabled = source["disabled"];
+	        this.binary = source["binary"];
+	        this.args = source["args"];
+	        this.env = source["env"];
+	        this.dir = source["dir"];
+	        this.readyTimeout = source["readyTimeout"];
+	        this.logFile = source["logFile"];
+	        this.logMaxSize = source["logMaxSize"];
+	        this.logMaxFiles = source["logMaxFiles"];
 	    }
 	}
 	export class AppConfig {
+	    version: number;
 	    serverURL: string;
+	    server?: ServerProcess;
 	    llm?: LLMConfig;
+	    budget?: BudgetConfig;
+	    compaction?: CompactionConfig;
+	    permission?: PermissionPolicy;
+	    hooks?: HooksConfig;
+	    templates?: PromptTemplate[];
+	    translation?: TranslationConfig;
 	
 	    static createFrom(source: any = {}) {
 	        return new AppConfig(source);
@@ -62,8 +290,57 @@ export namespace models {
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.version = source["version"];
 	        this.serverURL = source["serverURL"];
+	        this.server = this.convertValues(source["server"], ServerProcess);
 	        this.llm = this.convertValues(source["llm"], LLMConfig);
+	        this.budget = this.convertValues(source["budget"], BudgetConfig);
+	        this.compaction = this.convertValues(source["compaction"], CompactionConfig);
+	        this.permission = this.convertValues(source["permission"], PermissionPolicy);
+	        this.hooks = this.convertValues(source["hooks"], HooksConfig);
+	        this.templates = this.convertValues(source["templates"], PromptTemplate);
+	        this.translation = this.convertValues(source["translation"], TranslationConfig);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class BudgetStatus {
+	    sessionID: string;
+	    sessionCost: number;
+	    sessionTokens: number;
+	    dailyCost: number;
+	    overridden: boolean;
+	    budget: BudgetConfig;
+	
+	    static createFrom(source: any = {}) {
+	        return new BudgetStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.sessionID = source["sessionID"];
+	        this.sessionCost = source["sessionCost"];
+	        this.sessionTokens = source["sessionTokens"];
+	        this.dailyCost = source["dailyCost"];
+	        this.overridden = source["overridden"];
+	        this.budget = this.convertValues(source["budget"], BudgetConfig);
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -89,43 +366,483 @@ export namespace models {
 	    modelID: string;
 	
 	    static createFrom(source: any = {}) {
-	        return new ModelSelection(source);
+	        return new ModelSelection(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.providerID = source["providerID"];
+	        this.modelID = source["modelID"];
+	    }
+	}
+	export class TextInputPart {
+	    type: string;
+	    text: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new TextInputPart(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.type = source["type"];
+	        this.text = source["text"];
+	    }
+	}
+	export class ChatInput {
+	    parts: TextInputPart[];
+	    model?: ModelSelection;
+	    agent?: string;
+	    messageID?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ChatInput(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.parts = this.convertValues(source["parts"], TextInputPart);
+	        this.model = this.convertValues(source["model"], ModelSelection);
+	        this.agent = source["agent"];
+	        this.messageID = source["messageID"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class CompactionRecord {
+	    sessionID: string;
+	    providerID: string;
+	    modelID: string;
+	    percentage: number;
+	    threshold: number;
+	    time: number;
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new CompactionRecord(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.sessionID = source["sessionID"];
+	        this.providerID = source["providerID"];
+	        this.modelID = source["modelID"];
+	        this.percentage = source["percentage"];
+	        this.threshold = source["threshold"];
+	        this.time = source["time"];
+	        this.error = source["error"];
+	    }
+	}
+	export class ConfigFieldError {
+	    field: string;
+	    message: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ConfigFieldError(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.field = source["field"];
+	        this.message = source["message"];
+	    }
+	}
+	export class ConfigOverride {
+	    field: string;
+	    value: string;
+	    source: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ConfigOverride(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.field = source["field"];
+	        this.value = source["value"];
+	        this.source = source["source"];
+	    }
+	}
+	export class DefaultProvider {
+	    id: string;
+	    model: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new DefaultProvider(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.model = source["model"];
+	    }
+	}
+	export class DiagnosticCheck {
+	    name: string;
+	    status: string;
+	    message: string;
+	    latencyMs?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DiagnosticCheck(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.status = source["status"];
+	        this.message = source["message"];
+	        this.latencyMs = source["latencyMs"];
+	    }
+	}
+	export class StreamStatus {
+	    state: string;
+	    url: string;
+	    connectedAt?: number;
+	    lastEventAt?: number;
+	    reconnects: number;
+	    lastError?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new StreamStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.state = source["state"];
+	        this.url = source["url"];
+	        this.connectedAt = source["connectedAt"];
+	        this.lastEventAt = source["lastEventAt"];
+	        this.reconnects = source["reconnects"];
+	        this.lastError = source["lastError"];
+	    }
+	}
+	export class ServerStatus {
+	    state: string;
+	    url: string;
+	    managed: boolean;
+	    pid?: number;
+	    startedAt?: number;
+	    restarts: number;
+	    lastError?: string;
+	    exitCode?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ServerStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.state = source["state"];
+	        this.url = source["url"];
+	        this.managed = source["managed"];
+	        this.pid = source["pid"];
+	        this.startedAt = source["startedAt"];
+	        this.restarts = source["restarts"];
+	        this.lastError = source["lastError"];
+	        this.exitCode = source["exitCode"];
+	    }
+	}
+	export class Project {
+	    id: string;
+	    name: string;
+	    path: string;
+	    port?: number;
+	    addedAt: number;
+	    lastOpened?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Project(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.path = source["path"];
+	        this.port = source["port"];
+	        this.addedAt = source["addedAt"];
+	        this.lastOpened = source["lastOpened"];
+	    }
+	}
+	export class Diagnostics {
+	    time: number;
+	    serverURL: string;
+	    project?: Project;
+	    openCodeVersion?: string;
+	    checks: DiagnosticCheck[];
+	    server: ServerStatus;
+	    stream: StreamStatus;
+	
+	    static createFrom(source: any = {}) {
+	        return new Diagnostics(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.time = source["time"];
+	        this.serverURL = source["serverURL"];
+	        this.project = this.convertValues(source["project"], Project);
+	        this.openCodeVersion = source["openCodeVersion"];
+	        this.checks = this.convertValues(source["checks"], DiagnosticCheck);
+	        this.server = this.convertValues(source["server"], ServerStatus);
+	        this.stream = this.convertValues(source["stream"], StreamStatus);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class DiffHunk {
+	    index: number;
+	    original: string;
+	    replacement: string;
+	    offset: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DiffHunk(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.index = source["index"];
+	        this.original = source["original"];
+	        this.replacement = source["replacement"];
+	        this.offset = source["offset"];
+	    }
+	}
+	export class DiffSpan {
+	    op: string;
+	    text: string;
+	    hunk: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DiffSpan(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.op = source["op"];
+	        this.text = source["text"];
+	        this.hunk = source["hunk"];
+	    }
+	}
+	export class ExportOptions {
+	    format: string;
+	    hideReasoning?: boolean;
+	    hideToolOutputs?: boolean;
+	    redactPaths?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new ExportOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.format = source["format"];
+	        this.hideReasoning = source["hideReasoning"];
+	        this.hideToolOutputs = source["hideToolOutputs"];
+	        this.redactPaths = source["redactPaths"];
+	    }
+	}
+	export class FileContent {
+	    content: string;
+	    diff?: string;
+	    patch?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new FileContent(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.content = source["content"];
+	        this.diff = source["diff"];
+	        this.patch = source["patch"];
+	    }
+	}
+	export class HighlightSpan {
+	    start: number;
+	    end: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new HighlightSpan(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.start = source["start"];
+	        this.end = source["end"];
+	    }
+	}
+	
+	export class HookRun {
+	    hook: string;
+	    event: string;
+	    sessionID?: string;
+	    command: string;
+	    started: number;
+	    duration: number;
+	    exitCode: number;
+	    output: string;
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new HookRun(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.hook = source["hook"];
+	        this.event = source["event"];
+	        this.sessionID = source["sessionID"];
+	        this.command = source["command"];
+	        this.started = source["started"];
+	        this.duration = source["duration"];
+	        this.exitCode = source["exitCode"];
+	        this.output = source["output"];
+	        this.error = source["error"];
+	    }
+	}
+	
+	
+	export class MessageSearchFilters {
+	    sessionIDs?: string[];
+	    role?: string;
+	    partTypes?: string[];
+	    limit?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new MessageSearchFilters(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.sessionIDs = source["sessionIDs"];
+	        this.role = source["role"];
+	        this.partTypes = source["partTypes"];
+	        this.limit = source["limit"];
+	    }
+	}
+	export class MessageSearchResult {
+	    sessionID: string;
+	    sessionTitle?: string;
+	    messageID: string;
+	    partID: string;
+	    role?: string;
+	    partType: string;
+	    snippet: string;
+	    highlights: HighlightSpan[];
+	    score: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new MessageSearchResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.sessionID = source["sessionID"];
+	        this.sessionTitle = source["sessionTitle"];
+	        this.messageID = source["messageID"];
+	        this.partID = source["partID"];
+	        this.role = source["role"];
+	        this.partType = source["partType"];
+	        this.snippet = source["snippet"];
+	        this.highlights = this.convertValues(source["highlights"], HighlightSpan);
+	        this.score = source["score"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class MessageWithParts {
+	    info: any;
+	    parts: any[];
+	
+	    static createFrom(source: any = {}) {
+	        return new MessageWithParts(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.providerID = source["providerID"];
-	        this.modelID = source["modelID"];
+	        this.info = source["info"];
+	        this.parts = source["parts"];
 	    }
 	}
-	export class TextInputPart {
-	    type: string;
-	    text: string;
+	export class ModelLimit {
+	    context: number;
+	    output: number;
 	
 	    static createFrom(source: any = {}) {
-	        return new TextInputPart(source);
+	        return new ModelLimit(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.type = source["type"];
-	        this.text = source["text"];
+	        this.context = source["context"];
+	        this.output = source["output"];
 	    }
 	}
-	export class ChatInput {
-	    parts: TextInputPart[];
-	    model?: ModelSelection;
-	    agent?: string;
+	export class Model {
+	    id: string;
+	    name: string;
+	    limit: ModelLimit;
 	
 	    static createFrom(source: any = {}) {
-	        return new ChatInput(source);
+	        return new Model(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.parts = this.convertValues(source["parts"], TextInputPart);
-	        this.model = this.convertValues(source["model"], ModelSelection);
-	        this.agent = source["agent"];
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.limit = this.convertValues(source["limit"], ModelLimit);
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -146,79 +863,129 @@ export namespace models {
 		    return a;
 		}
 	}
-	export class DefaultProvider {
-	    id: string;
-	    model: string;
 	
-	    static createFrom(source: any = {}) {
-	        return new DefaultProvider(source);
-	    }
 	
-	    constructor(source: any = {}) {
-	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.id = source["id"];
-	        this.model = source["model"];
-	    }
-	}
-	export class FileContent {
-	    content: string;
-	    diff?: string;
-	    patch?: string;
+	export class PermissionAuditEntry {
+	    time: number;
+	    sessionID: string;
+	    permissionID: string;
+	    type?: string;
+	    title?: string;
+	    patterns?: string[];
+	    command?: string;
+	    path?: string;
+	    responder: string;
+	    rule?: string;
+	    response: string;
+	    error?: string;
 	
 	    static createFrom(source: any = {}) {
-	        return new FileContent(source);
+	        return new PermissionAuditEntry(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.content = source["content"];
-	        this.diff = source["diff"];
-	        this.patch = source["patch"];
+	        this.time = source["time"];
+	        this.sessionID = source["sessionID"];
+	        this.permissionID = source["permissionID"];
+	        this.type = source["type"];
+	        this.title = source["title"];
+	        this.patterns = source["patterns"];
+	        this.command = source["command"];
+	        this.path = source["path"];
+	        this.responder = source["responder"];
+	        this.rule = source["rule"];
+	        this.response = source["response"];
+	        this.error = source["error"];
 	    }
 	}
-	
-	export class MessageWithParts {
-	    info: any;
-	    parts: any[];
+	export class PermissionAuditFilter {
+	    sessionID?: string;
+	    responder?: string;
+	    response?: string;
+	    since?: number;
+	    until?: number;
+	    limit?: number;
 	
 	    static createFrom(source: any = {}) {
-	        return new MessageWithParts(source);
+	        return new PermissionAuditFilter(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.info = source["info"];
-	        this.parts = source["parts"];
+	        this.sessionID = source["sessionID"];
+	        this.responder = source["responder"];
+	        this.response = source["response"];
+	        this.since = source["since"];
+	        this.until = source["until"];
+	        this.limit = source["limit"];
 	    }
 	}
-	export class ModelLimit {
-	    context: number;
-	    output: number;
+	export class PermissionRequest {
+	    id: string;
+	    type: string;
+	    pattern?: any;
+	    sessionID: string;
+	    messageID: string;
+	    callID?: string;
+	    title: string;
+	    metadata?: Record<string, any>;
+	    // Go type: struct { Created int64 "json:\"created\"" }
+	    time: any;
 	
 	    static createFrom(source: any = {}) {
-	        return new ModelLimit(source);
+	        return new PermissionRequest(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.context = source["context"];
-	        this.output = source["output"];
+	        this.id = source["id"];
+	        this.type = source["type"];
+	        this.pattern = source["pattern"];
+	        this.sessionID = source["sessionID"];
+	        this.messageID = source["messageID"];
+	        this.callID = source["callID"];
+	        this.title = source["title"];
+	        this.metadata = source["metadata"];
+	        this.time = this.convertValues(source["time"], Object);
 	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
-	export class Model {
-	    id: string;
-	    name: string;
-	    limit: ModelLimit;
+	export class PermissionDecision {
+	    request?: PermissionRequest;
+	    rule?: string;
+	    action: string;
+	    dryRun: boolean;
+	    error?: string;
 	
 	    static createFrom(source: any = {}) {
-	        return new Model(source);
+	        return new PermissionDecision(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.id = source["id"];
-	        this.name = source["name"];
-	        this.limit = this.convertValues(source["limit"], ModelLimit);
+	        this.request = this.convertValues(source["request"], PermissionRequest);
+	        this.rule = source["rule"];
+	        this.action = source["action"];
+	        this.dryRun = source["dryRun"];
+	        this.error = source["error"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -241,6 +1008,7 @@ export namespace models {
 	}
 	
 	
+	
 	export class Position {
 	    line: number;
 	    character: number;
@@ -255,6 +1023,8 @@ export namespace models {
 	        this.character = source["character"];
 	    }
 	}
+	
+	
 	export class Provider {
 	    id: string;
 	    name: string;
@@ -451,6 +1221,24 @@ export namespace models {
 		    return a;
 		}
 	}
+	export class ServerLogLine {
+	    time: number;
+	    stream: string;
+	    text: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ServerLogLine(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.time = source["time"];
+	        this.stream = source["stream"];
+	        this.text = source["text"];
+	    }
+	}
+	
+	
 	export class Share {
 	    url: string;
 	
@@ -517,12 +1305,44 @@ export namespace models {
 		    return a;
 		}
 	}
+	export class TurnTokens {
+	    messageID: string;
+	    modelID: string;
+	    providerID: string;
+	    input: number;
+	    output: number;
+	    reasoning: number;
+	    cacheRead: number;
+	    cacheWrite: number;
+	    context: number;
+	    cost: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new TurnTokens(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.messageID = source["messageID"];
+	        this.modelID = source["modelID"];
+	        this.providerID = source["providerID"];
+	        this.input = source["input"];
+	        this.output = source["output"];
+	        this.reasoning = source["reasoning"];
+	        this.cacheRead = source["cacheRead"];
+	        this.cacheWrite = source["cacheWrite"];
+	        this.context = source["context"];
+	        this.cost = source["cost"];
+	    }
+	}
 	export class SessionTokens {
 	    used: number;
 	    max: number;
 	    percentage: number;
+	    outputLimit: number;
 	    modelID: string;
 	    providerID: string;
+	    turns: TurnTokens[];
 	
 	    static createFrom(source: any = {}) {
 	        return new SessionTokens(source);
@@ -533,11 +1353,32 @@ export namespace models {
 	        this.used = source["used"];
 	        this.max = source["max"];
 	        this.percentage = source["percentage"];
+	        this.outputLimit = source["outputLimit"];
 	        this.modelID = source["modelID"];
 	        this.providerID = source["providerID"];
+	        this.turns = this.convertValues(source["turns"], TurnTokens);
 	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 	
+	
 	export class SymbolLocation {
 	    uri: string;
 	    range: Range;
@@ -605,6 +1446,196 @@ export namespace models {
 		}
 	}
 	
+	export class TextDiff {
+	    original: string;
+	    revised: string;
+	    spans: DiffSpan[];
+	    hunks: DiffHunk[];
+	
+	    static createFrom(source: any = {}) {
+	        return new TextDiff(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.original = source["original"];
+	        this.revised = source["revised"];
+	        this.spans = this.convertValues(source["spans"], DiffSpan);
+	        this.hunks = this.convertValues(source["hunks"], DiffHunk);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	
+	export class TransformTextRequest {
+	    template: string;
+	    text: string;
+	    sessionID?: string;
+	    selection?: string;
+	    language?: string;
+	    variables?: Record<string, string>;
+	
+	    static createFrom(source: any = {}) {
+	        return new TransformTextRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.template = source["template"];
+	        this.text = source["text"];
+	        this.sessionID = source["sessionID"];
+	        this.selection = source["selection"];
+	        this.language = source["language"];
+	        this.variables = source["variables"];
+	    }
+	}
+	
+	export class TranslationEntry {
+	    sessionID: string;
+	    messageID?: string;
+	    partID?: string;
+	    direction: string;
+	    language: string;
+	    original: string;
+	    translated: string;
+	    time: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new TranslationEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.sessionID = source["sessionID"];
+	        this.messageID = source["messageID"];
+	        this.partID = source["partID"];
+	        this.direction = source["direction"];
+	        this.language = source["language"];
+	        this.original = source["original"];
+	        this.translated = source["translated"];
+	        this.time = source["time"];
+	    }
+	}
+	
+	export class UsageRange {
+	    from?: number;
+	    to?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new UsageRange(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.from = source["from"];
+	        this.to = source["to"];
+	    }
+	}
+	export class UsageTotals {
+	    messages: number;
+	    cost: number;
+	    input: number;
+	    output: number;
+	    reasoning: number;
+	    cacheRead: number;
+	    cacheWrite: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new UsageTotals(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.messages = source["messages"];
+	        this.cost = source["cost"];
+	        this.input = source["input"];
+	        this.output = source["output"];
+	        this.reasoning = source["reasoning"];
+	        this.cacheRead = source["cacheRead"];
+	        this.cacheWrite = source["cacheWrite"];
+	    }
+	}
+	export class UsageRow {
+	    key: string;
+	    label: string;
+	    messages: number;
+	    cost: number;
+	    input: number;
+	    output: number;
+	    reasoning: number;
+	    cacheRead: number;
+	    cacheWrite: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new UsageRow(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.key = source["key"];
+	        this.label = source["label"];
+	        this.messages = source["messages"];
+	        this.cost = source["cost"];
+	        this.input = source["input"];
+	        this.output = source["output"];
+	        this.reasoning = source["reasoning"];
+	        this.cacheRead = source["cacheRead"];
+	        this.cacheWrite = source["cacheWrite"];
+	    }
+	}
+	export class UsageReport {
+	    range: UsageRange;
+	    groupBy: string;
+	    rows: UsageRow[];
+	    total: UsageTotals;
+	
+	    static createFrom(source: any = {}) {
+	        return new UsageReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.range = this.convertValues(source["range"], UsageRange);
+	        this.groupBy = source["groupBy"];
+	        this.rows = this.convertValues(source["rows"], UsageRow);
+	        this.total = this.convertValues(source["total"], UsageTotals);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	
 
 }
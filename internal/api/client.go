@@ -2,33 +2,56 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"fm-opencode-tinyapp/internal/models"
+	"sync"
 	"time"
 )
 
-// Client is a client for the OpenCode API.
+// defaultReadTimeout bounds GET requests. Other requests, such as sending a
+// message, may run as long as the agent does and are only bounded by their context.
+const defaultReadTimeout = 30 * time.Second
+
+// Client is a client for the OpenCode API. Every request is bound to the
+// context passed to the method.
 type Client struct {
-	BaseURL    string
-	HTTPClient *http.Client
+	BaseURL     string // Use SetBaseURL to change it while the client is in use
+	HTTPClient  *http.Client
+	ReadTimeout time.Duration // Timeout of GET requests; 0 disables it
+	mu          sync.RWMutex
 }
 
 // NewClient creates a new OpenCode API client.
 func NewClient(baseURL string) *Client {
 	return &Client{
-		BaseURL: baseURL,
-		HTTPClient: &http.Client{
-			Timeout: time.Minute,
-		},
+		BaseURL:     baseURL,
+		HTTPClient:  &http.Client{},
+		ReadTimeout: defaultReadTimeout,
 	}
 }
 
-// doRequest is a helper function to make HTTP requests.
-func (c *Client) doRequest(method, path string, query url.Values, body interface{}) (*http.Response, error) {
+// URL returns the server URL the client talks to.
+func (c *Client) URL() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.BaseURL
+}
+
+// SetBaseURL points the client at another server.
+func (c *Client) SetBaseURL(baseURL string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.BaseURL = baseURL
+}
+
+// doRequest is a helper function to make HTTP requests. GET requests are
+// additionally bounded by ReadTimeout until the response body is closed.
+func (c *Client) doRequest(ctx context.Context, method, path string, query url.Values, body interface{}) (*http.Response, error) {
 	var reqBody io.Reader
 	if body != nil {
 		jsonBody, err := json.Marshal(body)
@@ -38,25 +61,44 @@ func (c *Client) doRequest(method, path string, query url.Values, body interface
 		reqBody = bytes.NewBuffer(jsonBody)
 	}
 
-	fullURL := fmt.Sprintf("%s%s", c.BaseURL, path)
+	fullURL := fmt.Sprintf("%s%s", c.URL(), path)
 	if query != nil {
 		fullURL = fmt.Sprintf("%s?%s", fullURL, query.Encode())
 	}
 
-	req, err := http.NewRequest(method, fullURL, reqBody)
+	cancel := context.CancelFunc(func() {})
+	if method == http.MethodGet && c.ReadTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.ReadTimeout)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, fullURL, reqBody)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	res, err := c.HTTPClient.Do(req)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
+	res.Body = &cancelOnClose{ReadCloser: res.Body, cancel: cancel}
 
 	return res, nil
 }
 
+// cancelOnClose releases the request context once the body has been read.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
 // decodeResponse is a helper function to decode JSON responses.
 func decodeResponse(res *http.Response, target interface{}) error {
 	defer res.Body.Close()
@@ -69,8 +111,8 @@ func decodeResponse(res *http.Response, target interface{}) error {
 }
 
 // GetSessions fetches all sessions from the server.
-func (c *Client) GetSessions() ([]models.Session, error) {
-	res, err := c.doRequest("GET", "/session", nil, nil)
+func (c *Client) GetSessions(ctx context.Context) ([]models.Session, error) {
+	res, err := c.doRequest(ctx, "GET", "/session", nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -80,8 +122,8 @@ func (c *Client) GetSessions() ([]models.Session, error) {
 }
 
 // GetSession fetches a single session by its ID.
-func (c *Client) GetSession(id string) (*models.Session, error) {
-	res, err := c.doRequest("GET", fmt.Sprintf("/session/%s", id), nil, nil)
+func (c *Client) GetSession(ctx context.Context, id string) (*models.Session, error) {
+	res, err := c.doRequest(ctx, "GET", fmt.Sprintf("/session/%s", id), nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -91,9 +133,9 @@ func (c *Client) GetSession(id string) (*models.Session, error) {
 }
 
 // CreateSession creates a new session.
-func (c *Client) CreateSession(title string) (*models.Session, error) {
+func (c *Client) CreateSession(ctx context.Context, title string) (*models.Session, error) {
 	body := map[string]string{"title": title}
-	res, err := c.doRequest("POST", "/session", nil, body)
+	res, err := c.doRequest(ctx, "POST", "/session", nil, body)
 	if err != nil {
 		return nil, err
 	}
@@ -103,9 +145,9 @@ func (c *Client) CreateSession(title string) (*models.Session, error) {
 }
 
 // UpdateSession updates a session's title.
-func (c *Client) UpdateSession(id, title string) (*models.Session, error) {
+func (c *Client) UpdateSession(ctx context.Context, id, title string) (*models.Session, error) {
 	body := map[string]string{"title": title}
-	res, err := c.doRequest("PATCH", fmt.Sprintf("/session/%s", id), nil, body)
+	res, err := c.doRequest(ctx, "PATCH", fmt.Sprintf("/session/%s", id), nil, body)
 	if err != nil {
 		return nil, err
 	}
@@ -115,8 +157,8 @@ func (c *Client) UpdateSession(id, title string) (*models.Session, error) {
 }
 
 // DeleteSession deletes a session by its ID.
-func (c *Client) DeleteSession(id string) error {
-	res, err := c.doRequest("DELETE", fmt.Sprintf("/session/%s", id), nil, nil)
+func (c *Client) DeleteSession(ctx context.Context, id string) error {
+	res, err := c.doRequest(ctx, "DELETE", fmt.Sprintf("/session/%s", id), nil, nil)
 	if err != nil {
 		return err
 	}
@@ -128,13 +170,13 @@ func (c *Client) DeleteSession(id string) error {
 }
 
 // SummarizeSession sends a request to summarize a session (generates session title/summary).
-func (c *Client) SummarizeSession(id string, providerID string, modelID string) error {
+func (c *Client) SummarizeSession(ctx context.Context, id string, providerID string, modelID string) error {
 	body := map[string]interface{}{
 		"providerID": providerID,
 		"modelID":    modelID,
 		"auto":       false,
 	}
-	res, err := c.doRequest("POST", fmt.Sprintf("/session/%s/summarize", id), nil, body)
+	res, err := c.doRequest(ctx, "POST", fmt.Sprintf("/session/%s/summarize", id), nil, body)
 	if err != nil {
 		return err
 	}
@@ -148,8 +190,8 @@ func (c *Client) SummarizeSession(id string, providerID string, modelID string)
 
 
 // GetMessages fetches all messages for a given session.
-func (c *Client) GetMessages(sessionID string) ([]models.MessageWithParts, error) {
-	res, err := c.doRequest("GET", fmt.Sprintf("/session/%s/message", sessionID), nil, nil)
+func (c *Client) GetMessages(ctx context.Context, sessionID string) ([]models.MessageWithParts, error) {
+	res, err := c.doRequest(ctx, "GET", fmt.Sprintf("/session/%s/message", sessionID), nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -159,8 +201,8 @@ func (c *Client) GetMessages(sessionID string) ([]models.MessageWithParts, error
 }
 
 // SendMessage sends a new message to a session.
-func (c *Client) SendMessage(sessionID string, req *models.ChatInput) (*models.MessageWithParts, error) {
-	res, err := c.doRequest("POST", fmt.Sprintf("/session/%s/message", sessionID), nil, req)
+func (c *Client) SendMessage(ctx context.Context, sessionID string, req *models.ChatInput) (*models.MessageWithParts, error) {
+	res, err := c.doRequest(ctx, "POST", fmt.Sprintf("/session/%s/message", sessionID), nil, req)
 	if err != nil {
 		return nil, err
 	}
@@ -169,9 +211,24 @@ func (c *Client) SendMessage(sessionID string, req *models.ChatInput) (*models.M
 	return &message, err
 }
 
+// SendMessageAsync sends a new message to a session without waiting for the
+// agent. Progress and completion are reported by the event stream.
+func (c *Client) SendMessageAsync(ctx context.Context, sessionID string, req *models.ChatInput) error {
+	res, err := c.doRequest(ctx, "POST", fmt.Sprintf("/session/%s/prompt_async", sessionID), nil, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("unexpected status code: %d, response body: %s", res.StatusCode, string(respBody))
+	}
+	return nil
+}
+
 // GetAgents fetches the list of available agents.
-func (c *Client) GetAgents() ([]models.Agent, error) {
-	res, err := c.doRequest("GET", "/agent", nil, nil)
+func (c *Client) GetAgents(ctx context.Context) ([]models.Agent, error) {
+	res, err := c.doRequest(ctx, "GET", "/agent", nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -181,8 +238,8 @@ func (c *Client) GetAgents() ([]models.Agent, error) {
 }
 
 // GetConfig fetches the server configuration.
-func (c *Client) GetConfig() (*models.ServerConfig, error) {
-	res, err := c.doRequest("GET", "/config", nil, nil)
+func (c *Client) GetConfig(ctx context.Context) (*models.ServerConfig, error) {
+	res, err := c.doRequest(ctx, "GET", "/config", nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -192,9 +249,9 @@ func (c *Client) GetConfig() (*models.ServerConfig, error) {
 }
 
 // UpdateConfigModel updates the default model in server configuration.
-func (c *Client) UpdateConfigModel(model string) error {
+func (c *Client) UpdateConfigModel(ctx context.Context, model string) error {
 	body := map[string]string{"model": model}
-	res, err := c.doRequest("PATCH", "/global/config", nil, body)
+	res, err := c.doRequest(ctx, "PATCH", "/global/config", nil, body)
 	if err != nil {
 		return err
 	}
@@ -207,10 +264,10 @@ func (c *Client) UpdateConfigModel(model string) error {
 }
 
 // FindInFiles searches for a pattern in files.
-func (c *Client) FindInFiles(pattern string) ([]models.SearchResult, error) {
+func (c *Client) FindInFiles(ctx context.Context, pattern string) ([]models.SearchResult, error) {
 	query := url.Values{}
 	query.Add("pattern", pattern)
-	res, err := c.doRequest("GET", "/find", query, nil)
+	res, err := c.doRequest(ctx, "GET", "/find", query, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -220,10 +277,10 @@ func (c *Client) FindInFiles(pattern string) ([]models.SearchResult, error) {
 }
 
 // FindFiles finds files by a query.
-func (c *Client) FindFiles(queryStr string) ([]string, error) {
+func (c *Client) FindFiles(ctx context.Context, queryStr string) ([]string, error) {
 	query := url.Values{}
 	query.Add("query", queryStr)
-	res, err := c.doRequest("GET", "/find/file", query, nil)
+	res, err := c.doRequest(ctx, "GET", "/find/file", query, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -233,10 +290,10 @@ func (c *Client) FindFiles(queryStr string) ([]string, error) {
 }
 
 // FindSymbols finds symbols by a query.
-func (c *Client) FindSymbols(queryStr string) ([]models.Symbol, error) {
+func (c *Client) FindSymbols(ctx context.Context, queryStr string) ([]models.Symbol, error) {
 	query := url.Values{}
 	query.Add("query", queryStr)
-	res, err := c.doRequest("GET", "/find/symbol", query, nil)
+	res, err := c.doRequest(ctx, "GET", "/find/symbol", query, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -246,10 +303,10 @@ func (c *Client) FindSymbols(queryStr string) ([]models.Symbol, error) {
 }
 
 // ReadFile reads the content of a file.
-func (c *Client) ReadFile(path string) (*models.FileContent, error) {
+func (c *Client) ReadFile(ctx context.Context, path string) (*models.FileContent, error) {
 	query := url.Values{}
 	query.Add("path", path)
-	res, err := c.doRequest("GET", "/file/content", query, nil)
+	res, err := c.doRequest(ctx, "GET", "/file/content", query, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -259,8 +316,8 @@ func (c *Client) ReadFile(path string) (*models.FileContent, error) {
 }
 
 // GetProviders fetches the list of available providers and models.
-func (c *Client) GetProviders() (*models.ProvidersResponse, error) {
-	res, err := c.doRequest("GET", "/config/providers", nil, nil)
+func (c *Client) GetProviders(ctx context.Context) (*models.ProvidersResponse, error) {
+	res, err := c.doRequest(ctx, "GET", "/config/providers", nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -270,8 +327,8 @@ func (c *Client) GetProviders() (*models.ProvidersResponse, error) {
 }
 
 // StopMessage stops the current agent execution in a session.
-func (c *Client) StopMessage(sessionID string) error {
-	res, err := c.doRequest("POST", fmt.Sprintf("/session/%s/abort", sessionID), nil, nil)
+func (c *Client) StopMessage(ctx context.Context, sessionID string) error {
+	res, err := c.doRequest(ctx, "POST", fmt.Sprintf("/session/%s/abort", sessionID), nil, nil)
 	if err != nil {
 		return err
 	}
@@ -284,11 +341,11 @@ func (c *Client) StopMessage(sessionID string) error {
 
 // RespondPermission responds to a pending permission/question request in a session.
 // response must be one of: "once", "always", "reject".
-func (c *Client) RespondPermission(sessionID string, permissionID string, response string) error {
+func (c *Client) RespondPermission(ctx context.Context, sessionID string, permissionID string, response string) error {
 	body := map[string]string{
 		"response": response,
 	}
-	res, err := c.doRequest("POST", fmt.Sprintf("/session/%s/permissions/%s", sessionID, permissionID), nil, body)
+	res, err := c.doRequest(ctx, "POST", fmt.Sprintf("/session/%s/permissions/%s", sessionID, permissionID), nil, body)
 	if err != nil {
 		return err
 	}
@@ -301,11 +358,11 @@ func (c *Client) RespondPermission(sessionID string, permissionID string, respon
 }
 
 // SendTUIControlResponse sends a response for interactive TUI control requests.
-func (c *Client) SendTUIControlResponse(body interface{}) error {
+func (c *Client) SendTUIControlResponse(ctx context.Context, body interface{}) error {
 	reqBody := map[string]interface{}{
 		"body": body,
 	}
-	res, err := c.doRequest("POST", "/tui/control/response", nil, reqBody)
+	res, err := c.doRequest(ctx, "POST", "/tui/control/response", nil, reqBody)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"fm-opencode-tinyapp/internal/models"
+)
+
+// slowServer replies after delay unless the request is cancelled first.
+func slowServer(t *testing.T, delay time.Duration, reply string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+			w.Write([]byte(reply))
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClientCancellation(t *testing.T) {
+	client := NewClient(slowServer(t, 5*time.Second, `[]`).URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := client.GetSessions(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("GetSessions = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("cancelled request took %v", elapsed)
+	}
+}
+
+func TestClientReadTimeout(t *testing.T) {
+	server := slowServer(t, 300*time.Millisecond, `{"info": {"id": "msg_2", "role": "assistant"}, "parts": []}`)
+	client := NewClient(server.URL)
+	client.ReadTimeout = 100 * time.Millisecond
+
+	// Reads are bounded by ReadTimeout.
+	if _, err := client.GetSessions(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetSessions = %v, want context.DeadlineExceeded", err)
+	}
+
+	// Sending a message waits for the agent however long it takes.
+	if _, err := client.SendMessage(context.Background(), "ses_1", &models.ChatInput{}); err != nil {
+		t.Errorf("SendMessage: %v", err)
+	}
+}
+
+func TestClientSendMessageAsync(t *testing.T) {
+	var path string
+	var body models.ChatInput
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.Method + " " + r.URL.Path
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(status)
+		if status != http.StatusNoContent {
+			w.Write([]byte(`{"error": "busy"}`))
+		}
+	}))
+	defer server.Close()
+	client := NewClient(server.URL)
+
+	req := &models.ChatInput{
+		Parts:     []models.TextInputPart{{Type: "text", Text: "hello"}},
+		MessageID: "msg_1",
+	}
+	if err := client.SendMessageAsync(context.Background(), "ses_1", req); err != nil {
+		t.Fatalf("SendMessageAsync: %v", err)
+	}
+	if path != "POST /session/ses_1/prompt_async" {
+		t.Errorf("request = %s", path)
+	}
+	if body.MessageID != "msg_1" || len(body.Parts) != 1 || body.Parts[0].Text != "hello" {
+		t.Errorf("body = %+v", body)
+	}
+
+	status = http.StatusBadRequest
+	err := client.SendMessageAsync(context.Background(), "ses_1", req)
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("SendMessageAsync with status 400 = %v", err)
+	}
+}
+
+func TestNewMessageIDAscending(t *testing.T) {
+	ids := make([]string, 1000)
+	for i := range ids {
+		ids[i] = NewMessageID()
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Error("message IDs are not in creation order")
+	}
+	for _, id := range ids {
+		if !strings.HasPrefix(id, "msg_") || len(id) != 30 {
+			t.Fatalf("ID %q does not have the server's format", id)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const base62Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var (
+	idMu       sync.Mutex
+	lastIDTime int64
+	idCounter  int64
+)
+
+// NewMessageID returns a new ID for a user message, in the format the
+// server uses: "msg_", the creation time and a counter as 12 hex digits, and
+// 14 random base62 characters. IDs created later sort after earlier ones.
+func NewMessageID() string {
+	return newAscendingID("msg")
+}
+
+func newAscendingID(prefix string) string {
+	idMu.Lock()
+	now := time.Now().UnixMilli()
+	if now != lastIDTime {
+		lastIDTime = now
+		idCounter = 0
+	}
+	idCounter++
+	value := now*0x1000 + idCounter
+	idMu.Unlock()
+
+	timeBytes := make([]byte, 6)
+	for i := range timeBytes {
+		timeBytes[i] = byte(value >> (40 - 8*i))
+	}
+	random := make([]byte, 14)
+	rand.Read(random)
+	for i, b := range random {
+		random[i] = base62Chars[int(b)%len(base62Chars)]
+	}
+	return prefix + "_" + hex.EncodeToString(timeBytes) + string(random)
+}
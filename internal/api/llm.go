@@ -1,8 +1,9 @@
 package api
 
 import (
-	"bytes"
-	"encoding/json"
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,21 +13,39 @@ import (
 	"fm-opencode-tinyapp/internal/models"
 )
 
-// LLMClient handles LLM API requests.
+const (
+	defaultLLMTimeout    = 60 * time.Second
+	defaultLLMMaxRetries = 2
+	llmRetryBaseDelay    = 500 * time.Millisecond
+	llmRetryMaxDelay     = 30 * time.Second
+)
+
+// LLMClient handles LLM API requests. The wire format is delegated to an
+// adapter selected by LLMConfig.Adapter.
 type LLMClient struct {
-	baseURL string
-	apiKey  string
-	client  *http.Client
+	adapter    llmAdapter
+	client     *http.Client
+	timeout    time.Duration // Per attempt, for non-streamed calls
+	maxRetries int
 }
 
-// NewLLMClient creates a new LLM client.
-func NewLLMClient(baseURL, apiKey string) *LLMClient {
+// NewLLMClient creates a new LLM client for the configured provider.
+func NewLLMClient(config models.LLMConfig) *LLMClient {
+	timeout := defaultLLMTimeout
+	if config.Timeout > 0 {
+		timeout = time.Duration(config.Timeout) * time.Second
+	}
+	maxRetries := defaultLLMMaxRetries
+	if config.MaxRetries < 0 {
+		maxRetries = 0
+	} else if config.MaxRetries > 0 {
+		maxRetries = config.MaxRetries
+	}
 	return &LLMClient{
-		baseURL: baseURL,
-		apiKey:  apiKey,
-		client: &http.Client{
-			Timeout: 60 * time.Second,
-		},
+		adapter:    newLLMAdapter(config),
+		client:     &http.Client{},
+		timeout:    timeout,
+		maxRetries: maxRetries,
 	}
 }
 
@@ -34,6 +53,7 @@ func NewLLMClient(baseURL, apiKey string) *LLMClient {
 type ChatRequest struct {
 	Model    string    `json:"model"`
 	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream,omitempty"`
 }
 
 // Message represents a chat message.
@@ -52,74 +72,214 @@ type Choice struct {
 	Message Message `json:"message"`
 }
 
-// PolishText sends text to LLM for polishing.
-func (c *LLMClient) PolishText(req *models.PolishTextRequest) (*models.PolishTextResponse, error) {
+// StreamChunk represents a chunk of a streamed chat completion.
+type StreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+}
+
+// buildPolishMessages expands the prompt template for a polish request.
+func buildPolishMessages(req *models.PolishTextRequest) []Message {
 	// Replace {text} placeholder in the prompt with the actual text
 	prompt := req.Prompt
 	if prompt == "" {
 		prompt = "以下の文章をより自然で分かりやすく、丁寧な表現に修正してください。\n誤字脱字や文法的な誤りも修正してください。\n\n---\n{text}"
 	}
 
-	// Replace {text} placeholder
-	prompt = strings.ReplaceAll(prompt, "{text}", req.Text)
+	// Replace {text} and any additional placeholders in a single pass so that
+	// placeholders inside the substituted values are left untouched.
+	pairs := []string{"{text}", req.Text}
+	for name, value := range req.Variables {
+		if name != "text" {
+			pairs = append(pairs, "{"+name+"}", value)
+		}
+	}
+	prompt = strings.NewReplacer(pairs...).Replace(prompt)
 
-	// Prepare the request
-	messages := []Message{
+	return []Message{
 		{
 			Role:    "user",
 			Content: prompt,
 		},
 	}
+}
 
-	chatReq := ChatRequest{
-		Model:    req.Model,
-		Messages: messages,
-	}
+// PolishText sends text to LLM for polishing.
+func (c *LLMClient) PolishText(req *models.PolishTextRequest) (*models.PolishTextResponse, error) {
+	return c.PolishTextContext(context.Background(), req)
+}
+
+// PolishTextContext sends text to LLM for polishing. Each attempt is bounded
+// by the configured timeout and the whole call by ctx.
+func (c *LLMClient) PolishTextContext(ctx context.Context, req *models.PolishTextRequest) (*models.PolishTextResponse, error) {
+	messages := buildPolishMessages(req)
 
-	jsonData, err := json.Marshal(chatReq)
+	var body []byte
+	err := c.withRetry(ctx, func() error {
+		attemptCtx, cancel := context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+
+		// Create request
+		httpReq, err := c.adapter.newRequest(attemptCtx, req.Model, messages, false)
+		if err != nil {
+			return err
+		}
+
+		// Send request
+		resp, err := c.client.Do(httpReq)
+		if err != nil {
+			return fmt.Errorf("failed to send request: %w", err)
+		}
+		defer resp.Body.Close()
+
+		// Read response
+		body, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+
+		// Check for HTTP errors
+		if resp.StatusCode != http.StatusOK {
+			return newLLMError(resp, body)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, err
 	}
 
-	// Create request
-	httpReq, err := http.NewRequest("POST", c.baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	// Parse response
+	text, err := c.adapter.parseResponse(body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	// Set headers
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	return &models.PolishTextResponse{
+		PolishedText: text,
+	}, nil
+}
 
-	// Send request
+// Ping checks that the endpoint is reachable and accepts the credentials by
+// listing the available models. It is not retried.
+func (c *LLMClient) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	httpReq, err := c.adapter.newModelsRequest(ctx)
+	if err != nil {
+		return err
+	}
 	resp, err := c.client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
-
-	// Read response
-	body, err := io.ReadAll(resp.Body)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return fmt.Errorf("failed to read response: %w", err)
 	}
-
-	// Check for HTTP errors
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return newLLMError(resp, body)
 	}
+	return nil
+}
 
-	// Parse response
-	var chatResp ChatResponse
-	if err := json.Unmarshal(body, &chatResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+// PolishTextStream polishes text with a streamed completion, calling onDelta
+// for every content chunk as it arrives. The call stops when ctx is cancelled.
+// Only the connection is retried; a stream that fails midway is not restarted.
+func (c *LLMClient) PolishTextStream(ctx context.Context, req *models.PolishTextRequest, onDelta func(delta string)) (*models.PolishTextResponse, error) {
+	messages := buildPolishMessages(req)
+
+	var resp *http.Response
+	err := c.withRetry(ctx, func() error {
+		httpReq, err := c.adapter.newRequest(ctx, req.Model, messages, true)
+		if err != nil {
+			return err
+		}
+		httpReq.Header.Set("Accept", "text/event-stream")
+
+		resp, err = c.client.Do(httpReq)
+		if err != nil {
+			return fmt.Errorf("failed to send request: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return newLLMError(resp, body)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	if len(chatResp.Choices) == 0 {
-		return nil, fmt.Errorf("no choices in response")
+	var result strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		delta, done, err := c.adapter.parseStreamLine(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		if delta != "" {
+			result.WriteString(delta)
+			if onDelta != nil {
+				onDelta(delta)
+			}
+		}
+		if done {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
 	}
 
 	return &models.PolishTextResponse{
-		PolishedText: chatResp.Choices[0].Message.Content,
+		PolishedText: result.String(),
 	}, nil
-}
\ No newline at end of file
+}
+
+// withRetry runs attempt until it succeeds, fails with a non-retryable error
+// or the retries are exhausted. Rate limits and server errors are retried
+// with exponential backoff, honoring Retry-After; network errors are retried
+// unless ctx is done.
+func (c *LLMClient) withRetry(ctx context.Context, attempt func() error) error {
+	for i := 0; ; i++ {
+		err := attempt()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var llmErr *LLMError
+		retryable := true
+		var delay time.Duration
+		if errors.As(err, &llmErr) {
+			retryable = llmErr.Retryable()
+			delay = llmErr.RetryAfter
+		}
+		if !retryable || i >= c.maxRetries {
+			return err
+		}
+		if delay <= 0 {
+			delay = llmRetryBaseDelay << i
+		}
+		if delay > llmRetryMaxDelay {
+			delay = llmRetryMaxDelay
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"fm-opencode-tinyapp/internal/models"
+)
+
+const (
+	anthropicVersion       = "2023-06-01"
+	defaultAzureAPIVersion = "2024-06-01"
+
+	// Bounds of the Anthropic output token limit derived from the input
+	// length when llm.maxTokens is not set. The upper bound is supported by
+	// current models; larger limits have to be configured.
+	anthropicMinMaxTokens = 4096
+	anthropicMaxMaxTokens = 8192
+)
+
+// llmAdapter translates completions to and from a provider's native API.
+type llmAdapter interface {
+	// newRequest builds the HTTP request for a completion.
+	newRequest(ctx context.Context, model string, messages []Message, stream bool) (*http.Request, error)
+	// parseResponse extracts the completion text from a non-streamed response body.
+	parseResponse(body []byte) (string, error)
+	// parseStreamLine extracts the text delta from one line of a streamed response.
+	parseStreamLine(line string) (delta string, done bool, err error)
+	// newModelsRequest builds a request listing the available models. It is
+	// used to check the endpoint and credentials without running a completion.
+	newModelsRequest(ctx context.Context) (*http.Request, error)
+}
+
+// newLLMAdapter selects the configured adapter. An empty adapter uses the
+// OpenAI-compatible API.
+func newLLMAdapter(config models.LLMConfig) llmAdapter {
+	baseURL := strings.TrimRight(config.BaseURL, "/")
+	switch config.Adapter {
+	case models.LLMAdapterAnthropic:
+		return &anthropicAdapter{baseURL: baseURL, apiKey: config.APIKey, maxTokens: config.MaxTokens}
+	case models.LLMAdapterAzure:
+		apiVersion := config.APIVersion
+		if apiVersion == "" {
+			apiVersion = defaultAzureAPIVersion
+		}
+		return &azureAdapter{baseURL: baseURL, apiKey: config.APIKey, apiVersion: apiVersion}
+	case models.LLMAdapterOllama:
+		return &ollamaAdapter{baseURL: baseURL}
+	}
+	return &openAIAdapter{baseURL: baseURL, apiKey: config.APIKey}
+}
+
+func newJSONRequest(ctx context.Context, endpoint string, body interface{}) (*http.Request, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+func newGetRequest(ctx context.Context, endpoint string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	return req, nil
+}
+
+// sseData returns the payload of an SSE "data:" line.
+func sseData(line string) (string, bool) {
+	if !strings.HasPrefix(line, "data:") {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(line, "data:")), true
+}
+
+// === OpenAI compatible ===
+
+// openAIAdapter speaks the OpenAI-style /chat/completions API with a Bearer token.
+type openAIAdapter struct {
+	baseURL string
+	apiKey  string
+}
+
+func (a *openAIAdapter) newRequest(ctx context.Context, model string, messages []Message, stream bool) (*http.Request, error) {
+	req, err := newJSONRequest(ctx, a.baseURL+"/chat/completions", ChatRequest{Model: model, Messages: messages, Stream: stream})
+	if err != nil {
+		return nil, err
+	}
+	if a.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+a.apiKey)
+	}
+	return req, nil
+}
+
+func (a *openAIAdapter) newModelsRequest(ctx context.Context) (*http.Request, error) {
+	req, err := newGetRequest(ctx, a.baseURL+"/models")
+	if err != nil {
+		return nil, err
+	}
+	if a.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+a.apiKey)
+	}
+	return req, nil
+}
+
+func (a *openAIAdapter) parseResponse(body []byte) (string, error) {
+	return parseChatCompletion(body)
+}
+
+func (a *openAIAdapter) parseStreamLine(line string) (string, bool, error) {
+	return parseChatCompletionChunk(line)
+}
+
+func parseChatCompletion(body []byte) (string, error) {
+	var chatResp ChatResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(chatResp.Choices) == 0 {
+		return "", fmt.Errorf("no choices in response")
+	}
+	return chatResp.Choices[0].Message.Content, nil
+}
+
+func parseChatCompletionChunk(line string) (string, bool, error) {
+	data, ok := sseData(line)
+	if !ok || data == "" {
+		return "", false, nil
+	}
+	if data == "[DONE]" {
+		return "", true, nil
+	}
+	var chunk StreamChunk
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return "", false, fmt.Errorf("failed to parse stream chunk: %w", err)
+	}
+	var delta strings.Builder
+	for _, choice := range chunk.Choices {
+		delta.WriteString(choice.Delta.Content)
+	}
+	return delta.String(), false, nil
+}
+
+// === Azure OpenAI ===
+
+// azureAdapter speaks Azure OpenAI. The model is used as the deployment name
+// unless the base URL already points at a deployment.
+type azureAdapter struct {
+	baseURL    string
+	apiKey     string
+	apiVersion string
+}
+
+func (a *azureAdapter) newRequest(ctx context.Context, model string, messages []Message, stream bool) (*http.Request, error) {
+	endpoint := a.baseURL
+	if !strings.Contains(endpoint, "/openai/deployments/") {
+		endpoint += "/openai/deployments/" + url.PathEscape(model)
+	}
+	endpoint += "/chat/completions?api-version=" + url.QueryEscape(a.apiVersion)
+
+	req, err := newJSONRequest(ctx, endpoint, ChatRequest{Messages: messages, Stream: stream})
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("api-key", a.apiKey)
+	return req, nil
+}
+
+func (a *azureAdapter) newModelsRequest(ctx context.Context) (*http.Request, error) {
+	endpoint := a.baseURL
+	if i := strings.Index(endpoint, "/openai/deployments/"); i >= 0 {
+		endpoint = endpoint[:i]
+	}
+	req, err := newGetRequest(ctx, endpoint+"/openai/models?api-version="+url.QueryEscape(a.apiVersion))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("api-key", a.apiKey)
+	return req, nil
+}
+
+func (a *azureAdapter) parseResponse(body []byte) (string, error) {
+	return parseChatCompletion(body)
+}
+
+func (a *azureAdapter) parseStreamLine(line string) (string, bool, error) {
+	return parseChatCompletionChunk(line)
+}
+
+// === Anthropic ===
+
+// anthropicAdapter speaks the Anthropic Messages API. maxTokens is the
+// configured output token limit; zero derives it from the input length.
+type anthropicAdapter struct {
+	baseURL   string
+	apiKey    string
+	maxTokens int
+}
+
+type anthropicRequest struct {
+	Model     string    `json:"model"`
+	MaxTokens int       `json:"max_tokens"`
+	System    string    `json:"system,omitempty"`
+	Messages  []Message `json:"messages"`
+	Stream    bool      `json:"stream,omitempty"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+}
+
+type anthropicStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"` // Set in message_delta events
+	} `json:"delta"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func (a *anthropicAdapter) newRequest(ctx context.Context, model string, messages []Message, stream bool) (*http.Request, error) {
+	body := anthropicRequest{Model: model, MaxTokens: a.outputLimit(messages), Stream: stream}
+	// System prompts are a top-level field in the Messages API.
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			body.System = strings.TrimSpace(body.System + "\n" + msg.Content)
+			continue
+		}
+		body.Messages = append(body.Messages, msg)
+	}
+
+	req, err := newJSONRequest(ctx, a.baseURL+"/messages", body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-api-key", a.apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+	return req, nil
+}
+
+// outputLimit returns max_tokens for a request. Rewritten text is about as
+// long as its input, so the derived limit allows twice the input length in
+// characters, which is more than its length in tokens for most languages.
+func (a *anthropicAdapter) outputLimit(messages []Message) int {
+	if a.maxTokens > 0 {
+		return a.maxTokens
+	}
+	chars := 0
+	for _, msg := range messages {
+		chars += utf8.RuneCountInString(msg.Content)
+	}
+	limit := 2 * chars
+	if limit < anthropicMinMaxTokens {
+		return anthropicMinMaxTokens
+	}
+	if limit > anthropicMaxMaxTokens {
+		return anthropicMaxMaxTokens
+	}
+	return limit
+}
+
+// truncatedError reports a response cut off at max_tokens.
+func (a *anthropicAdapter) truncatedError() error {
+	return fmt.Errorf("%w; raise llm.maxTokens", ErrLLMTruncated)
+}
+
+func (a *anthropicAdapter) newModelsRequest(ctx context.Context) (*http.Request, error) {
+	req, err := newGetRequest(ctx, a.baseURL+"/models")
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-api-key", a.apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+	return req, nil
+}
+
+func (a *anthropicAdapter) parseResponse(body []byte) (string, error) {
+	var resp anthropicResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if resp.StopReason == "max_tokens" {
+		return "", a.truncatedError()
+	}
+	var text strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return "", fmt.Errorf("no text content in response")
+	}
+	return text.String(), nil
+}
+
+func (a *anthropicAdapter) parseStreamLine(line string) (string, bool, error) {
+	data, ok := sseData(line)
+	if !ok || data == "" {
+		return "", false, nil
+	}
+	var event anthropicStreamEvent
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		return "", false, fmt.Errorf("failed to parse stream chunk: %w", err)
+	}
+	switch event.Type {
+	case "content_block_delta":
+		if event.Delta.Type == "text_delta" {
+			return event.Delta.Text, false, nil
+		}
+	case "message_delta":
+		if event.Delta.StopReason == "max_tokens" {
+			return "", false, a.truncatedError()
+		}
+	case "message_stop":
+		return "", true, nil
+	case "error":
+		if event.Error != nil {
+			return "", false, fmt.Errorf("stream error: %s: %s", event.Error.Type, event.Error.Message)
+		}
+		return "", false, fmt.Errorf("stream error")
+	}
+	return "", false, nil
+}
+
+// === Ollama ===
+
+// ollamaAdapter speaks the native Ollama /api/chat API, which streams
+// newline-delimited JSON instead of SSE.
+type ollamaAdapter struct {
+	baseURL string
+}
+
+type ollamaRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream"` // Ollama streams unless explicitly disabled
+}
+
+type ollamaResponse struct {
+	Message Message `json:"message"`
+	Done    bool    `json:"done"`
+	Error   string  `json:"error,omitempty"`
+}
+
+func (a *ollamaAdapter) newRequest(ctx context.Context, model string, messages []Message, stream bool) (*http.Request, error) {
+	return newJSONRequest(ctx, a.baseURL+"/api/chat", ollamaRequest{Model: model, Messages: messages, Stream: stream})
+}
+
+func (a *ollamaAdapter) newModelsRequest(ctx context.Context) (*http.Request, error) {
+	return newGetRequest(ctx, a.baseURL+"/api/tags")
+}
+
+func (a *ollamaAdapter) parseResponse(body []byte) (string, error) {
+	var resp ollamaResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if resp.Error != "" {
+		return "", fmt.Errorf("ollama error: %s", resp.Error)
+	}
+	return resp.Message.Content, nil
+}
+
+func (a *ollamaAdapter) parseStreamLine(line string) (string, bool, error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", false, nil
+	}
+	var resp ollamaResponse
+	if err := json.Unmarshal([]byte(line), &resp); err != nil {
+		return "", false, fmt.Errorf("failed to parse stream chunk: %w", err)
+	}
+	if resp.Error != "" {
+		return "", false, fmt.Errorf("ollama error: %s", resp.Error)
+	}
+	return resp.Message.Content, resp.Done, nil
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sentinel errors for classifying LLM failures with errors.Is.
+var (
+	ErrLLMAuth       = errors.New("llm: authentication failed")
+	ErrLLMRateLimit  = errors.New("llm: rate limited")
+	ErrLLMBadRequest = errors.New("llm: bad request")
+	ErrLLMServer     = errors.New("llm: server error")
+	ErrLLMTruncated  = errors.New("llm: response truncated at the output token limit")
+)
+
+// LLMError is returned for non-2xx responses from the LLM endpoint.
+type LLMError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // Parsed Retry-After header, if any
+	kind       error
+}
+
+func (e *LLMError) Error() string {
+	body := strings.TrimSpace(e.Body)
+	if len(body) > 500 {
+		body = body[:500] + "..."
+	}
+	var hint string
+	switch e.kind {
+	case ErrLLMAuth:
+		hint = "authentication failed; check the API key and provider settings"
+	case ErrLLMRateLimit:
+		hint = "rate limited by the provider; wait and try again"
+		if e.RetryAfter > 0 {
+			hint = fmt.Sprintf("rate limited by the provider; retry after %s", e.RetryAfter.Round(time.Second))
+		}
+	case ErrLLMBadRequest:
+		hint = "request rejected; check the model name and base URL"
+	case ErrLLMServer:
+		hint = "provider server error; try again later"
+	default:
+		hint = "API request failed"
+	}
+	return fmt.Sprintf("%s (status %d): %s", hint, e.StatusCode, body)
+}
+
+// Is reports whether target is the sentinel error matching this failure.
+func (e *LLMError) Is(target error) bool {
+	return e.kind != nil && target == e.kind
+}
+
+// Retryable reports whether the request may succeed when retried.
+func (e *LLMError) Retryable() bool {
+	return e.kind == ErrLLMRateLimit || e.kind == ErrLLMServer
+}
+
+// newLLMError classifies a failed response.
+func newLLMError(resp *http.Response, body []byte) *LLMError {
+	err := &LLMError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		err.kind = ErrLLMAuth
+	case resp.StatusCode == http.StatusTooManyRequests:
+		err.kind = ErrLLMRateLimit
+	case resp.StatusCode >= 500:
+		err.kind = ErrLLMServer
+	case resp.StatusCode >= 400:
+		err.kind = ErrLLMBadRequest
+	}
+	return err
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,394 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"fm-opencode-tinyapp/internal/models"
+)
+
+// fakeLLMServer records the last request and replies with the given handler.
+type fakeLLMServer struct {
+	*httptest.Server
+	path   string
+	query  string
+	header http.Header
+	body   map[string]interface{}
+}
+
+func newFakeLLMServer(t *testing.T, reply func(w http.ResponseWriter, stream bool)) *fakeLLMServer {
+	t.Helper()
+	f := &fakeLLMServer{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.path = r.URL.Path
+		f.query = r.URL.RawQuery
+		f.header = r.Header.Clone()
+		f.body = map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&f.body); err != nil {
+			t.Errorf("failed to decode request body: %v", err)
+		}
+		stream, _ := f.body["stream"].(bool)
+		reply(w, stream)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func writeSSE(w http.ResponseWriter, events ...string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, event := range events {
+		fmt.Fprintf(w, "data: %s\n\n", event)
+	}
+}
+
+func polish(t *testing.T, client *LLMClient) (string, string) {
+	t.Helper()
+	req := &models.PolishTextRequest{Text: "hello", Prompt: "fix: {text}", Model: "test-model"}
+
+	resp, err := client.PolishText(req)
+	if err != nil {
+		t.Fatalf("PolishText failed: %v", err)
+	}
+
+	var deltas []string
+	streamed, err := client.PolishTextStream(context.Background(), req, func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil {
+		t.Fatalf("PolishTextStream failed: %v", err)
+	}
+	if got := strings.Join(deltas, ""); got != streamed.PolishedText {
+		t.Errorf("deltas %q do not add up to result %q", got, streamed.PolishedText)
+	}
+	return resp.PolishedText, streamed.PolishedText
+}
+
+func TestLLMClientOpenAI(t *testing.T) {
+	server := newFakeLLMServer(t, func(w http.ResponseWriter, stream bool) {
+		if stream {
+			writeSSE(w,
+				`{"choices":[{"delta":{"role":"assistant"}}]}`,
+				`{"choices":[{"delta":{"content":"Hel"}}]}`,
+				`{"choices":[{"delta":{"content":"lo!"}}]}`,
+				`[DONE]`)
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Hello!"}}]}`)
+	})
+
+	client := NewLLMClient(models.LLMConfig{Provider: "OpenAI API互換", BaseURL: server.URL + "/v1", APIKey: "sk-test"})
+	text, streamed := polish(t, client)
+
+	if text != "Hello!" || streamed != "Hello!" {
+		t.Errorf("unexpected results: %q, %q", text, streamed)
+	}
+	if server.path != "/v1/chat/completions" {
+		t.Errorf("unexpected path: %s", server.path)
+	}
+	if got := server.header.Get("Authorization"); got != "Bearer sk-test" {
+		t.Errorf("unexpected Authorization header: %q", got)
+	}
+	if server.body["model"] != "test-model" {
+		t.Errorf("unexpected model: %v", server.body["model"])
+	}
+}
+
+func TestLLMClientAnthropic(t *testing.T) {
+	server := newFakeLLMServer(t, func(w http.ResponseWriter, stream bool) {
+		if stream {
+			writeSSE(w,
+				`{"type":"message_start","message":{"id":"msg_1"}}`,
+				`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"lo!"}}`,
+				`{"type":"message_stop"}`)
+			return
+		}
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"Hello!"}]}`)
+	})
+
+	client := NewLLMClient(models.LLMConfig{Provider: "Anthropic", Adapter: models.LLMAdapterAnthropic, BaseURL: server.URL + "/v1", APIKey: "ak-test"})
+	text, streamed := polish(t, client)
+
+	if text != "Hello!" || streamed != "Hello!" {
+		t.Errorf("unexpected results: %q, %q", text, streamed)
+	}
+	if server.path != "/v1/messages" {
+		t.Errorf("unexpected path: %s", server.path)
+	}
+	if got := server.header.Get("x-api-key"); got != "ak-test" {
+		t.Errorf("unexpected x-api-key header: %q", got)
+	}
+	if got := server.header.Get("anthropic-version"); got != anthropicVersion {
+		t.Errorf("unexpected anthropic-version header: %q", got)
+	}
+	if server.header.Get("Authorization") != "" {
+		t.Errorf("Authorization header must not be sent")
+	}
+	if _, ok := server.body["max_tokens"]; !ok {
+		t.Errorf("max_tokens is required by the Messages API")
+	}
+}
+
+func TestLLMClientAnthropicMaxTokens(t *testing.T) {
+	server := newFakeLLMServer(t, func(w http.ResponseWriter, stream bool) {
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn"}`)
+	})
+	maxTokens := func(config models.LLMConfig, text string) float64 {
+		t.Helper()
+		config.Adapter = models.LLMAdapterAnthropic
+		config.BaseURL = server.URL
+		config.APIKey = "ak-test"
+		if _, err := NewLLMClient(config).PolishText(&models.PolishTextRequest{Text: text, Prompt: "{text}", Model: "m"}); err != nil {
+			t.Fatalf("PolishText failed: %v", err)
+		}
+		got, _ := server.body["max_tokens"].(float64)
+		return got
+	}
+
+	cases := []struct {
+		name   string
+		config models.LLMConfig
+		text   string
+		want   float64
+	}{
+		{"short input", models.LLMConfig{}, "hello", anthropicMinMaxTokens},
+		{"long input", models.LLMConfig{}, strings.Repeat("あ", 3000), 6000},
+		{"very long input", models.LLMConfig{}, strings.Repeat("a", 20000), anthropicMaxMaxTokens},
+		{"configured", models.LLMConfig{MaxTokens: 32000}, "hello", 32000},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := maxTokens(c.config, c.text); got != c.want {
+				t.Errorf("max_tokens = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestLLMClientAnthropicTruncated(t *testing.T) {
+	server := newFakeLLMServer(t, func(w http.ResponseWriter, stream bool) {
+		if stream {
+			writeSSE(w,
+				`{"type":"message_start","message":{"id":"msg_1"}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`,
+				`{"type":"message_delta","delta":{"stop_reason":"max_tokens","stop_sequence":null},"usage":{"output_tokens":4096}}`,
+				`{"type":"message_stop"}`)
+			return
+		}
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"Hel"}],"stop_reason":"max_tokens"}`)
+	})
+	client := NewLLMClient(models.LLMConfig{Adapter: models.LLMAdapterAnthropic, BaseURL: server.URL, APIKey: "ak-test"})
+	req := &models.PolishTextRequest{Text: "hello", Model: "m"}
+
+	if resp, err := client.PolishText(req); !errors.Is(err, ErrLLMTruncated) {
+		t.Errorf("PolishText = %+v, %v; want ErrLLMTruncated", resp, err)
+	}
+	if resp, err := client.PolishTextStream(context.Background(), req, nil); !errors.Is(err, ErrLLMTruncated) {
+		t.Errorf("PolishTextStream = %+v, %v; want ErrLLMTruncated", resp, err)
+	}
+}
+
+func TestLLMClientAzure(t *testing.T) {
+	server := newFakeLLMServer(t, func(w http.ResponseWriter, stream bool) {
+		if stream {
+			writeSSE(w,
+				`{"choices":[]}`,
+				`{"choices":[{"delta":{"content":"Hello!"}}]}`,
+				`[DONE]`)
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Hello!"}}]}`)
+	})
+
+	client := NewLLMClient(models.LLMConfig{Provider: "Azure OpenAI", Adapter: models.LLMAdapterAzure, BaseURL: server.URL, APIKey: "az-test", APIVersion: "2024-10-21"})
+	text, streamed := polish(t, client)
+
+	if text != "Hello!" || streamed != "Hello!" {
+		t.Errorf("unexpected results: %q, %q", text, streamed)
+	}
+	if server.path != "/openai/deployments/test-model/chat/completions" {
+		t.Errorf("unexpected path: %s", server.path)
+	}
+	if server.query != "api-version=2024-10-21" {
+		t.Errorf("unexpected query: %s", server.query)
+	}
+	if got := server.header.Get("api-key"); got != "az-test" {
+		t.Errorf("unexpected api-key header: %q", got)
+	}
+}
+
+func TestLLMClientOllama(t *testing.T) {
+	server := newFakeLLMServer(t, func(w http.ResponseWriter, stream bool) {
+		if stream {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			fmt.Fprintln(w, `{"message":{"role":"assistant","content":"Hel"},"done":false}`)
+			fmt.Fprintln(w, `{"message":{"role":"assistant","content":"lo!"},"done":false}`)
+			fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true}`)
+			return
+		}
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"Hello!"},"done":true}`)
+	})
+
+	config := models.LLMConfig{Provider: "Ollama", Adapter: models.LLMAdapterOllama, BaseURL: server.URL}
+	if !config.Configured() {
+		t.Fatalf("Ollama must not require an API key")
+	}
+	client := NewLLMClient(config)
+	text, streamed := polish(t, client)
+
+	if text != "Hello!" || streamed != "Hello!" {
+		t.Errorf("unexpected results: %q, %q", text, streamed)
+	}
+	if server.path != "/api/chat" {
+		t.Errorf("unexpected path: %s", server.path)
+	}
+	if stream, ok := server.body["stream"]; !ok || stream != true {
+		t.Errorf("stream must be sent explicitly, got %v", stream)
+	}
+}
+
+func TestLLMClientErrorStatus(t *testing.T) {
+	server := newFakeLLMServer(t, func(w http.ResponseWriter, stream bool) {
+		http.Error(w, `{"error":"bad key"}`, http.StatusUnauthorized)
+	})
+
+	client := NewLLMClient(models.LLMConfig{BaseURL: server.URL, APIKey: "sk-test"})
+	_, err := client.PolishText(&models.PolishTextRequest{Text: "hello", Model: "m"})
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected a 401 error, got %v", err)
+	}
+}
+
+func TestLLMClientRetriesRateLimit(t *testing.T) {
+	attempts := 0
+	server := newFakeLLMServer(t, func(w http.ResponseWriter, stream bool) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, `{"error":"slow down"}`, http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Hello!"}}]}`)
+	})
+
+	client := NewLLMClient(models.LLMConfig{BaseURL: server.URL, APIKey: "sk-test"})
+	resp, err := client.PolishText(&models.PolishTextRequest{Text: "hello", Model: "m"})
+	if err != nil {
+		t.Fatalf("PolishText failed: %v", err)
+	}
+	if resp.PolishedText != "Hello!" || attempts != 2 {
+		t.Errorf("unexpected result %q after %d attempts", resp.PolishedText, attempts)
+	}
+}
+
+func TestLLMClientErrorKinds(t *testing.T) {
+	cases := []struct {
+		status int
+		kind   error
+	}{
+		{http.StatusUnauthorized, ErrLLMAuth},
+		{http.StatusBadRequest, ErrLLMBadRequest},
+		{http.StatusTooManyRequests, ErrLLMRateLimit},
+		{http.StatusBadGateway, ErrLLMServer},
+	}
+	for _, tc := range cases {
+		server := newFakeLLMServer(t, func(w http.ResponseWriter, stream bool) {
+			http.Error(w, "failure", tc.status)
+		})
+		client := NewLLMClient(models.LLMConfig{BaseURL: server.URL, APIKey: "sk-test", MaxRetries: -1})
+		_, err := client.PolishText(&models.PolishTextRequest{Text: "hello", Model: "m"})
+		if !errors.Is(err, tc.kind) {
+			t.Errorf("status %d: expected %v, got %v", tc.status, tc.kind, err)
+		}
+	}
+}
+
+func TestLLMClientCancel(t *testing.T) {
+	server := newFakeLLMServer(t, func(w http.ResponseWriter, stream bool) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client := NewLLMClient(models.LLMConfig{BaseURL: server.URL, APIKey: "sk-test"})
+	_, err := client.PolishTextContext(ctx, &models.PolishTextRequest{Text: "hello", Model: "m"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got := parseRetryAfter("3", now); got != 3*time.Second {
+		t.Errorf("seconds: got %s", got)
+	}
+	if got := parseRetryAfter(now.Add(5*time.Second).Format(http.TimeFormat), now); got != 5*time.Second {
+		t.Errorf("date: got %s", got)
+	}
+	if got := parseRetryAfter("soon", now); got != 0 {
+		t.Errorf("invalid: got %s", got)
+	}
+}
+
+func TestLLMClientPing(t *testing.T) {
+	var path, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		if auth != "Bearer sk-test" {
+			http.Error(w, `{"error":"bad key"}`, http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"data":[]}`)
+	}))
+	defer server.Close()
+
+	client := NewLLMClient(models.LLMConfig{BaseURL: server.URL + "/v1", APIKey: "sk-test"})
+	if err := client.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+	if path != "/v1/models" {
+		t.Errorf("unexpected path: %s", path)
+	}
+
+	client = NewLLMClient(models.LLMConfig{BaseURL: server.URL + "/v1", APIKey: "sk-wrong"})
+	if err := client.Ping(context.Background()); !errors.Is(err, ErrLLMAuth) {
+		t.Errorf("expected ErrLLMAuth, got %v", err)
+	}
+}
+
+func TestBuildPolishMessagesVariables(t *testing.T) {
+	cases := []struct {
+		name      string
+		prompt    string
+		text      string
+		variables map[string]string
+		want      string
+	}{
+		{"text only", "fix: {text}", "hello", nil, "fix: hello"},
+		{"variables", "{text} in {language} for {session_title}", "hello", map[string]string{"language": "English", "session_title": "Docs"}, "hello in English for Docs"},
+		{"repeated placeholder", "{text} / {text}", "a", nil, "a / a"},
+		{"unknown placeholder is kept", "{text} {missing}", "a", nil, "a {missing}"},
+		{"text variable does not override the text", "{text}", "a", map[string]string{"text": "b"}, "a"},
+		{"placeholders in values are not expanded", "{text} {language}", "{language}", map[string]string{"language": "{text}"}, "{language} {text}"},
+		{"default prompt", "", "hello", nil, "以下の文章をより自然で分かりやすく、丁寧な表現に修正してください。\n誤字脱字や文法的な誤りも修正してください。\n\n---\nhello"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			messages := buildPolishMessages(&models.PolishTextRequest{Text: c.text, Prompt: c.prompt, Variables: c.variables})
+			if len(messages) != 1 || messages[0].Role != "user" {
+				t.Fatalf("messages = %+v, want a single user message", messages)
+			}
+			if messages[0].Content != c.want {
+				t.Errorf("prompt = %q, want %q", messages[0].Content, c.want)
+			}
+		})
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"fm-opencode-tinyapp/internal/models"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -20,8 +22,16 @@ type StreamClient struct {
 	logger     *logrus.Logger
 	eventChan  chan *models.Event
 	stopChan   chan struct{}
+
+	mu         sync.Mutex
+	cancelConn context.CancelFunc // Cancels the current connection
+	status     models.StreamStatus
+	stopOnce   sync.Once
 }
 
+// errReconnect is returned by connectAndStream when SetBaseURL interrupted it.
+var errReconnect = errors.New("reconnect requested")
+
 // NewStreamClient creates a new StreamClient.
 func NewStreamClient(baseURL string, logger *logrus.Logger) *StreamClient {
 	return &StreamClient{
@@ -30,9 +40,23 @@ func NewStreamClient(baseURL string, logger *logrus.Logger) *StreamClient {
 		logger:     logger,
 		eventChan:  make(chan *models.Event, 100),
 		stopChan:   make(chan struct{}),
+		status:     models.StreamStatus{State: models.StreamStateIdle, URL: baseURL},
 	}
 }
 
+// Status returns the state of the event stream connection.
+func (c *StreamClient) Status() models.StreamStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+func (c *StreamClient) updateStatus(change func(status *models.StreamStatus)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	change(&c.status)
+}
+
 // SubscribeEvents connects to the /event endpoint and starts listening for SSE events.
 // It runs in a goroutine and will attempt to reconnect on failure.
 func (c *StreamClient) SubscribeEvents(ctx context.Context) (<-chan *models.Event, error) {
@@ -42,6 +66,7 @@ func (c *StreamClient) SubscribeEvents(ctx context.Context) (<-chan *models.Even
 
 func (c *StreamClient) startEventStream(ctx context.Context) {
 	defer close(c.eventChan)
+	defer c.updateStatus(func(status *models.StreamStatus) { status.State = models.StreamStateStopped })
 
 	for {
 		select {
@@ -53,7 +78,14 @@ func (c *StreamClient) startEventStream(ctx context.Context) {
 			return
 		default:
 			err := c.connectAndStream(ctx)
+			if errors.Is(err, errReconnect) {
+				continue
+			}
 			if err != nil {
+				c.updateStatus(func(status *models.StreamStatus) {
+					status.State = models.StreamStateDisconnected
+					status.LastError = err.Error()
+				})
 				c.logger.Errorf("Event stream error: %v. Reconnecting in 5 seconds...", err)
 				time.Sleep(5 * time.Second)
 			}
@@ -61,8 +93,38 @@ func (c *StreamClient) startEventStream(ctx context.Context) {
 	}
 }
 
+// SetBaseURL points the stream at another server, reconnecting immediately.
+func (c *StreamClient) SetBaseURL(baseURL string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.BaseURL = baseURL
+	if c.cancelConn != nil {
+		c.cancelConn()
+	}
+}
+
 func (c *StreamClient) connectAndStream(ctx context.Context) error {
-	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+"/event", nil)
+	connCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	c.mu.Lock()
+	baseURL := c.BaseURL
+	c.cancelConn = cancel
+	if c.status.State != models.StreamStateIdle {
+		c.status.Reconnects++
+	}
+	c.status.State = models.StreamStateConnecting
+	c.status.URL = baseURL
+	c.mu.Unlock()
+
+	err := c.stream(connCtx, baseURL)
+	if connCtx.Err() != nil && ctx.Err() == nil {
+		return errReconnect
+	}
+	return err
+}
+
+func (c *StreamClient) stream(ctx context.Context, baseURL string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL+"/event", nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -80,6 +142,11 @@ func (c *StreamClient) connectAndStream(ctx context.Context) error {
 		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
+	c.updateStatus(func(status *models.StreamStatus) {
+		status.State = models.StreamStateConnected
+		status.ConnectedAt = time.Now().UnixMilli()
+		status.LastError = ""
+	})
 	c.logger.Info("Successfully connected to event stream.")
 
 	scanner := bufio.NewScanner(resp.Body)
@@ -92,6 +159,7 @@ func (c *StreamClient) connectAndStream(ctx context.Context) error {
 				c.logger.Warnf("Failed to unmarshal event data: %v, data: %s", err, data)
 				continue
 			}
+			c.updateStatus(func(status *models.StreamStatus) { status.LastEventAt = time.Now().UnixMilli() })
 			c.eventChan <- &event
 		}
 	}
@@ -103,7 +171,7 @@ func (c *StreamClient) connectAndStream(ctx context.Context) error {
 	return fmt.Errorf("event stream disconnected")
 }
 
-// Stop gracefully stops the event stream.
+// Stop gracefully stops the event stream. It is safe to call more than once.
 func (c *StreamClient) Stop() {
-	close(c.stopChan)
+	c.stopOnce.Do(func() { close(c.stopChan) })
 }
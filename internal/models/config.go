@@ -1,27 +1,189 @@
 package models
 
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// AppConfigVersion is the current schema version of config.json. Files
+// without a version are version 0.
+const AppConfigVersion = 2
+
 // AppConfig defines the structure for the application's local configuration.
 type AppConfig struct {
-	ServerURL string `json:"serverURL"`
-	LLM       LLMConfig `json:"llm,omitempty"`
+	Version     int               `json:"version"`
+	ServerURL   string            `json:"serverURL"`
+	Server      ServerProcess     `json:"server,omitempty"`
+	LLM         LLMConfig         `json:"llm,omitempty"`
+	Budget      BudgetConfig      `json:"budget,omitempty"`
+	Compaction  CompactionConfig  `json:"compaction,omitempty"`
+	Permission  PermissionPolicy  `json:"permission,omitempty"`
+	Hooks       HooksConfig       `json:"hooks,omitempty"`
+	Templates   []PromptTemplate  `json:"templates,omitempty"`
+	Translation TranslationConfig `json:"translation,omitempty"`
+}
+
+// ServerProcess defines how the local opencode server is started when
+// ServerURL points at this machine and nothing is listening there yet.
+type ServerProcess struct {
+	Disabled     bool              `json:"disabled,omitempty"`     // Never start a server
+	Binary       string            `json:"binary,omitempty"`       // Default "opencode"
+	Args         []string          `json:"args,omitempty"`         // Appended to "serve --hostname <host> --port <port>"
+	Env          map[string]string `json:"env,omitempty"`          // Added to the app's environment
+	Dir          string            `json:"dir,omitempty"`          // Working directory, default the app's
+	ReadyTimeout int               `json:"readyTimeout,omitempty"` // Seconds to wait for readiness, default 30
+	LogFile      string            `json:"logFile,omitempty"`      // Also write output here; relative to the app directory
+	LogMaxSize   int               `json:"logMaxSize,omitempty"`   // Rotate after this many KB, default 5120
+	LogMaxFiles  int               `json:"logMaxFiles,omitempty"`  // Rotated files kept, default 3
 }
 
 // LLMConfig defines the structure for LLM configuration.
 type LLMConfig struct {
-	Provider  string `json:"provider,omitempty"`
-	BaseURL   string `json:"baseURL,omitempty"`
-	APIKey    string `json:"apiKey,omitempty"`
-	Model     string `json:"model,omitempty"`
-	Prompt    string `json:"prompt,omitempty"`
+	Provider   string `json:"provider,omitempty"` // Display label
+	Adapter    string `json:"adapter,omitempty"`  // API adapter: "openai" (default), "anthropic", "azure" or "ollama"
+	BaseURL    string `json:"baseURL,omitempty"`
+	APIKey     string `json:"apiKey,omitempty"` // Secret reference; plain keys are moved to the secret store
+	Model      string `json:"model,omitempty"`
+	Prompt     string `json:"prompt,omitempty"`
+	APIVersion string `json:"apiVersion,omitempty"` // Azure OpenAI api-version
+	Timeout    int    `json:"timeout,omitempty"`    // Seconds per attempt (default 60)
+	MaxRetries int    `json:"maxRetries,omitempty"` // Retries on rate limits and server errors (default 2, negative disables)
+	MaxTokens  int    `json:"maxTokens,omitempty"`  // Anthropic output token limit (default derived from the input length)
+}
+
+// LLM adapters selected by LLMConfig.Adapter.
+const (
+	LLMAdapterOpenAI    = "openai"
+	LLMAdapterAnthropic = "anthropic"
+	LLMAdapterAzure     = "azure"
+	LLMAdapterOllama    = "ollama"
+)
+
+// LLMAdapterForProvider guesses the adapter from a provider label. It is only
+// used to fill in the adapter of configs written before it existed; unknown
+// labels (including "OpenAI API互換") use the OpenAI-compatible API.
+func LLMAdapterForProvider(provider string) string {
+	provider = strings.ToLower(provider)
+	switch {
+	case strings.Contains(provider, "anthropic"), strings.Contains(provider, "claude"):
+		return LLMAdapterAnthropic
+	case strings.Contains(provider, "azure"):
+		return LLMAdapterAzure
+	case strings.Contains(provider, "ollama"):
+		return LLMAdapterOllama
+	}
+	return LLMAdapterOpenAI
+}
+
+// Configured reports whether enough settings are present to create a client.
+// Ollama runs locally and does not need an API key.
+func (c LLMConfig) Configured() bool {
+	if c.BaseURL == "" {
+		return false
+	}
+	return c.APIKey != "" || c.Adapter == LLMAdapterOllama
+}
+
+// PromptTemplate is a named text transformation. Prompt may use {text},
+// {session_title}, {selection}, {language} and any key of Variables.
+type PromptTemplate struct {
+	Name      string            `json:"name"`
+	Prompt    string            `json:"prompt"`
+	Model     string            `json:"model,omitempty"`     // Overrides LLMConfig.Model
+	Variables map[string]string `json:"variables,omitempty"` // Default variable values
+}
+
+// DefaultPromptTemplates returns the templates used when none are configured.
+// The "polish" template uses LLMConfig.Prompt.
+func DefaultPromptTemplates() []PromptTemplate {
+	return []PromptTemplate{
+		{Name: "polish"},
+		{
+			Name: "formal",
+			Prompt: `以下の文章をビジネス文書にふさわしいフォーマルな表現に書き換えてください。
+意味は変えず、書き換え後の文章のみ出力してください。
+---
+{text}`,
+		},
+		{
+			Name: "summarize",
+			Prompt: `以下の文章を{language}で簡潔に要約してください。
+要約のみ出力してください。
+---
+{text}`,
+			Variables: map[string]string{"language": "日本語"},
+		},
+		{
+			Name: "translate",
+			Prompt: `以下の文章を{language}に翻訳してください。
+翻訳結果のみ出力してください。
+---
+{text}`,
+			Variables: map[string]string{"language": "English"},
+		},
+	}
+}
+
+// TransformTextRequest runs a prompt template with explicit variables.
+type TransformTextRequest struct {
+	Template  string            `json:"template"`
+	Text      string            `json:"text"`
+	SessionID string            `json:"sessionID,omitempty"` // Provides {session_title}
+	Selection string            `json:"selection,omitempty"`
+	Language  string            `json:"language,omitempty"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+// TranslationConfig defines the bidirectional translation mode.
+type TranslationConfig struct {
+	Enabled        bool   `json:"enabled,omitempty"`        // Translate outgoing messages before sending
+	TargetLanguage string `json:"targetLanguage,omitempty"` // Language sent to the agent (default "English")
+	SourceLanguage string `json:"sourceLanguage,omitempty"` // Language replies are translated back to (default "日本語")
+	Model          string `json:"model,omitempty"`          // Overrides LLMConfig.Model
+	Prompt         string `json:"prompt,omitempty"`         // Uses {text} and {language}
+}
+
+// BudgetConfig defines spending limits. A zero value disables the limit.
+type BudgetConfig struct {
+	SessionCost   float64 `json:"sessionCost,omitempty"`   // Maximum cost per session
+	SessionTokens int     `json:"sessionTokens,omitempty"` // Maximum input+output+reasoning tokens per session
+	DailyCost     float64 `json:"dailyCost,omitempty"`     // Maximum cost across all sessions per local day
+}
+
+// CompactionConfig defines the automatic compaction policy.
+type CompactionConfig struct {
+	Enabled   bool    `json:"enabled,omitempty"`
+	Threshold float64 `json:"threshold,omitempty"` // Context usage percentage (0-100) that triggers compaction
+}
+
+// HooksConfig defines commands run in response to server events.
+type HooksConfig struct {
+	MaxConcurrent int          `json:"maxConcurrent,omitempty"` // Default 4
+	Hooks         []HookConfig `json:"hooks,omitempty"`
+}
+
+// HookConfig maps an event type plus optional filters to a command line.
+// The command receives the event JSON on stdin.
+type HookConfig struct {
+	Name      string `json:"name,omitempty"`
+	Event     string `json:"event"`               // Event type, e.g. "session.idle" or "permission.updated"
+	SessionID string `json:"sessionID,omitempty"` // Only events of this session
+	Tool      string `json:"tool,omitempty"`      // Only tool parts of this tool (message.part.updated)
+	Status    string `json:"status,omitempty"`    // Only tool parts in this state, e.g. "completed"
+	Command   string `json:"command"`             // Run through the system shell
+	Timeout   int    `json:"timeout,omitempty"`   // Seconds, default 30
+	Disabled  bool   `json:"disabled,omitempty"`
 }
 
 // ServerConfig defines the structure for the server's configuration.
 type ServerConfig struct {
-	Theme    string                `json:"theme,omitempty"`
-	Model    string                `json:"model,omitempty"`
+	Theme    string                 `json:"theme,omitempty"`
+	Model    string                 `json:"model,omitempty"`
 	Agent    map[string]AgentConfig `json:"agent,omitempty"`
 	Provider map[string]interface{} `json:"provider,omitempty"`
-	Keybinds interface{}           `json:"keybinds,omitempty"`
+	Keybinds interface{}            `json:"keybinds,omitempty"`
 }
 
 // AgentConfig defines the structure for an agent's configuration.
@@ -75,9 +237,110 @@ type PolishTextRequest struct {
 	Text   string `json:"text"`
 	Prompt string `json:"prompt"`
 	Model  string `json:"model"`
+	// Variables holds additional placeholders (name without braces) expanded
+	// in Prompt alongside {text}.
+	Variables map[string]string `json:"variables,omitempty"`
 }
 
 // PolishTextResponse represents a response from polishing text.
 type PolishTextResponse struct {
 	PolishedText string `json:"polishedText"`
 }
+
+// ConfigFieldError describes an invalid config field. Field is the JSON path,
+// e.g. "llm.baseURL" or "hooks.hooks[0].command".
+type ConfigFieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ConfigValidationError is returned when a config fails validation.
+type ConfigValidationError struct {
+	Errors []ConfigFieldError `json:"errors"`
+}
+
+func (e *ConfigValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, fieldErr := range e.Errors {
+		messages = append(messages, fieldErr.Field+": "+fieldErr.Message)
+	}
+	return "invalid config: " + strings.Join(messages, "; ")
+}
+
+// ConfigOverride replaces a config field with a value given on the command
+// line or in the environment. Overrides are applied on top of config.json
+// and never written back to it.
+type ConfigOverride struct {
+	Field  string `json:"field"` // JSON path, e.g. "llm.baseURL"
+	Value  string `json:"value"`
+	Source string `json:"source"` // e.g. "--server-url" or "FMOC_SERVER_URL"
+}
+
+// Sources of a config change.
+const (
+	ConfigSourceAPI  = "api"  // UpdateAppConfig
+	ConfigSourceFile = "file" // config.json edited on disk
+)
+
+// ConfigChange is the payload of the "config.changed" event.
+type ConfigChange struct {
+	Source   string    `json:"source"`
+	Sections []string  `json:"sections"` // Top-level keys that changed, e.g. "llm"
+	Config   AppConfig `json:"config"`   // Masked
+}
+
+// ChangedConfigSections returns the top-level JSON keys whose values differ.
+// All sections are reported when previous is nil.
+func ChangedConfigSections(previous, next *AppConfig) []string {
+	sections := func(config *AppConfig) map[string]json.RawMessage {
+		fields := map[string]json.RawMessage{}
+		if config != nil {
+			data, _ := json.Marshal(config)
+			_ = json.Unmarshal(data, &fields)
+		}
+		return fields
+	}
+	before, after := sections(previous), sections(next)
+
+	changed := []string{}
+	for key := range after {
+		if !bytes.Equal(before[key], after[key]) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// SecretRefPrefix marks a config value that refers to an entry in the secret
+// store instead of holding the secret itself.
+const SecretRefPrefix = "secret:"
+
+// SecretMask replaces secret values in configs handed to the frontend.
+const SecretMask = "********"
+
+// SecretRef returns the reference to the named secret.
+func SecretRef(name string) string {
+	return SecretRefPrefix + name
+}
+
+// SecretName returns the secret name of a reference, or false for plain values.
+func SecretName(value string) (string, bool) {
+	if !strings.HasPrefix(value, SecretRefPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(value, SecretRefPrefix), true
+}
+
+// Masked returns a copy of the config with secret values replaced by SecretMask.
+func (c AppConfig) Masked() AppConfig {
+	if c.LLM.APIKey != "" {
+		c.LLM.APIKey = SecretMask
+	}
+	return c
+}
@@ -0,0 +1,65 @@
+package models
+
+// Event stream states reported by StreamStatus.State.
+const (
+	StreamStateIdle         = "idle"         // Not subscribed yet
+	StreamStateConnecting   = "connecting"   // Connecting to /event
+	StreamStateConnected    = "connected"    // Receiving events
+	StreamStateDisconnected = "disconnected" // Lost the connection, waiting to reconnect
+	StreamStateStopped      = "stopped"
+)
+
+// StreamStatus describes the connection to the server's event stream.
+type StreamStatus struct {
+	State       string `json:"state"`
+	URL         string `json:"url"`
+	ConnectedAt int64  `json:"connectedAt,omitempty"` // Unix milliseconds
+	LastEventAt int64  `json:"lastEventAt,omitempty"` // Unix milliseconds
+	Reconnects  int    `json:"reconnects"`
+	LastError   string `json:"lastError,omitempty"`
+}
+
+// Results of a DiagnosticCheck.
+const (
+	DiagnosticOK      = "ok"
+	DiagnosticWarning = "warning"
+	DiagnosticError   = "error"
+	DiagnosticSkipped = "skipped" // Not applicable, e.g. the LLM is not configured
+)
+
+// DiagnosticCheck is the result of one diagnostic check.
+type DiagnosticCheck struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+	LatencyMs int64  `json:"latencyMs,omitempty"`
+}
+
+// Diagnostics describes the health of the connections used by the app.
+type Diagnostics struct {
+	Time            int64             `json:"time"` // Unix milliseconds
+	ServerURL       string            `json:"serverURL"`
+	Project         *Project          `json:"project,omitempty"`
+	OpenCodeVersion string            `json:"openCodeVersion,omitempty"`
+	Checks          []DiagnosticCheck `json:"checks"`
+	Server          ServerStatus      `json:"server"`
+	Stream          StreamStatus      `json:"stream"`
+}
+
+// DiagnosticsBundle is the report copied for bug reports. Secrets are
+// redacted before it is handed out.
+type DiagnosticsBundle struct {
+	App         DiagnosticsAppInfo `json:"app"`
+	Diagnostics *Diagnostics       `json:"diagnostics"`
+	Config      AppConfig          `json:"config"`
+	Overrides   []ConfigOverride   `json:"overrides,omitempty"`
+	ServerLog   []ServerLogLine    `json:"serverLog"`
+}
+
+// DiagnosticsAppInfo describes the environment the app runs in.
+type DiagnosticsAppInfo struct {
+	OS         string `json:"os"`
+	Arch       string `json:"arch"`
+	GoVersion  string `json:"goVersion"`
+	ConfigPath string `json:"configPath"`
+}
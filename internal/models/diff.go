@@ -0,0 +1,44 @@
+package models
+
+// DiffOp is the kind of a diff span.
+type DiffOp string
+
+const (
+	DiffEqual  DiffOp = "equal"
+	DiffInsert DiffOp = "insert"
+	DiffDelete DiffOp = "delete"
+)
+
+// DiffSpan is a run of text that is unchanged, inserted or deleted.
+// Hunk is the index of the hunk a changed span belongs to, or -1 for equal spans.
+type DiffSpan struct {
+	Op   DiffOp `json:"op"`
+	Text string `json:"text"`
+	Hunk int    `json:"hunk"`
+}
+
+// DiffHunk is a group of adjacent changes that can be accepted or rejected as a unit.
+type DiffHunk struct {
+	Index       int    `json:"index"`
+	Original    string `json:"original"`    // Deleted text
+	Replacement string `json:"replacement"` // Inserted text
+	Offset      int    `json:"offset"`      // Rune offset in the original text
+}
+
+// TextDiff is a structured diff between an original and a rewritten text.
+type TextDiff struct {
+	Original string     `json:"original"`
+	Revised  string     `json:"revised"`
+	Spans    []DiffSpan `json:"spans"`
+	Hunks    []DiffHunk `json:"hunks"`
+}
+
+// DiffGranularity controls how texts are split before diffing.
+type DiffGranularity string
+
+const (
+	// DiffByWord compares Latin words as a whole and CJK text per character.
+	DiffByWord DiffGranularity = "word"
+	// DiffByChar compares every character.
+	DiffByChar DiffGranularity = "char"
+)
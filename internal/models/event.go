@@ -1,5 +1,7 @@
 package models
 
+import "strings"
+
 // Event defines the structure for a server-sent event from the OpenCode API.
 // Based on the OpenCode API specification, events have a type and properties.
 type Event struct {
@@ -23,3 +25,31 @@ type MessageUpdatedEvent struct {
 		Info map[string]interface{} `json:"info"`
 	} `json:"properties"`
 }
+
+// SessionID returns the session an event refers to, looking at the common
+// property layouts ("sessionID", "part.sessionID", "info.sessionID" and
+// "info.id" for session events).
+func (e *Event) SessionID() string {
+	if e == nil || e.Properties == nil {
+		return ""
+	}
+	if id, ok := e.Properties["sessionID"].(string); ok {
+		return id
+	}
+	if part, ok := e.Properties["part"].(map[string]interface{}); ok {
+		if id, ok := part["sessionID"].(string); ok {
+			return id
+		}
+	}
+	if info, ok := e.Properties["info"].(map[string]interface{}); ok {
+		if id, ok := info["sessionID"].(string); ok {
+			return id
+		}
+		if strings.HasPrefix(e.Type, "session.") {
+			if id, ok := info["id"].(string); ok {
+				return id
+			}
+		}
+	}
+	return ""
+}
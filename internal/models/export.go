@@ -0,0 +1,49 @@
+package models
+
+import "encoding/json"
+
+// Export formats supported by ExportSession.
+const (
+	ExportFormatMarkdown = "markdown"
+	ExportFormatJSON     = "json"
+	ExportFormatHTML     = "html"
+)
+
+// SessionExportVersion is the version of the JSON export format.
+const SessionExportVersion = 1
+
+// ExportOptions controls what is included in a session export.
+type ExportOptions struct {
+	Format          string `json:"format"`
+	HideReasoning   bool   `json:"hideReasoning,omitempty"`
+	HideToolOutputs bool   `json:"hideToolOutputs,omitempty"`
+	RedactPaths     bool   `json:"redactPaths,omitempty"`
+}
+
+// SessionExport is the lossless JSON export format of a session.
+// Messages are kept as the raw JSON returned by the server.
+type SessionExport struct {
+	Version    int               `json:"version"`
+	ExportedAt int64             `json:"exportedAt"`
+	Session    *Session          `json:"session"`
+	Messages   []json.RawMessage `json:"messages"`
+}
+
+// Import modes supported by ImportSession.
+const (
+	// ImportModeReplay re-sends the user messages of the export in order.
+	ImportModeReplay = "replay"
+	// ImportModeTranscript posts a condensed transcript as a single message.
+	ImportModeTranscript = "transcript"
+)
+
+// ImportProgress reports the progress of a session import.
+type ImportProgress struct {
+	SessionID       string `json:"sessionID"`
+	SourceSessionID string `json:"sourceSessionID"`
+	Mode            string `json:"mode"`
+	Status          string `json:"status"` // "started", "progress", "completed" or "failed"
+	Current         int    `json:"current"`
+	Total           int    `json:"total"`
+	Error           string `json:"error,omitempty"`
+}
@@ -0,0 +1,14 @@
+package models
+
+// HookRun records a single execution of an event hook.
+type HookRun struct {
+	Hook      string `json:"hook"`
+	Event     string `json:"event"`
+	SessionID string `json:"sessionID,omitempty"`
+	Command   string `json:"command"`
+	Started   int64  `json:"started"`  // Unix milliseconds
+	Duration  int64  `json:"duration"` // Milliseconds
+	ExitCode  int    `json:"exitCode"`
+	Output    string `json:"output"` // Combined stdout/stderr, truncated
+	Error     string `json:"error,omitempty"`
+}
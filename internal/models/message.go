@@ -65,6 +65,10 @@ func (m AssistantMessage) GetRole() string      { return m.Role }
 type MessageWithParts struct {
 	Info  Message `json:"info"`
 	Parts []Part  `json:"parts"`
+
+	// Raw holds the original JSON as returned by the server so that
+	// fields not modelled here (e.g. unknown part types) are not lost.
+	Raw json.RawMessage `json:"-"`
 }
 
 // UnmarshalJSON for MessageWithParts to handle polymorphic Info and Parts
@@ -80,6 +84,7 @@ func (m *MessageWithParts) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &aux); err != nil {
 		return err
 	}
+	m.Raw = append(json.RawMessage(nil), data...)
 
 	// Unmarshal Info
 	var base baseMessage
@@ -123,6 +128,12 @@ func (m *MessageWithParts) UnmarshalJSON(data []byte) error {
 				return err
 			}
 			m.Parts[i] = toolPart
+		case "reasoning":
+			var reasoningPart ReasoningPart
+			if err := json.Unmarshal(partData, &reasoningPart); err != nil {
+				return err
+			}
+			m.Parts[i] = reasoningPart
 		// Add other part types here
 		default:
 			// For now, just unmarshal into a generic map
@@ -156,6 +167,14 @@ type TextPart struct {
 
 func (p TextPart) GetType() string { return p.Type }
 
+// ReasoningPart represents the model's reasoning (thinking) output.
+type ReasoningPart struct {
+	basePart
+	Text string `json:"text"`
+}
+
+func (p ReasoningPart) GetType() string { return p.Type }
+
 // ToolPart represents a tool part of a message.
 type ToolPart struct {
 	basePart
@@ -177,6 +196,26 @@ func (p ToolPart) GetState() string {
 	return "unknown"
 }
 
+// StateMap returns the state as a map when the server sent an object.
+// Keys typically include "status", "input", "output", "title" and "error".
+func (p ToolPart) StateMap() map[string]interface{} {
+	if m, ok := p.State.(map[string]interface{}); ok {
+		return m
+	}
+	return nil
+}
+
+// Status returns the tool execution status ("pending", "running", "completed", "error").
+func (p ToolPart) Status() string {
+	if s, ok := p.State.(string); ok {
+		return s
+	}
+	if status, ok := p.StateMap()["status"].(string); ok {
+		return status
+	}
+	return ""
+}
+
 
 // TextInputPart is used for sending message parts.
 type TextInputPart struct {
@@ -196,6 +235,8 @@ type ChatInput struct {
 	Parts []TextInputPart `json:"parts"`
 	Model *ModelSelection `json:"model,omitempty"`
 	Agent string          `json:"agent,omitempty"`
+	// MessageID is the ID of the user message; the server creates one when empty.
+	MessageID string `json:"messageID,omitempty"`
 }
 
 // CommandInput represents the input for a command.
@@ -203,3 +244,13 @@ type CommandInput struct {
 	Agent   string `json:"agent"`
 	Command string `json:"command"`
 }
+
+// MessageCompletion is the payload of the "message.completed" event emitted
+// when the agent has finished a message sent with SendMessageAsync.
+type MessageCompletion struct {
+	SessionID  string `json:"sessionID"`
+	MessageID  string `json:"messageID,omitempty"` // ID of the sent user message
+	StartedAt  int64  `json:"startedAt"`           // Unix milliseconds
+	FinishedAt int64  `json:"finishedAt"`          // Unix milliseconds
+	Error      string `json:"error,omitempty"`     // Set when the session reported an error
+}
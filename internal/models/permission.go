@@ -0,0 +1,140 @@
+package models
+
+import "encoding/json"
+
+// Permission responses accepted by RespondPermission, plus "ask" for rules
+// that leave the decision to the user.
+const (
+	PermissionOnce   = "once"
+	PermissionAlways = "always"
+	PermissionReject = "reject"
+	PermissionAsk    = "ask"
+)
+
+// PermissionPolicy defines the rule-based permission auto-responder.
+// Rules are evaluated in order and the first match wins; when no rule
+// matches, the request is left to the user.
+type PermissionPolicy struct {
+	Enabled bool             `json:"enabled,omitempty"`
+	DryRun  bool             `json:"dryRun,omitempty"` // Only log what would have been answered
+	Rules   []PermissionRule `json:"rules,omitempty"`
+}
+
+// PermissionRule matches permission requests. Empty fields match anything.
+type PermissionRule struct {
+	Name      string `json:"name,omitempty"`
+	Tool      string `json:"tool,omitempty"`      // Tool/permission type glob, e.g. "bash", "edit", "web*"
+	Command   string `json:"command,omitempty"`   // Command glob, e.g. "git status*" ("*" does not match ;, &, |, $, backticks, parentheses or redirections)
+	Path      string `json:"path,omitempty"`      // File path glob, e.g. "src/**/*.go" ("*" stops at "/")
+	SessionID string `json:"sessionID,omitempty"` // Exact session ID
+	Action    string `json:"action"`              // "once", "always", "reject" or "ask"
+}
+
+// PermissionRequest is the payload of a permission.updated event.
+type PermissionRequest struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	Pattern   interface{}            `json:"pattern,omitempty"` // string or []string
+	SessionID string                 `json:"sessionID"`
+	MessageID string                 `json:"messageID"`
+	CallID    string                 `json:"callID,omitempty"`
+	Title     string                 `json:"title"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	Time      struct {
+		Created int64 `json:"created"`
+	} `json:"time"`
+}
+
+// ParsePermissionRequest decodes the properties of a permission.updated event.
+func ParsePermissionRequest(properties map[string]interface{}) (*PermissionRequest, error) {
+	data, err := json.Marshal(properties)
+	if err != nil {
+		return nil, err
+	}
+	var req PermissionRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// Patterns returns the request pattern(s) as a slice.
+func (r *PermissionRequest) Patterns() []string {
+	switch p := r.Pattern.(type) {
+	case string:
+		return []string{p}
+	case []interface{}:
+		patterns := make([]string, 0, len(p))
+		for _, item := range p {
+			if s, ok := item.(string); ok {
+				patterns = append(patterns, s)
+			}
+		}
+		return patterns
+	}
+	return nil
+}
+
+// Command returns the shell command of the request, if any.
+func (r *PermissionRequest) Command() string {
+	if command, ok := r.Metadata["command"].(string); ok {
+		return command
+	}
+	if r.Type == "bash" {
+		if patterns := r.Patterns(); len(patterns) > 0 {
+			return patterns[0]
+		}
+	}
+	return ""
+}
+
+// FilePath returns the file path the request refers to, if any.
+func (r *PermissionRequest) FilePath() string {
+	for _, key := range []string{"filePath", "filepath", "path"} {
+		if path, ok := r.Metadata[key].(string); ok && path != "" {
+			return path
+		}
+	}
+	return ""
+}
+
+// PermissionDecision is the outcome of evaluating the policy for a request.
+type PermissionDecision struct {
+	Request *PermissionRequest `json:"request"`
+	Rule    string             `json:"rule,omitempty"` // Name (or index) of the matching rule
+	Action  string             `json:"action"`
+	DryRun  bool               `json:"dryRun"`
+	Error   string             `json:"error,omitempty"`
+}
+
+// Permission responders recorded in the audit log.
+const (
+	PermissionResponderUser = "user"
+	PermissionResponderRule = "rule"
+)
+
+// PermissionAuditEntry is one line of the permission audit log.
+type PermissionAuditEntry struct {
+	Time         int64    `json:"time"` // Unix milliseconds
+	SessionID    string   `json:"sessionID"`
+	PermissionID string   `json:"permissionID"`
+	Type         string   `json:"type,omitempty"`
+	Title        string   `json:"title,omitempty"`
+	Patterns     []string `json:"patterns,omitempty"`
+	Command      string   `json:"command,omitempty"`
+	Path         string   `json:"path,omitempty"`
+	Responder    string   `json:"responder"`      // "user" or "rule"
+	Rule         string   `json:"rule,omitempty"` // Matching rule when Responder is "rule"
+	Response     string   `json:"response"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// PermissionAuditFilter narrows down a permission audit query. Empty fields match anything.
+type PermissionAuditFilter struct {
+	SessionID string `json:"sessionID,omitempty"`
+	Responder string `json:"responder,omitempty"`
+	Response  string `json:"response,omitempty"`
+	Since     int64  `json:"since,omitempty"` // Unix milliseconds, inclusive
+	Until     int64  `json:"until,omitempty"` // Unix milliseconds, exclusive
+	Limit     int    `json:"limit,omitempty"` // Most recent entries only
+}
@@ -0,0 +1,26 @@
+package models
+
+import "strconv"
+
+// Project is a registered working directory. Each project runs its own
+// opencode server on Port.
+type Project struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Path       string `json:"path"`
+	Port       int    `json:"port,omitempty"`
+	AddedAt    int64  `json:"addedAt"`              // Unix milliseconds
+	LastOpened int64  `json:"lastOpened,omitempty"` // Unix milliseconds
+}
+
+// ServerURL returns the URL of the project's opencode server.
+func (p *Project) ServerURL() string {
+	return "http://127.0.0.1:" + strconv.Itoa(p.Port)
+}
+
+// ProjectSwitch is the payload of the "project.switched" event. Project is
+// nil when the app went back to the server URL from the config.
+type ProjectSwitch struct {
+	Project   *Project `json:"project"`
+	ServerURL string   `json:"serverURL"`
+}
@@ -0,0 +1,36 @@
+package models
+
+// Server process states reported by ServerStatus.State.
+const (
+	ServerStateStopped  = "stopped"  // Not running
+	ServerStateStarting = "starting" // Started, waiting for readiness
+	ServerStateRunning  = "running"  // Started by the app and ready
+	ServerStateBackoff  = "backoff"  // Exited, waiting to restart
+	ServerStateExternal = "external" // Not managed: remote URL, already running or disabled
+)
+
+// ServerStatus describes the supervised opencode server process.
+type ServerStatus struct {
+	State     string `json:"state"`
+	URL       string `json:"url"`
+	Managed   bool   `json:"managed"` // Whether the app starts and restarts the process
+	PID       int    `json:"pid,omitempty"`
+	StartedAt int64  `json:"startedAt,omitempty"` // Unix milliseconds
+	Restarts  int    `json:"restarts"`
+	LastError string `json:"lastError,omitempty"`
+	ExitCode  int    `json:"exitCode,omitempty"`
+}
+
+// Streams of a ServerLogLine.
+const (
+	ServerLogStdout     = "stdout"
+	ServerLogStderr     = "stderr"
+	ServerLogSupervisor = "supervisor" // Messages about starts, exits and restarts
+)
+
+// ServerLogLine is a line of output of the opencode server process.
+type ServerLogLine struct {
+	Time   int64  `json:"time"` // Unix milliseconds
+	Stream string `json:"stream"`
+	Text   string `json:"text"`
+}
@@ -20,3 +20,14 @@ type Session struct {
 	Time      Time    `json:"time"`
 	Share     *Share  `json:"share,omitempty"`
 }
+
+// CompactionRecord records an automatic compaction of a session.
+type CompactionRecord struct {
+	SessionID  string  `json:"sessionID"`
+	ProviderID string  `json:"providerID"`
+	ModelID    string  `json:"modelID"`
+	Percentage float64 `json:"percentage"` // Context usage that triggered the compaction
+	Threshold  float64 `json:"threshold"`
+	Time       int64   `json:"time"` // Unix milliseconds
+	Error      string  `json:"error,omitempty"`
+}
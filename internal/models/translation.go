@@ -0,0 +1,26 @@
+package models
+
+// Translation directions.
+const (
+	TranslationOutgoing = "outgoing" // User input translated before sending
+	TranslationIncoming = "incoming" // Assistant reply translated back on demand
+)
+
+// DefaultTranslationPrompt is used when TranslationConfig.Prompt is empty.
+const DefaultTranslationPrompt = `以下の文章を{language}に翻訳してください。
+コード、コマンド、ファイルパス、識別子は翻訳せずそのまま残してください。
+翻訳結果のみ出力してください。
+---
+{text}`
+
+// TranslationEntry stores the original and translated text of a message part side by side.
+type TranslationEntry struct {
+	SessionID  string `json:"sessionID"`
+	MessageID  string `json:"messageID,omitempty"` // Empty for entries stored by earlier versions before sending
+	PartID     string `json:"partID,omitempty"`
+	Direction  string `json:"direction"`
+	Language   string `json:"language"` // Language of Translated
+	Original   string `json:"original"`
+	Translated string `json:"translated"`
+	Time       int64  `json:"time"` // Unix milliseconds
+}
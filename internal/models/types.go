@@ -73,10 +73,53 @@ type LogEntry struct {
 
 // SessionTokens represents token usage information for a session.
 type SessionTokens struct {
-	Used       int     `json:"used"`       // Total tokens used (input + output)
-	Max        int     `json:"max"`        // Maximum context tokens
-	Percentage float64 `json:"percentage"` // Usage percentage (0-100)
-	ModelID    string  `json:"modelID"`    // Current model ID
-	ProviderID string  `json:"providerID"` // Current provider ID
+	Used        int          `json:"used"`        // Context tokens of the latest turn (input + cache read)
+	Max         int          `json:"max"`         // Maximum context tokens
+	Percentage  float64      `json:"percentage"`  // Usage percentage (0-100)
+	OutputLimit int          `json:"outputLimit"` // Maximum output tokens of the current model
+	ModelID     string       `json:"modelID"`     // Current model ID
+	ProviderID  string       `json:"providerID"`  // Current provider ID
+	Turns       []TurnTokens `json:"turns"`       // Per-turn breakdown in message order
 }
 
+// TurnTokens represents token usage of a single assistant message.
+type TurnTokens struct {
+	MessageID  string  `json:"messageID"`
+	ModelID    string  `json:"modelID"`
+	ProviderID string  `json:"providerID"`
+	Input      int     `json:"input"`
+	Output     int     `json:"output"`
+	Reasoning  int     `json:"reasoning"`
+	CacheRead  int     `json:"cacheRead"`
+	CacheWrite int     `json:"cacheWrite"`
+	Context    int     `json:"context"` // Input + cache read, i.e. the prompt size of this turn
+	Cost       float64 `json:"cost"`
+}
+
+// MessageSearchFilters narrows down a message search.
+type MessageSearchFilters struct {
+	SessionIDs []string `json:"sessionIDs,omitempty"` // Restrict to these sessions
+	Role       string   `json:"role,omitempty"`       // "user" or "assistant"
+	PartTypes  []string `json:"partTypes,omitempty"`  // "text" and/or "tool"
+	Limit      int      `json:"limit,omitempty"`      // Maximum number of results (default 50)
+}
+
+// HighlightSpan marks a matched range in a snippet, in UTF-16 code unit
+// offsets so that it can be used with JavaScript string methods.
+type HighlightSpan struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// MessageSearchResult is a single hit of a message search.
+type MessageSearchResult struct {
+	SessionID    string          `json:"sessionID"`
+	SessionTitle string          `json:"sessionTitle,omitempty"`
+	MessageID    string          `json:"messageID"`
+	PartID       string          `json:"partID"`
+	Role         string          `json:"role,omitempty"`
+	PartType     string          `json:"partType"`
+	Snippet      string          `json:"snippet"`
+	Highlights   []HighlightSpan `json:"highlights"`
+	Score        int             `json:"score"`
+}
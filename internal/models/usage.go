@@ -0,0 +1,93 @@
+package models
+
+// Usage report grouping keys.
+const (
+	UsageGroupBySession  = "session"
+	UsageGroupByModel    = "model"
+	UsageGroupByProvider = "provider"
+	UsageGroupByDay      = "day"
+)
+
+// UsageRange restricts a usage report to assistant messages created in
+// [From, To). Both are Unix milliseconds; zero means unbounded.
+type UsageRange struct {
+	From int64 `json:"from,omitempty"`
+	To   int64 `json:"to,omitempty"`
+}
+
+// Contains reports whether the timestamp (Unix milliseconds) lies in the range.
+func (r *UsageRange) Contains(ms int64) bool {
+	if r == nil {
+		return true
+	}
+	if r.From > 0 && ms < r.From {
+		return false
+	}
+	if r.To > 0 && ms >= r.To {
+		return false
+	}
+	return true
+}
+
+// UsageTotals holds aggregated cost and token counts.
+type UsageTotals struct {
+	Messages   int     `json:"messages"`
+	Cost       float64 `json:"cost"`
+	Input      int     `json:"input"`
+	Output     int     `json:"output"`
+	Reasoning  int     `json:"reasoning"`
+	CacheRead  int     `json:"cacheRead"`
+	CacheWrite int     `json:"cacheWrite"`
+}
+
+// Add accumulates the usage of a single assistant message.
+func (t *UsageTotals) Add(msg AssistantMessage) {
+	t.Messages++
+	t.Cost += msg.Cost
+	t.Input += msg.Tokens.Input
+	t.Output += msg.Tokens.Output
+	t.Reasoning += msg.Tokens.Reasoning
+	t.CacheRead += msg.Tokens.Cache.Read
+	t.CacheWrite += msg.Tokens.Cache.Write
+}
+
+// UsageRow is one group of a usage report.
+type UsageRow struct {
+	Key   string `json:"key"`   // Session ID, "provider/model", provider ID or YYYY-MM-DD
+	Label string `json:"label"` // Human readable label (e.g. session title)
+	UsageTotals
+}
+
+// UsageReport aggregates cost and token usage across sessions.
+type UsageReport struct {
+	Range   UsageRange  `json:"range"`
+	GroupBy string      `json:"groupBy"`
+	Rows    []UsageRow  `json:"rows"`
+	Total   UsageTotals `json:"total"`
+}
+
+// Budget kinds reported in BudgetExceeded.
+const (
+	BudgetKindSessionCost   = "sessionCost"
+	BudgetKindSessionTokens = "sessionTokens"
+	BudgetKindDailyCost     = "dailyCost"
+)
+
+// BudgetExceeded describes a budget that was exceeded and caused a session to be stopped.
+type BudgetExceeded struct {
+	SessionID string  `json:"sessionID"`
+	MessageID string  `json:"messageID"`
+	Kind      string  `json:"kind"`
+	Limit     float64 `json:"limit"`
+	Actual    float64 `json:"actual"`
+}
+
+// BudgetStatus reports the current spending of a session against the configured budget.
+type BudgetStatus struct {
+	SessionID     string       `json:"sessionID"`
+	SessionCost   float64      `json:"sessionCost"`
+	SessionTokens int          `json:"sessionTokens"`
+	DailyCost     float64      `json:"dailyCost"`
+	Overridden    bool         `json:"overridden"`
+	Budget        BudgetConfig `json:"budget"`
+}
@@ -1,49 +1,95 @@
 package services
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/json"
-	"fm-opencode-tinyapp/internal/models"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
+
+	"fm-opencode-tinyapp/internal/models"
 )
 
-// AppConfigService handles the application's local configuration file.
+const defaultServerURL = "http://localhost:23450"
+
+// AppConfigService handles the application's local configuration file. It is
+// the only component reading or writing config.json.
 type AppConfigService struct {
 	configPath string
+	mu         sync.Mutex
+	knownHash  []byte // Hash of the content last written or reported by Watch
+	overrides  []models.ConfigOverride
+	readOnly   bool // Never write the file; a missing file reads as the defaults
 }
 
-// NewAppConfigService creates a new AppConfigService, ensuring the config directory and file exist.
-func NewAppConfigService() (*AppConfigService, error) {
-	configDir, err := os.UserConfigDir()
+// AppDataDir returns the application's directory under the user config
+// directory, creating it if necessary.
+func AppDataDir() (string, error) {
+	appConfigDir, err := appDataPath()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	appConfigDir := filepath.Join(configDir, "fm-opencode-tinyapp")
 	if err := os.MkdirAll(appConfigDir, 0750); err != nil {
-		return nil, err
+		return "", err
 	}
-	configPath := filepath.Join(appConfigDir, "config.json")
+	return appConfigDir, nil
+}
 
-	// Create a default config file if it doesn't exist
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		defaultConfig := &models.AppConfig{
-			ServerURL: "http://localhost:23450",
-			LLM: models.LLMConfig{
-				Provider: "OpenAI API互換",
-				BaseURL:  "https://api.openai.com/v1",
-				Model:    "gpt-4o",
-				Prompt: `以下の文章をより自然で分かりやすく、丁寧な表現に修正してください。
+// appDataPath returns the application's directory without creating it.
+func appDataPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "fm-opencode-tinyapp"), nil
+}
+
+// DefaultAppConfig returns the configuration written on first start.
+func DefaultAppConfig() *models.AppConfig {
+	return &models.AppConfig{
+		Version:   models.AppConfigVersion,
+		ServerURL: defaultServerURL,
+		LLM: models.LLMConfig{
+			Provider: "OpenAI API互換",
+			Adapter:  models.LLMAdapterOpenAI,
+			BaseURL:  "https://api.openai.com/v1",
+			Model:    "gpt-4o",
+			Prompt: `以下の文章をより自然で分かりやすく、丁寧な表現に修正してください。
 誤字脱字や文法的な誤りも修正してください。
 修正後の文書のみ出力し、返答などは不要です。
 ---
 {text}`,
-			},
-		}
-		data, marshalErr := json.MarshalIndent(defaultConfig, "", "  ")
+		},
+	}
+}
+
+// NewAppConfigService creates a new AppConfigService, ensuring the config directory and file exist.
+func NewAppConfigService() (*AppConfigService, error) {
+	appConfigDir, err := AppDataDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewAppConfigServiceAt(filepath.Join(appConfigDir, "config.json"))
+}
+
+// NewAppConfigServiceAt creates a new AppConfigService for the config file at
+// configPath, creating it with defaults if it doesn't exist.
+func NewAppConfigServiceAt(configPath string) (*AppConfigService, error) {
+	if err := os.MkdirAll(filepath.Dir(configPath), 0750); err != nil {
+		return nil, fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	// Create a default config file if it doesn't exist
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		data, marshalErr := json.MarshalIndent(DefaultAppConfig(), "", "  ")
 		if marshalErr != nil {
 			return nil, marshalErr
 		}
-		if writeErr := os.WriteFile(configPath, data, 0640); writeErr != nil {
+		if writeErr := writeFileAtomic(configPath, data, 0640); writeErr != nil {
 			return nil, writeErr
 		}
 	}
@@ -53,24 +99,228 @@ func NewAppConfigService() (*AppConfigService, error) {
 	}, nil
 }
 
-// GetAppConfig reads the configuration from the JSON file.
+// Path returns the path of the configuration file.
+func (s *AppConfigService) Path() string {
+	return s.configPath
+}
+
+// GetAppConfig reads the configuration from the JSON file and applies the
+// overrides. Files written by older versions are migrated and saved in the
+// current schema.
 func (s *AppConfigService) GetAppConfig() (*models.AppConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	config, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	s.applyOverrides(config)
+	return config, nil
+}
+
+// load reads the file without overrides. It must be called with the lock held.
+func (s *AppConfigService) load() (*models.AppConfig, error) {
 	data, err := os.ReadFile(s.configPath)
+	if os.IsNotExist(err) && s.readOnly {
+		data, err = json.Marshal(DefaultAppConfig())
+	}
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	migrated, err := migrateAppConfig(raw)
 	if err != nil {
 		return nil, err
 	}
+	if migrated {
+		if data, err = json.MarshalIndent(raw, "", "  "); err != nil {
+			return nil, fmt.Errorf("failed to marshal config: %w", err)
+		}
+		if !s.readOnly {
+			if err := writeFileAtomic(s.configPath, data, 0640); err != nil {
+				return nil, fmt.Errorf("failed to save migrated config: %w", err)
+			}
+			s.knownHash = hashConfig(data)
+		}
+	}
+
 	var config models.AppConfig
 	if err := json.Unmarshal(data, &config); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 	return &config, nil
 }
 
-// UpdateAppConfig writes the given configuration to the JSON file.
+// UpdateAppConfig validates the given configuration and writes it to the
+// JSON file, replacing the whole config; use MergeAppConfig first to change
+// only some fields. Invalid configs are rejected with a *models.ConfigValidationError.
+// Overridden fields keep their value from the file.
 func (s *AppConfigService) UpdateAppConfig(config *models.AppConfig) error {
-	data, err := json.MarshalIndent(config, "", "  ")
+	if s.readOnly {
+		return fmt.Errorf("config %s is opened read-only", s.configPath)
+	}
+	config.Version = models.AppConfigVersion
+	if err := ValidateAppConfig(config); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	toSave := *config
+	if len(s.overrides) > 0 {
+		file, err := s.load()
+		if err != nil {
+			return err
+		}
+		s.restoreOverridden(&toSave, file)
+	}
+	data, err := json.MarshalIndent(&toSave, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(s.configPath, data, 0640)
+	if err := writeFileAtomic(s.configPath, data, 0640); err != nil {
+		return err
+	}
+	s.knownHash = hashConfig(data)
+	return nil
+}
+
+// MergeAppConfig returns the current config (with overrides) with patch
+// merged into it as a JSON merge patch (RFC 7396): objects are merged
+// recursively, other values replace the current ones and null removes a
+// field. Fields missing from patch keep their value. Nothing is saved.
+func (s *AppConfigService) MergeAppConfig(patch map[string]interface{}) (*models.AppConfig, error) {
+	current, err := s.GetAppConfig()
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	mergePatch(raw, patch)
+	if data, err = json.Marshal(raw); err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	var merged models.AppConfig
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, fmt.Errorf("failed to apply config patch: %w", err)
+	}
+	return &merged, nil
+}
+
+func mergePatch(target, patch map[string]interface{}) {
+	for key, value := range patch {
+		if value == nil {
+			delete(target, key)
+			continue
+		}
+		if patchObject, ok := value.(map[string]interface{}); ok {
+			if targetObject, ok := target[key].(map[string]interface{}); ok {
+				mergePatch(targetObject, patchObject)
+				continue
+			}
+		}
+		target[key] = value
+	}
+}
+
+// Watch polls the configuration file until ctx is done and calls onChange
+// with the new config whenever it was changed by another program. Changes
+// made through this service are not reported. Unreadable or invalid files
+// are reported to onError and otherwise ignored.
+func (s *AppConfigService) Watch(ctx context.Context, interval time.Duration, onChange func(*models.AppConfig), onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastMod time.Time
+	var lastSize int64
+	if info, err := os.Stat(s.configPath); err == nil {
+		lastMod, lastSize = info.ModTime(), info.Size()
+	}
+	if data, err := os.ReadFile(s.configPath); err == nil {
+		s.mu.Lock()
+		s.knownHash = hashConfig(data)
+		s.mu.Unlock()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(s.configPath)
+		if err != nil || (info.ModTime().Equal(lastMod) && info.Size() == lastSize) {
+			continue
+		}
+		lastMod, lastSize = info.ModTime(), info.Size()
+
+		data, err := os.ReadFile(s.configPath)
+		if err != nil {
+			continue
+		}
+		hash := hashConfig(data)
+		s.mu.Lock()
+		unchanged := bytes.Equal(hash, s.knownHash)
+		s.knownHash = hash
+		s.mu.Unlock()
+		if unchanged {
+			continue
+		}
+
+		config, err := s.GetAppConfig()
+		if err == nil {
+			err = ValidateAppConfig(config)
+		}
+		if err != nil {
+			onError(err)
+			continue
+		}
+		onChange(config)
+	}
+}
+
+func hashConfig(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it over path, so that readers never see a partially written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // No-op after a successful rename
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return fmt.Errorf("failed to set file permissions: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", filepath.Base(path), err)
+	}
+	return nil
 }
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fm-opencode-tinyapp/internal/models"
+)
+
+func TestAppConfigMigration(t *testing.T) {
+	cases := []struct {
+		name         string
+		file         string
+		wantServer   string
+		wantProvider string
+		wantAdapter  string
+	}{
+		{
+			name:         "version 0 without server URL",
+			file:         `{"llm": {"provider": "OpenAI API compatible", "baseURL": "https://api.openai.com/v1"}}`,
+			wantServer:   defaultServerURL,
+			wantProvider: "OpenAI API互換",
+			wantAdapter:  models.LLMAdapterOpenAI,
+		},
+		{
+			name:         "version 0 with anthropic label",
+			file:         `{"serverURL": "http://localhost:4096", "llm": {"provider": "Anthropic Claude"}}`,
+			wantServer:   "http://localhost:4096",
+			wantProvider: "Anthropic Claude",
+			wantAdapter:  models.LLMAdapterAnthropic,
+		},
+		{
+			name:         "version 1 with azure label",
+			file:         `{"version": 1, "serverURL": "http://localhost:4096", "llm": {"provider": "Azure OpenAI"}}`,
+			wantServer:   "http://localhost:4096",
+			wantProvider: "Azure OpenAI",
+			wantAdapter:  models.LLMAdapterAzure,
+		},
+		{
+			name:         "version 1 with ollama label",
+			file:         `{"version": 1, "serverURL": "http://localhost:4096", "llm": {"provider": "ollama (local)"}}`,
+			wantServer:   "http://localhost:4096",
+			wantProvider: "ollama (local)",
+			wantAdapter:  models.LLMAdapterOllama,
+		},
+		{
+			name:         "version 1 with adapter already set",
+			file:         `{"version": 1, "serverURL": "http://localhost:4096", "llm": {"provider": "My Claude proxy", "adapter": "openai"}}`,
+			wantServer:   "http://localhost:4096",
+			wantProvider: "My Claude proxy",
+			wantAdapter:  models.LLMAdapterOpenAI,
+		},
+		{
+			name:        "version 1 without llm",
+			file:        `{"version": 1, "serverURL": "http://localhost:4096"}`,
+			wantServer:  "http://localhost:4096",
+			wantAdapter: "",
+		},
+		{
+			name:         "current version is not migrated",
+			file:         `{"version": 2, "serverURL": "http://localhost:4096", "llm": {"provider": "Anthropic"}}`,
+			wantServer:   "http://localhost:4096",
+			wantProvider: "Anthropic",
+			wantAdapter:  "",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(path, []byte(c.file), 0640); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+			s, err := NewAppConfigServiceAt(path)
+			if err != nil {
+				t.Fatalf("NewAppConfigServiceAt: %v", err)
+			}
+
+			config, err := s.GetAppConfig()
+			if err != nil {
+				t.Fatalf("GetAppConfig: %v", err)
+			}
+			if config.Version != models.AppConfigVersion {
+				t.Errorf("Version = %d, want %d", config.Version, models.AppConfigVersion)
+			}
+			if config.ServerURL != c.wantServer {
+				t.Errorf("ServerURL = %q, want %q", config.ServerURL, c.wantServer)
+			}
+			if config.LLM.Provider != c.wantProvider {
+				t.Errorf("Provider = %q, want %q", config.LLM.Provider, c.wantProvider)
+			}
+			if config.LLM.Adapter != c.wantAdapter {
+				t.Errorf("Adapter = %q, want %q", config.LLM.Adapter, c.wantAdapter)
+			}
+
+			// The migrated config is saved in the current schema.
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile: %v", err)
+			}
+			var saved models.AppConfig
+			if err := json.Unmarshal(data, &saved); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if saved.Version != models.AppConfigVersion || saved.LLM.Adapter != c.wantAdapter {
+				t.Errorf("saved version %d adapter %q", saved.Version, saved.LLM.Adapter)
+			}
+		})
+	}
+}
+
+func TestAppConfigMigrationRejectsNewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	file := []byte(`{"version": 99, "serverURL": "http://localhost:4096"}`)
+	if err := os.WriteFile(path, file, 0640); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	s, err := NewAppConfigServiceAt(path)
+	if err != nil {
+		t.Fatalf("NewAppConfigServiceAt: %v", err)
+	}
+	if _, err := s.GetAppConfig(); err == nil {
+		t.Error("GetAppConfig accepted a newer config version")
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != string(file) {
+		t.Error("newer config was rewritten")
+	}
+}
+
+func TestValidateAppConfig(t *testing.T) {
+	cases := []struct {
+		name       string
+		modify     func(config *models.AppConfig)
+		wantFields []string
+	}{
+		{"default config", func(c *models.AppConfig) {}, nil},
+		{"missing server URL", func(c *models.AppConfig) { c.ServerURL = "" }, []string{"serverURL"}},
+		{"server URL without scheme", func(c *models.AppConfig) { c.ServerURL = "localhost:4096" }, []string{"serverURL"}},
+		{"server URL without host", func(c *models.AppConfig) { c.ServerURL = "http://" }, []string{"serverURL"}},
+		{"unknown adapter", func(c *models.AppConfig) { c.LLM.Adapter = "gemini" }, []string{"llm.adapter"}},
+		{"LLM base URL with ftp scheme", func(c *models.AppConfig) { c.LLM.BaseURL = "ftp://example.com" }, []string{"llm.baseURL"}},
+		{"negative LLM timeout", func(c *models.AppConfig) { c.LLM.Timeout = -1 }, []string{"llm.timeout"}},
+		{"negative LLM max tokens", func(c *models.AppConfig) { c.LLM.MaxTokens = -1 }, []string{"llm.maxTokens"}},
+		{"negative budgets", func(c *models.AppConfig) {
+			c.Budget = models.BudgetConfig{SessionCost: -1, SessionTokens: -1, DailyCost: -1}
+		}, []string{"budget.sessionCost", "budget.sessionTokens", "budget.dailyCost"}},
+		{"compaction threshold above 100", func(c *models.AppConfig) { c.Compaction.Threshold = 101 }, []string{"compaction.threshold"}},
+		{"unknown permission action", func(c *models.AppConfig) {
+			c.Permission.Rules = []models.PermissionRule{{Command: "git status", Action: "allow"}}
+		}, []string{"permission.rules[0].action"}},
+		{"hook without event and command", func(c *models.AppConfig) {
+			c.Hooks.Hooks = []models.HookConfig{{Command: " ", Timeout: -1}}
+		}, []string{"hooks.hooks[0].event", "hooks.hooks[0].command", "hooks.hooks[0].timeout"}},
+		{"templates", func(c *models.AppConfig) {
+			c.Templates = []models.PromptTemplate{{Name: "a", Prompt: "{text}"}, {Name: "a", Prompt: "{text}"}, {Prompt: ""}}
+		}, []string{"templates[1].name", "templates[2].name", "templates[2].prompt"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config := DefaultAppConfig()
+			c.modify(config)
+			err := ValidateAppConfig(config)
+			if len(c.wantFields) == 0 {
+				if err != nil {
+					t.Fatalf("ValidateAppConfig: %v", err)
+				}
+				return
+			}
+			var validationErr *models.ConfigValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("ValidateAppConfig = %v, want *models.ConfigValidationError", err)
+			}
+			got := make([]string, 0, len(validationErr.Errors))
+			for _, fieldErr := range validationErr.Errors {
+				got = append(got, fieldErr.Field)
+			}
+			if len(got) != len(c.wantFields) {
+				t.Fatalf("invalid fields = %v, want %v", got, c.wantFields)
+			}
+			for i := range got {
+				if got[i] != c.wantFields[i] {
+					t.Errorf("invalid fields = %v, want %v", got, c.wantFields)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateAppConfigRejectsInvalidConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	s, err := NewAppConfigServiceAt(path)
+	if err != nil {
+		t.Fatalf("NewAppConfigServiceAt: %v", err)
+	}
+	before, _ := os.ReadFile(path)
+
+	config := DefaultAppConfig()
+	config.ServerURL = ""
+	var validationErr *models.ConfigValidationError
+	if err := s.UpdateAppConfig(config); !errors.As(err, &validationErr) {
+		t.Fatalf("UpdateAppConfig = %v, want *models.ConfigValidationError", err)
+	}
+	after, _ := os.ReadFile(path)
+	if string(before) != string(after) {
+		t.Error("invalid config was written")
+	}
+}
+
+func TestReadOnlyAppConfigServiceDoesNotWrite(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing", "config.json")
+	s, err := OpenAppConfigServiceReadOnly(missing, []models.ConfigOverride{
+		{Field: "serverURL", Value: "http://localhost:4096", Source: "--server-url"},
+	})
+	if err != nil {
+		t.Fatalf("OpenAppConfigServiceReadOnly: %v", err)
+	}
+	config, err := s.GetAppConfig()
+	if err != nil {
+		t.Fatalf("GetAppConfig: %v", err)
+	}
+	if config.ServerURL != "http://localhost:4096" || config.LLM.Model != DefaultAppConfig().LLM.Model {
+		t.Errorf("config = %+v, want the defaults with the override", config)
+	}
+	if _, err := os.Stat(filepath.Dir(missing)); !os.IsNotExist(err) {
+		t.Error("read-only service created the config directory")
+	}
+	if err := s.UpdateAppConfig(config); err == nil {
+		t.Error("read-only service accepted an update")
+	}
+
+	old := filepath.Join(dir, "config.json")
+	file := []byte(`{"serverURL": "http://localhost:4096", "llm": {"provider": "Anthropic"}}`)
+	if err := os.WriteFile(old, file, 0640); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	s, err = OpenAppConfigServiceReadOnly(old, nil)
+	if err != nil {
+		t.Fatalf("OpenAppConfigServiceReadOnly: %v", err)
+	}
+	config, err = s.GetAppConfig()
+	if err != nil {
+		t.Fatalf("GetAppConfig: %v", err)
+	}
+	if config.Version != models.AppConfigVersion || config.LLM.Adapter != models.LLMAdapterAnthropic {
+		t.Errorf("config was not migrated in memory: %+v", config)
+	}
+	if data, _ := os.ReadFile(old); string(data) != string(file) {
+		t.Error("read-only service rewrote the migrated config")
+	}
+}
+
+func TestMergeAppConfigKeepsOtherSections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	s, err := NewAppConfigServiceAt(path)
+	if err != nil {
+		t.Fatalf("NewAppConfigServiceAt: %v", err)
+	}
+	config := DefaultAppConfig()
+	config.LLM.Timeout = 90
+	config.Budget = models.BudgetConfig{SessionCost: 2.5}
+	config.Permission.Rules = []models.PermissionRule{{Command: "git status", Action: "once"}}
+	config.Hooks.Hooks = []models.HookConfig{{Event: "session.idle", Command: "notify-send done"}}
+	config.Templates = []models.PromptTemplate{{Name: "polite", Prompt: "{text}"}}
+	if err := s.UpdateAppConfig(config); err != nil {
+		t.Fatalf("UpdateAppConfig: %v", err)
+	}
+
+	// The settings screen sends only the server URL and the LLM fields.
+	merged, err := s.MergeAppConfig(map[string]interface{}{
+		"serverURL": "http://localhost:5000",
+		"llm":       map[string]interface{}{"model": "gpt-4o", "prompt": "translate"},
+	})
+	if err != nil {
+		t.Fatalf("MergeAppConfig: %v", err)
+	}
+	if err := s.UpdateAppConfig(merged); err != nil {
+		t.Fatalf("UpdateAppConfig: %v", err)
+	}
+
+	saved, err := s.GetAppConfig()
+	if err != nil {
+		t.Fatalf("GetAppConfig: %v", err)
+	}
+	if saved.ServerURL != "http://localhost:5000" || saved.LLM.Model != "gpt-4o" || saved.LLM.Prompt != "translate" {
+		t.Errorf("patched fields not saved: %+v", saved)
+	}
+	if saved.LLM.Timeout != 90 || saved.LLM.Adapter != config.LLM.Adapter {
+		t.Errorf("llm fields missing from the patch were lost: %+v", saved.LLM)
+	}
+	if saved.Budget.SessionCost != 2.5 {
+		t.Errorf("budget = %+v, want it kept", saved.Budget)
+	}
+	if len(saved.Permission.Rules) != 1 || len(saved.Hooks.Hooks) != 1 || len(saved.Templates) != 1 {
+		t.Errorf("sections lost: permission %+v hooks %+v templates %+v", saved.Permission, saved.Hooks, saved.Templates)
+	}
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"fm-opencode-tinyapp/internal/api"
+	"fm-opencode-tinyapp/internal/models"
+)
+
+// trackedUsage is the latest known usage of a single assistant message.
+type trackedUsage struct {
+	sessionID string
+	day       string
+	cost      float64
+	tokens    int
+}
+
+// BudgetService enforces spending limits by stopping sessions whose cost or
+// token usage exceeds the configured budget.
+type BudgetService struct {
+	apiClient *api.Client
+
+	mu         sync.Mutex
+	budget     models.BudgetConfig
+	messages   map[string]trackedUsage  // messageID -> usage
+	seeded     map[string]bool          // sessionIDs whose history has been loaded
+	seeding    map[string]chan struct{} // sessionIDs whose history is being loaded; closed when done
+	generation int                      // Incremented by Reset; discards history loaded before
+	overrides  map[string]bool          // sessionIDs exempt from budgets
+	stopped    map[string]string        // sessionID -> messageID already stopped
+}
+
+// NewBudgetService creates a new BudgetService.
+func NewBudgetService(apiClient *api.Client) *BudgetService {
+	return &BudgetService{
+		apiClient: apiClient,
+		messages:  make(map[string]trackedUsage),
+		seeded:    make(map[string]bool),
+		seeding:   make(map[string]chan struct{}),
+		overrides: make(map[string]bool),
+		stopped:   make(map[string]string),
+	}
+}
+
+// SetBudget replaces the budget configuration.
+func (s *BudgetService) SetBudget(budget models.BudgetConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.budget = budget
+}
+
+// Reset forgets the tracked usage, e.g. when switching to another server.
+// Session overrides are kept.
+func (s *BudgetService) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = make(map[string]trackedUsage)
+	s.seeded = make(map[string]bool)
+	s.seeding = make(map[string]chan struct{})
+	s.generation++
+	s.stopped = make(map[string]string)
+}
+
+// Seed loads the usage of sessions updated today so that the daily budget
+// includes spending from before the app was started.
+func (s *BudgetService) Seed(ctx context.Context) error {
+	sessions, err := s.apiClient.GetSessions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get sessions: %w", err)
+	}
+	startOfDay := localStartOfDay(time.Now()).UnixMilli()
+	for _, session := range sessions {
+		if session.Time.Updated > 0 && session.Time.Updated < startOfDay {
+			continue
+		}
+		if err := s.ensureSeeded(ctx, session.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureSeeded loads the history of a session unless it has been loaded.
+// Concurrent callers for the same session wait for a single load.
+func (s *BudgetService) ensureSeeded(ctx context.Context, sessionID string) error {
+	for {
+		s.mu.Lock()
+		if s.seeded[sessionID] {
+			s.mu.Unlock()
+			return nil
+		}
+		if wait, ok := s.seeding[sessionID]; ok {
+			s.mu.Unlock()
+			select {
+			case <-wait:
+				// Check again; the load may have failed.
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		done := make(chan struct{})
+		s.seeding[sessionID] = done
+		s.mu.Unlock()
+
+		err := s.seedSession(ctx, sessionID)
+
+		s.mu.Lock()
+		if s.seeding[sessionID] == done {
+			delete(s.seeding, sessionID)
+		}
+		s.mu.Unlock()
+		close(done)
+		return err
+	}
+}
+
+func (s *BudgetService) seedSession(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	generation := s.generation
+	s.mu.Unlock()
+	messages, err := s.apiClient.GetMessages(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get messages for session %s: %w", sessionID, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if generation != s.generation {
+		// Reset while loading; the history belongs to the previous server.
+		return nil
+	}
+	for _, msg := range messages {
+		if assistantMsg, ok := msg.Info.(models.AssistantMessage); ok {
+			s.track(assistantMsg)
+		}
+	}
+	s.seeded[sessionID] = true
+	return nil
+}
+
+// Override exempts a session from all budgets so that it can resume.
+func (s *BudgetService) Override(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides[sessionID] = true
+	delete(s.stopped, sessionID)
+}
+
+// ClearOverride makes budgets apply to the session again.
+func (s *BudgetService) ClearOverride(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.overrides, sessionID)
+}
+
+// Status returns the current spending of a session.
+func (s *BudgetService) Status(ctx context.Context, sessionID string) (*models.BudgetStatus, error) {
+	if err := s.ensureSeeded(ctx, sessionID); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cost, tokens, daily := s.totals(sessionID)
+	return &models.BudgetStatus{
+		SessionID:     sessionID,
+		SessionCost:   cost,
+		SessionTokens: tokens,
+		DailyCost:     daily,
+		Overridden:    s.overrides[sessionID],
+		Budget:        s.budget,
+	}, nil
+}
+
+// Track records the usage of a message.updated event. It returns the
+// assistant message when budgets are configured and the message must be
+// checked with Enforce, otherwise nil. It does not call the server.
+func (s *BudgetService) Track(event *models.Event) *models.AssistantMessage {
+	if event == nil || event.Type != "message.updated" {
+		return nil
+	}
+	info, ok := event.Properties["info"]
+	if !ok {
+		return nil
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil
+	}
+	var msg models.AssistantMessage
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Role != "assistant" || msg.SessionID == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.track(msg)
+	if s.budget.SessionCost <= 0 && s.budget.SessionTokens <= 0 && s.budget.DailyCost <= 0 {
+		return nil
+	}
+	return &msg
+}
+
+// Enforce evaluates the budget for a message returned by Track. When a
+// budget is exceeded by a running message, the session is stopped and the
+// exceeded budget is returned; otherwise nil is returned. The session's
+// earlier usage is loaded from the server first if necessary.
+func (s *BudgetService) Enforce(ctx context.Context, msg models.AssistantMessage) (*models.BudgetExceeded, error) {
+	if err := s.ensureSeeded(ctx, msg.SessionID); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	exceeded := s.check(msg)
+	if exceeded != nil {
+		s.stopped[msg.SessionID] = msg.ID
+	}
+	s.mu.Unlock()
+
+	if exceeded == nil {
+		return nil, nil
+	}
+	if err := s.apiClient.StopMessage(ctx, msg.SessionID); err != nil {
+		return exceeded, fmt.Errorf("failed to stop session %s: %w", msg.SessionID, err)
+	}
+	return exceeded, nil
+}
+
+// check returns the first exceeded budget for a running message. Must be called with the lock held.
+func (s *BudgetService) check(msg models.AssistantMessage) *models.BudgetExceeded {
+	if msg.Time.Completed > 0 || s.overrides[msg.SessionID] || s.stopped[msg.SessionID] == msg.ID {
+		return nil
+	}
+
+	cost, tokens, daily := s.totals(msg.SessionID)
+	exceeded := func(kind string, limit, actual float64) *models.BudgetExceeded {
+		return &models.BudgetExceeded{
+			SessionID: msg.SessionID,
+			MessageID: msg.ID,
+			Kind:      kind,
+			Limit:     limit,
+			Actual:    actual,
+		}
+	}
+
+	switch {
+	case s.budget.SessionCost > 0 && cost > s.budget.SessionCost:
+		return exceeded(models.BudgetKindSessionCost, s.budget.SessionCost, cost)
+	case s.budget.SessionTokens > 0 && tokens > s.budget.SessionTokens:
+		return exceeded(models.BudgetKindSessionTokens, float64(s.budget.SessionTokens), float64(tokens))
+	case s.budget.DailyCost > 0 && daily > s.budget.DailyCost:
+		return exceeded(models.BudgetKindDailyCost, s.budget.DailyCost, daily)
+	}
+	return nil
+}
+
+// track records the latest usage of a message. Must be called with the lock held.
+func (s *BudgetService) track(msg models.AssistantMessage) {
+	created := time.Now()
+	if msg.Time.Created > 0 {
+		created = time.UnixMilli(msg.Time.Created)
+	}
+	s.messages[msg.ID] = trackedUsage{
+		sessionID: msg.SessionID,
+		day:       created.Format("2006-01-02"),
+		cost:      msg.Cost,
+		tokens:    msg.Tokens.Input + msg.Tokens.Output + msg.Tokens.Reasoning,
+	}
+}
+
+// totals returns the session cost, session tokens and today's cost. Must be called with the lock held.
+func (s *BudgetService) totals(sessionID string) (float64, int, float64) {
+	today := time.Now().Format("2006-01-02")
+	var cost, daily float64
+	var tokens int
+	for _, usage := range s.messages {
+		if usage.sessionID == sessionID {
+			cost += usage.cost
+			tokens += usage.tokens
+		}
+		if usage.day == today {
+			daily += usage.cost
+		}
+	}
+	return cost, tokens, daily
+}
+
+func localStartOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
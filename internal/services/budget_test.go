@@ -0,0 +1,310 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fm-opencode-tinyapp/internal/api"
+	"fm-opencode-tinyapp/internal/models"
+)
+
+// fakeBudgetServer serves the history of sessions and records aborts.
+type fakeBudgetServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	history  map[string]string // sessionID -> JSON array of messages
+	loads    map[string]int
+	stopped  []string
+	loadWait chan struct{} // When set, message loads wait until it is closed
+}
+
+func newFakeBudgetServer(t *testing.T) *fakeBudgetServer {
+	t.Helper()
+	f := &fakeBudgetServer{history: make(map[string]string), loads: make(map[string]int)}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		switch {
+		case r.URL.Path == "/session":
+			w.Write([]byte(`[]`))
+		case len(parts) == 3 && parts[2] == "message":
+			f.mu.Lock()
+			f.loads[parts[1]]++
+			history, wait := f.history[parts[1]], f.loadWait
+			f.mu.Unlock()
+			if wait != nil {
+				<-wait
+			}
+			if history == "" {
+				history = "[]"
+			}
+			w.Write([]byte(history))
+		case len(parts) == 3 && parts[2] == "abort":
+			f.mu.Lock()
+			f.stopped = append(f.stopped, parts[1])
+			f.mu.Unlock()
+			w.Write([]byte(`true`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeBudgetServer) stops() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.stopped...)
+}
+
+func (f *fakeBudgetServer) loadCount(sessionID string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.loads[sessionID]
+}
+
+func assistantJSON(sessionID, messageID string, cost float64, tokens int, completed bool) string {
+	var completedAt int64
+	if completed {
+		completedAt = time.Now().UnixMilli()
+	}
+	return fmt.Sprintf(`{"id": %q, "sessionID": %q, "role": "assistant", "cost": %g,
+		"tokens": {"input": %d, "output": 0, "reasoning": 0, "cache": {"read": 0, "write": 0}},
+		"time": {"created": %d, "completed": %d}}`,
+		messageID, sessionID, cost, tokens, time.Now().UnixMilli(), completedAt)
+}
+
+func assistantUpdatedEvent(sessionID, messageID string, cost float64, tokens int, completed bool) *models.Event {
+	return &models.Event{
+		Type:       "message.updated",
+		Properties: map[string]interface{}{"info": jsonValue(assistantJSON(sessionID, messageID, cost, tokens, completed))},
+	}
+}
+
+// jsonValue decodes a JSON document into the generic form events carry.
+func jsonValue(data string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		panic(err)
+	}
+	return value
+}
+
+func newTestBudgetService(t *testing.T, budget models.BudgetConfig) (*BudgetService, *fakeBudgetServer) {
+	t.Helper()
+	server := newFakeBudgetServer(t)
+	s := NewBudgetService(api.NewClient(server.URL))
+	s.SetBudget(budget)
+	return s, server
+}
+
+func TestBudgetTrack(t *testing.T) {
+	s, _ := newTestBudgetService(t, models.BudgetConfig{})
+	if msg := s.Track(assistantUpdatedEvent("ses_1", "msg_1", 1, 10, false)); msg != nil {
+		t.Error("Track returned a message to enforce without a budget")
+	}
+
+	s.SetBudget(models.BudgetConfig{SessionCost: 1})
+	cases := []struct {
+		name  string
+		event *models.Event
+		want  bool
+	}{
+		{"assistant message", assistantUpdatedEvent("ses_1", "msg_1", 0.5, 10, false), true},
+		{"other event", &models.Event{Type: "session.idle", Properties: map[string]interface{}{"sessionID": "ses_1"}}, false},
+		{"user message", messageEvent("ses_1", "msg_2", "user"), false},
+		{"nil event", nil, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := s.Track(c.event) != nil; got != c.want {
+				t.Errorf("Track returned a message = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestBudgetEnforce(t *testing.T) {
+	cases := []struct {
+		name     string
+		budget   models.BudgetConfig
+		history  string
+		events   []*models.Event
+		wantKind string
+	}{
+		{
+			name:   "within budget",
+			budget: models.BudgetConfig{SessionCost: 1, SessionTokens: 100},
+			events: []*models.Event{assistantUpdatedEvent("ses_1", "msg_1", 0.5, 50, false)},
+		},
+		{
+			name:     "session cost",
+			budget:   models.BudgetConfig{SessionCost: 1},
+			events:   []*models.Event{assistantUpdatedEvent("ses_1", "msg_1", 1.5, 10, false)},
+			wantKind: models.BudgetKindSessionCost,
+		},
+		{
+			name:     "session tokens across messages",
+			budget:   models.BudgetConfig{SessionTokens: 100},
+			events:   []*models.Event{assistantUpdatedEvent("ses_1", "msg_1", 0, 60, true), assistantUpdatedEvent("ses_1", "msg_2", 0, 60, false)},
+			wantKind: models.BudgetKindSessionTokens,
+		},
+		{
+			name:     "session cost including history",
+			budget:   models.BudgetConfig{SessionCost: 1},
+			history:  `[{"info": ` + assistantJSON("ses_1", "msg_0", 0.8, 10, true) + `, "parts": []}]`,
+			events:   []*models.Event{assistantUpdatedEvent("ses_1", "msg_1", 0.5, 10, false)},
+			wantKind: models.BudgetKindSessionCost,
+		},
+		{
+			name:     "daily cost across sessions",
+			budget:   models.BudgetConfig{DailyCost: 1},
+			events:   []*models.Event{assistantUpdatedEvent("ses_2", "msg_1", 0.6, 10, true), assistantUpdatedEvent("ses_1", "msg_2", 0.6, 10, false)},
+			wantKind: models.BudgetKindDailyCost,
+		},
+		{
+			name:   "completed message is not stopped",
+			budget: models.BudgetConfig{SessionCost: 1},
+			events: []*models.Event{assistantUpdatedEvent("ses_1", "msg_1", 1.5, 10, true)},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, server := newTestBudgetService(t, c.budget)
+			server.history["ses_1"] = c.history
+
+			var exceeded *models.BudgetExceeded
+			for _, event := range c.events {
+				msg := s.Track(event)
+				if msg == nil {
+					t.Fatal("Track returned no message")
+				}
+				var err error
+				if exceeded, err = s.Enforce(context.Background(), *msg); err != nil {
+					t.Fatalf("Enforce: %v", err)
+				}
+			}
+
+			if c.wantKind == "" {
+				if exceeded != nil || len(server.stops()) != 0 {
+					t.Errorf("exceeded = %+v, stopped %v; want nothing", exceeded, server.stops())
+				}
+				return
+			}
+			if exceeded == nil || exceeded.Kind != c.wantKind {
+				t.Fatalf("exceeded = %+v, want %s", exceeded, c.wantKind)
+			}
+			if stops := server.stops(); len(stops) != 1 || stops[0] != "ses_1" {
+				t.Errorf("stopped sessions = %v, want [ses_1]", stops)
+			}
+		})
+	}
+}
+
+func TestBudgetEnforceStopsOncePerMessage(t *testing.T) {
+	s, server := newTestBudgetService(t, models.BudgetConfig{SessionCost: 1})
+	for i := 0; i < 3; i++ {
+		msg := s.Track(assistantUpdatedEvent("ses_1", "msg_1", 1.5+float64(i), 10, false))
+		if _, err := s.Enforce(context.Background(), *msg); err != nil {
+			t.Fatalf("Enforce: %v", err)
+		}
+	}
+	if stops := server.stops(); len(stops) != 1 {
+		t.Errorf("stopped %d times, want once", len(stops))
+	}
+}
+
+func TestBudgetOverride(t *testing.T) {
+	s, server := newTestBudgetService(t, models.BudgetConfig{SessionCost: 1})
+	s.Override("ses_1")
+	msg := s.Track(assistantUpdatedEvent("ses_1", "msg_1", 1.5, 10, false))
+	if exceeded, err := s.Enforce(context.Background(), *msg); err != nil || exceeded != nil {
+		t.Fatalf("Enforce with override = %+v, %v; want nil", exceeded, err)
+	}
+
+	status, err := s.Status(context.Background(), "ses_1")
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if !status.Overridden || status.SessionCost != 1.5 {
+		t.Errorf("status = %+v", status)
+	}
+
+	s.ClearOverride("ses_1")
+	msg = s.Track(assistantUpdatedEvent("ses_1", "msg_2", 0.1, 10, false))
+	if exceeded, err := s.Enforce(context.Background(), *msg); err != nil || exceeded == nil {
+		t.Errorf("Enforce after clearing the override = %+v, %v; want exceeded", exceeded, err)
+	}
+	if len(server.stops()) != 1 {
+		t.Errorf("stopped %d times, want once", len(server.stops()))
+	}
+}
+
+func TestBudgetReset(t *testing.T) {
+	s, server := newTestBudgetService(t, models.BudgetConfig{SessionCost: 1})
+	msg := s.Track(assistantUpdatedEvent("ses_1", "msg_1", 0.8, 10, false))
+	if _, err := s.Enforce(context.Background(), *msg); err != nil {
+		t.Fatalf("Enforce: %v", err)
+	}
+	s.Override("ses_2")
+
+	s.Reset()
+	status, err := s.Status(context.Background(), "ses_1")
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if status.SessionCost != 0 {
+		t.Errorf("session cost after Reset = %v, want 0", status.SessionCost)
+	}
+	if loads := server.loadCount("ses_1"); loads != 2 {
+		t.Errorf("history loaded %d times, want again after Reset", loads)
+	}
+	if status, _ := s.Status(context.Background(), "ses_2"); !status.Overridden {
+		t.Error("Reset dropped an override")
+	}
+}
+
+func TestBudgetSeedsSessionOnce(t *testing.T) {
+	s, server := newTestBudgetService(t, models.BudgetConfig{SessionCost: 1})
+	server.history["ses_1"] = `[{"info": ` + assistantJSON("ses_1", "msg_0", 0.8, 10, true) + `, "parts": []}]`
+	server.loadWait = make(chan struct{})
+
+	var wg sync.WaitGroup
+	results := make(chan *models.BudgetExceeded, 10)
+	for i := 0; i < 10; i++ {
+		msg := s.Track(assistantUpdatedEvent("ses_1", fmt.Sprintf("msg_%d", i+1), 0.05, 10, false))
+		wg.Add(1)
+		go func(msg models.AssistantMessage) {
+			defer wg.Done()
+			exceeded, err := s.Enforce(context.Background(), msg)
+			if err != nil {
+				t.Errorf("Enforce: %v", err)
+			}
+			results <- exceeded
+		}(*msg)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(server.loadWait)
+	wg.Wait()
+	close(results)
+
+	if loads := server.loadCount("ses_1"); loads != 1 {
+		t.Errorf("history loaded %d times by concurrent Enforce calls, want once", loads)
+	}
+	var exceeded int
+	for result := range results {
+		if result != nil {
+			exceeded++
+		}
+	}
+	if exceeded == 0 {
+		t.Error("no Enforce call saw the budget exceeded with the loaded history")
+	}
+}
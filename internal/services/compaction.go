@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"fm-opencode-tinyapp/internal/api"
+	"fm-opencode-tinyapp/internal/models"
+)
+
+// maxCompactionHistory caps the number of compaction records kept.
+const maxCompactionHistory = 200
+
+// CompactionService summarizes sessions automatically when their context
+// usage crosses the configured threshold. The records of the compactions are
+// kept in a JSON file.
+type CompactionService struct {
+	apiClient   *api.Client
+	historyPath string
+
+	mu      sync.Mutex
+	config  models.CompactionConfig
+	loaded  bool
+	history []models.CompactionRecord
+	turns   map[string]int  // sessionID -> number of turns when last compacted
+	running map[string]bool // sessionIDs currently being compacted
+}
+
+// NewCompactionService creates a new CompactionService keeping its records
+// in compactions.json in the application directory.
+func NewCompactionService(apiClient *api.Client) (*CompactionService, error) {
+	appDir, err := AppDataDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewCompactionServiceAt(apiClient, filepath.Join(appDir, "compactions.json")), nil
+}
+
+// NewCompactionServiceAt creates a CompactionService keeping its records in
+// the file at historyPath.
+func NewCompactionServiceAt(apiClient *api.Client, historyPath string) *CompactionService {
+	return &CompactionService{
+		apiClient:   apiClient,
+		historyPath: historyPath,
+		turns:       make(map[string]int),
+		running:     make(map[string]bool),
+	}
+}
+
+// SetConfig replaces the compaction policy.
+func (s *CompactionService) SetConfig(config models.CompactionConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config
+}
+
+// Enabled reports whether automatic compaction is turned on.
+func (s *CompactionService) Enabled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.config.Enabled && s.config.Threshold > 0
+}
+
+// MaybeCompact summarizes the session with its current provider/model when
+// tokens is above the threshold. It returns the record of the compaction, or
+// nil when no compaction was needed.
+func (s *CompactionService) MaybeCompact(ctx context.Context, sessionID string, tokens *models.SessionTokens) (*models.CompactionRecord, error) {
+	if tokens == nil || tokens.ModelID == "" {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	config := s.config
+	// The summary itself adds a turn whose input is the whole history, so a
+	// session is only compacted again once a new turn follows the summary.
+	last, compacted := s.turns[sessionID]
+	if !config.Enabled || config.Threshold <= 0 || tokens.Percentage < config.Threshold ||
+		s.running[sessionID] || (compacted && len(tokens.Turns) <= last+1) {
+		s.mu.Unlock()
+		return nil, nil
+	}
+	s.running[sessionID] = true
+	s.mu.Unlock()
+
+	record := models.CompactionRecord{
+		SessionID:  sessionID,
+		ProviderID: tokens.ProviderID,
+		ModelID:    tokens.ModelID,
+		Percentage: tokens.Percentage,
+		Threshold:  config.Threshold,
+		Time:       time.Now().UnixMilli(),
+	}
+	err := s.apiClient.SummarizeSession(ctx, sessionID, tokens.ProviderID, tokens.ModelID)
+	if err != nil {
+		record.Error = err.Error()
+		err = fmt.Errorf("failed to compact session %s: %w", sessionID, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, sessionID)
+	if err == nil {
+		s.turns[sessionID] = len(tokens.Turns)
+	}
+	if loadErr := s.load(); loadErr != nil {
+		if err == nil {
+			err = loadErr
+		}
+		return &record, err
+	}
+	s.history = append(s.history, record)
+	if len(s.history) > maxCompactionHistory {
+		s.history = s.history[len(s.history)-maxCompactionHistory:]
+	}
+	if saveErr := s.save(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return &record, err
+}
+
+// History returns the compaction records, optionally limited to one session.
+func (s *CompactionService) History(sessionID string) ([]models.CompactionRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	records := make([]models.CompactionRecord, 0, len(s.history))
+	for _, record := range s.history {
+		if sessionID == "" || record.SessionID == sessionID {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// load must be called with the lock held.
+func (s *CompactionService) load() error {
+	if s.loaded {
+		return nil
+	}
+	data, err := os.ReadFile(s.historyPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read compaction history: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &s.history); err != nil {
+			return fmt.Errorf("failed to parse compaction history: %w", err)
+		}
+	}
+	s.loaded = true
+	return nil
+}
+
+// save must be called with the lock held.
+func (s *CompactionService) save() error {
+	data, err := json.MarshalIndent(s.history, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal compaction history: %w", err)
+	}
+	if err := writeFileAtomic(s.historyPath, data, 0640); err != nil {
+		return fmt.Errorf("failed to write compaction history: %w", err)
+	}
+	return nil
+}
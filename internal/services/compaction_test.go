@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fm-opencode-tinyapp/internal/api"
+	"fm-opencode-tinyapp/internal/models"
+)
+
+func newTestCompactionService(t *testing.T, historyPath string, status int) (*CompactionService, *int) {
+	t.Helper()
+	summarized := new(int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/session/ses_1/summarize" {
+			http.NotFound(w, r)
+			return
+		}
+		*summarized++
+		w.WriteHeader(status)
+		w.Write([]byte(`true`))
+	}))
+	t.Cleanup(server.Close)
+	s := NewCompactionServiceAt(api.NewClient(server.URL), historyPath)
+	s.SetConfig(models.CompactionConfig{Enabled: true, Threshold: 80})
+	return s, summarized
+}
+
+func sessionTokens(percentage float64, turns int) *models.SessionTokens {
+	return &models.SessionTokens{
+		Percentage: percentage,
+		ProviderID: "anthropic",
+		ModelID:    "claude",
+		Turns:      make([]models.TurnTokens, turns),
+	}
+}
+
+func TestMaybeCompact(t *testing.T) {
+	s, summarized := newTestCompactionService(t, filepath.Join(t.TempDir(), "compactions.json"), http.StatusOK)
+	ctx := context.Background()
+
+	if record, err := s.MaybeCompact(ctx, "ses_1", sessionTokens(50, 3)); record != nil || err != nil {
+		t.Fatalf("MaybeCompact below the threshold = %+v, %v", record, err)
+	}
+	record, err := s.MaybeCompact(ctx, "ses_1", sessionTokens(85, 3))
+	if err != nil || record == nil {
+		t.Fatalf("MaybeCompact above the threshold = %+v, %v", record, err)
+	}
+	if record.Percentage != 85 || record.Threshold != 80 || record.ModelID != "claude" {
+		t.Errorf("record = %+v", record)
+	}
+	// The summary turn alone does not trigger another compaction.
+	if record, _ := s.MaybeCompact(ctx, "ses_1", sessionTokens(90, 4)); record != nil {
+		t.Error("compacted again right after the summary")
+	}
+	if record, _ := s.MaybeCompact(ctx, "ses_1", sessionTokens(90, 5)); record == nil {
+		t.Error("not compacted after a new turn")
+	}
+	if *summarized != 2 {
+		t.Errorf("summarized %d times, want 2", *summarized)
+	}
+
+	s.SetConfig(models.CompactionConfig{})
+	if record, _ := s.MaybeCompact(ctx, "ses_1", sessionTokens(99, 9)); record != nil {
+		t.Error("compacted while disabled")
+	}
+}
+
+func TestCompactionHistoryPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "compactions.json")
+	s, _ := newTestCompactionService(t, path, http.StatusInternalServerError)
+	record, err := s.MaybeCompact(context.Background(), "ses_1", sessionTokens(85, 3))
+	if err == nil || record == nil || record.Error == "" {
+		t.Fatalf("failed compaction = %+v, %v; want a record with the error", record, err)
+	}
+
+	reopened, _ := newTestCompactionService(t, path, http.StatusOK)
+	history, err := reopened.History("")
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(history) != 1 || history[0].SessionID != "ses_1" || history[0].Error == "" {
+		t.Fatalf("history = %+v, want the failed compaction", history)
+	}
+	if _, err := reopened.MaybeCompact(context.Background(), "ses_1", sessionTokens(85, 3)); err != nil {
+		t.Fatalf("MaybeCompact: %v", err)
+	}
+	if history, _ := reopened.History("ses_1"); len(history) != 2 {
+		t.Errorf("history = %+v, want both records", history)
+	}
+	if history, _ := reopened.History("ses_2"); len(history) != 0 {
+		t.Errorf("history of another session = %+v", history)
+	}
+
+	if err := os.WriteFile(path, []byte("not json"), 0640); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	corrupt, _ := newTestCompactionService(t, path, http.StatusOK)
+	if _, err := corrupt.History(""); err == nil {
+		t.Error("History accepted a corrupt file")
+	}
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"fmt"
+
+	"fm-opencode-tinyapp/internal/models"
+)
+
+// configMigration upgrades a raw config by one schema version.
+type configMigration func(raw map[string]interface{}) error
+
+// appConfigMigrations[i] migrates a config from version i to i+1.
+var appConfigMigrations = []configMigration{
+	migrateAppConfigV0,
+	migrateAppConfigV1,
+}
+
+// migrateAppConfig upgrades a raw config to models.AppConfigVersion and
+// reports whether anything was changed.
+func migrateAppConfig(raw map[string]interface{}) (bool, error) {
+	version := 0
+	if v, ok := raw["version"].(float64); ok {
+		version = int(v)
+	}
+	if version > models.AppConfigVersion {
+		return false, fmt.Errorf("config version %d is newer than the supported version %d", version, models.AppConfigVersion)
+	}
+	if version == models.AppConfigVersion {
+		return false, nil
+	}
+
+	for ; version < models.AppConfigVersion; version++ {
+		if err := appConfigMigrations[version](raw); err != nil {
+			return false, fmt.Errorf("failed to migrate config from version %d: %w", version, err)
+		}
+		raw["version"] = version + 1
+	}
+	return true, nil
+}
+
+// migrateAppConfigV0 upgrades unversioned configs. They could lack the server
+// URL, and configs written by the former ConfigService used an English label
+// for the OpenAI-compatible provider.
+func migrateAppConfigV0(raw map[string]interface{}) error {
+	if url, _ := raw["serverURL"].(string); url == "" {
+		raw["serverURL"] = defaultServerURL
+	}
+	if llm, ok := raw["llm"].(map[string]interface{}); ok {
+		if llm["provider"] == "OpenAI API compatible" {
+			llm["provider"] = "OpenAI API互換"
+		}
+	}
+	return nil
+}
+
+// migrateAppConfigV1 sets the LLM adapter, which version 1 derived from the
+// provider label on every use.
+func migrateAppConfigV1(raw map[string]interface{}) error {
+	llm, ok := raw["llm"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if adapter, _ := llm["adapter"].(string); adapter == "" {
+		provider, _ := llm["provider"].(string)
+		llm["adapter"] = models.LLMAdapterForProvider(provider)
+	}
+	return nil
+}
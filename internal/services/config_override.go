@@ -0,0 +1,135 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"fm-opencode-tinyapp/internal/models"
+)
+
+// ConfigPathEnv overrides the location of config.json.
+const ConfigPathEnv = "FMOC_CONFIG"
+
+// configOverrideField is a config field that can be overridden.
+type configOverrideField struct {
+	field  string
+	env    string
+	target func(config *models.AppConfig) *string
+}
+
+var configOverrideFields = []configOverrideField{
+	{"serverURL", "FMOC_SERVER_URL", func(c *models.AppConfig) *string { return &c.ServerURL }},
+	{"llm.provider", "FMOC_LLM_PROVIDER", func(c *models.AppConfig) *string { return &c.LLM.Provider }},
+	{"llm.adapter", "FMOC_LLM_ADAPTER", func(c *models.AppConfig) *string { return &c.LLM.Adapter }},
+	{"llm.baseURL", "FMOC_LLM_BASE_URL", func(c *models.AppConfig) *string { return &c.LLM.BaseURL }},
+	{"llm.model", "FMOC_LLM_MODEL", func(c *models.AppConfig) *string { return &c.LLM.Model }},
+	{"llm.apiVersion", "FMOC_LLM_API_VERSION", func(c *models.AppConfig) *string { return &c.LLM.APIVersion }},
+}
+
+func findOverrideField(field string) (configOverrideField, bool) {
+	for _, f := range configOverrideFields {
+		if f.field == field {
+			return f, true
+		}
+	}
+	return configOverrideField{}, false
+}
+
+// EnvConfigOverrides returns the overrides set through FMOC_* environment
+// variables. The LLM API key is read from FMOC_LLM_API_KEY by SecretService.
+func EnvConfigOverrides() []models.ConfigOverride {
+	var overrides []models.ConfigOverride
+	for _, f := range configOverrideFields {
+		if value := os.Getenv(f.env); value != "" {
+			overrides = append(overrides, models.ConfigOverride{Field: f.field, Value: value, Source: f.env})
+		}
+	}
+	return overrides
+}
+
+// SetOverrides sets the overrides applied on top of the file. Later entries
+// take precedence over earlier ones for the same field.
+func (s *AppConfigService) SetOverrides(overrides []models.ConfigOverride) error {
+	for _, o := range overrides {
+		if _, ok := findOverrideField(o.Field); !ok {
+			return fmt.Errorf("config field %q cannot be overridden", o.Field)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides = append([]models.ConfigOverride(nil), overrides...)
+	return nil
+}
+
+// Overrides returns the overrides in effect.
+func (s *AppConfigService) Overrides() []models.ConfigOverride {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.ConfigOverride{}, s.overrides...)
+}
+
+// applyOverrides must be called with the lock held.
+func (s *AppConfigService) applyOverrides(config *models.AppConfig) {
+	for _, o := range s.overrides {
+		if f, ok := findOverrideField(o.Field); ok {
+			*f.target(config) = o.Value
+		}
+	}
+}
+
+// restoreOverridden replaces overridden values of config with the values of
+// file, so that saving an effective config does not persist the overrides.
+// It must be called with the lock held.
+func (s *AppConfigService) restoreOverridden(config, file *models.AppConfig) {
+	for _, o := range s.overrides {
+		f, ok := findOverrideField(o.Field)
+		if ok && *f.target(config) == o.Value {
+			*f.target(config) = *f.target(file)
+		}
+	}
+}
+
+// OpenAppConfigService opens the config file at configPath, or at
+// FMOC_CONFIG or the default location if configPath is empty, and applies
+// the overrides.
+func OpenAppConfigService(configPath string, overrides []models.ConfigOverride) (*AppConfigService, error) {
+	if configPath == "" {
+		configPath = os.Getenv(ConfigPathEnv)
+	}
+	var service *AppConfigService
+	var err error
+	if configPath != "" {
+		service, err = NewAppConfigServiceAt(configPath)
+	} else {
+		service, err = NewAppConfigService()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := service.SetOverrides(overrides); err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+// OpenAppConfigServiceReadOnly is like OpenAppConfigService but never writes
+// to disk: a missing file reads as the defaults and older files are migrated
+// in memory only. Updates are rejected.
+func OpenAppConfigServiceReadOnly(configPath string, overrides []models.ConfigOverride) (*AppConfigService, error) {
+	if configPath == "" {
+		configPath = os.Getenv(ConfigPathEnv)
+	}
+	if configPath == "" {
+		appDir, err := appDataPath()
+		if err != nil {
+			return nil, err
+		}
+		configPath = filepath.Join(appDir, "config.json")
+	}
+	service := &AppConfigService{configPath: configPath, readOnly: true}
+	if err := service.SetOverrides(overrides); err != nil {
+		return nil, err
+	}
+	return service, nil
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"fm-opencode-tinyapp/internal/models"
+)
+
+// configValidator collects field errors.
+type configValidator struct {
+	errors []models.ConfigFieldError
+}
+
+func (v *configValidator) add(field, format string, args ...interface{}) {
+	v.errors = append(v.errors, models.ConfigFieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *configValidator) httpURL(field, value string, required bool) {
+	if value == "" {
+		if required {
+			v.add(field, "is required")
+		}
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		v.add(field, "is not a valid URL: %v", err)
+		return
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		v.add(field, "must be an http or https URL")
+		return
+	}
+	if u.Host == "" {
+		v.add(field, "must include a host")
+	}
+}
+
+func (v *configValidator) nonNegative(field string, value float64) {
+	if value < 0 {
+		v.add(field, "must not be negative")
+	}
+}
+
+// ValidateAppConfig checks the config and returns a *models.ConfigValidationError
+// listing every invalid field, or nil.
+func ValidateAppConfig(config *models.AppConfig) error {
+	v := &configValidator{}
+
+	v.httpURL("serverURL", config.ServerURL, true)
+
+	switch config.LLM.Adapter {
+	case "", models.LLMAdapterOpenAI, models.LLMAdapterAnthropic, models.LLMAdapterAzure, models.LLMAdapterOllama:
+	default:
+		v.add("llm.adapter", "must be one of openai, anthropic, azure or ollama")
+	}
+	v.httpURL("llm.baseURL", config.LLM.BaseURL, false)
+	v.nonNegative("llm.timeout", float64(config.LLM.Timeout))
+	v.nonNegative("llm.maxTokens", float64(config.LLM.MaxTokens))
+
+	v.nonNegative("budget.sessionCost", config.Budget.SessionCost)
+	v.nonNegative("budget.sessionTokens", float64(config.Budget.SessionTokens))
+	v.nonNegative("budget.dailyCost", config.Budget.DailyCost)
+
+	if t := config.Compaction.Threshold; t < 0 || t > 100 {
+		v.add("compaction.threshold", "must be between 0 and 100")
+	}
+
+	for i, rule := range config.Permission.Rules {
+		field := fmt.Sprintf("permission.rules[%d]", i)
+		switch rule.Action {
+		case models.PermissionOnce, models.PermissionAlways, models.PermissionReject, models.PermissionAsk:
+		default:
+			v.add(field+".action", "must be one of once, always, reject or ask")
+		}
+		patterns := []struct {
+			name, pattern string
+			mode          globMode
+		}{{"tool", rule.Tool, globText}, {"command", rule.Command, globCommand}, {"path", rule.Path, globPath}}
+		for _, p := range patterns {
+			if _, err := globToRegexp(p.pattern, p.mode); err != nil {
+				v.add(field+"."+p.name, "is not a valid pattern: %v", err)
+			}
+		}
+	}
+
+	v.nonNegative("hooks.maxConcurrent", float64(config.Hooks.MaxConcurrent))
+	for i, hook := range config.Hooks.Hooks {
+		field := fmt.Sprintf("hooks.hooks[%d]", i)
+		if hook.Event == "" {
+			v.add(field+".event", "is required")
+		}
+		if strings.TrimSpace(hook.Command) == "" {
+			v.add(field+".command", "is required")
+		}
+		v.nonNegative(field+".timeout", float64(hook.Timeout))
+	}
+
+	names := make(map[string]bool)
+	for i, template := range config.Templates {
+		field := fmt.Sprintf("templates[%d]", i)
+		if template.Name == "" {
+			v.add(field+".name", "is required")
+		} else if names[template.Name] {
+			v.add(field+".name", "duplicate template name %q", template.Name)
+		}
+		names[template.Name] = true
+		if template.Prompt == "" && template.Name != "polish" {
+			v.add(field+".prompt", "is required")
+		}
+	}
+
+	if len(v.errors) > 0 {
+		return &models.ConfigValidationError{Errors: v.errors}
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"fm-opencode-tinyapp/internal/api"
+	"fm-opencode-tinyapp/internal/models"
+)
+
+const diagnosticTimeout = 5 * time.Second
+
+// Names of the checks run by the diagnostics.
+const (
+	DiagnosticServerReachable = "server.reachable"
+	DiagnosticServerConfig    = "server.config"
+	DiagnosticEventStream     = "server.events"
+	DiagnosticOpenCodeVersion = "opencode.version"
+	DiagnosticServerProcess   = "server.process"
+	DiagnosticLLMEndpoint     = "llm.endpoint"
+)
+
+// CheckServerReachability opens a TCP connection to the server and reports
+// how long it took.
+func CheckServerReachability(serverURL string) models.DiagnosticCheck {
+	check := models.DiagnosticCheck{Name: DiagnosticServerReachable}
+	host, port, err := serverListenAddress(serverURL)
+	if err != nil {
+		return failCheck(check, err)
+	}
+	started := time.Now()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(dialHost(host), port), diagnosticTimeout)
+	if err != nil {
+		return failCheck(check, err)
+	}
+	check.LatencyMs = time.Since(started).Milliseconds()
+	conn.Close()
+	check.Status = models.DiagnosticOK
+	check.Message = fmt.Sprintf("connected in %d ms", check.LatencyMs)
+	return check
+}
+
+var diagnosticClient = &http.Client{Timeout: diagnosticTimeout}
+
+// CheckServerConfig requests /config from the server and checks that it
+// answers with a JSON object.
+func CheckServerConfig(serverURL string) models.DiagnosticCheck {
+	check := models.DiagnosticCheck{Name: DiagnosticServerConfig}
+	started := time.Now()
+	resp, err := diagnosticClient.Get(strings.TrimRight(serverURL, "/") + "/config")
+	if err != nil {
+		return failCheck(check, err)
+	}
+	defer resp.Body.Close()
+	check.LatencyMs = time.Since(started).Milliseconds()
+	if resp.StatusCode != http.StatusOK {
+		return failCheck(check, fmt.Errorf("unexpected status code: %d", resp.StatusCode))
+	}
+	var config map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&config); err != nil {
+		return failCheck(check, fmt.Errorf("failed to decode response: %w", err))
+	}
+	check.Status = models.DiagnosticOK
+	check.Message = fmt.Sprintf("answered in %d ms", check.LatencyMs)
+	return check
+}
+
+// CheckEventStream reports the state of the event stream connection.
+func CheckEventStream(status models.StreamStatus) models.DiagnosticCheck {
+	check := models.DiagnosticCheck{Name: DiagnosticEventStream, Message: status.State}
+	switch status.State {
+	case models.StreamStateConnected:
+		check.Status = models.DiagnosticOK
+		if status.LastEventAt > 0 {
+			age := time.Since(time.UnixMilli(status.LastEventAt)).Round(time.Second)
+			check.Message = fmt.Sprintf("connected, last event %s ago", age)
+		} else {
+			check.Message = "connected, no events received yet"
+		}
+	case models.StreamStateConnecting, models.StreamStateIdle:
+		check.Status = models.DiagnosticWarning
+	default:
+		check.Status = models.DiagnosticError
+		if status.LastError != "" {
+			check.Message += ": " + status.LastError
+		}
+	}
+	return check
+}
+
+// CheckServerProcess reports the state of the managed server process.
+func CheckServerProcess(status models.ServerStatus) models.DiagnosticCheck {
+	check := models.DiagnosticCheck{Name: DiagnosticServerProcess, Message: status.State}
+	switch status.State {
+	case models.ServerStateRunning:
+		check.Status = models.DiagnosticOK
+		check.Message = fmt.Sprintf("running (pid %d, %d restarts)", status.PID, status.Restarts)
+	case models.ServerStateExternal:
+		check.Status = models.DiagnosticSkipped
+		check.Message = "not managed by the app"
+	case models.ServerStateStarting:
+		check.Status = models.DiagnosticWarning
+	default:
+		check.Status = models.DiagnosticError
+		if status.LastError != "" {
+			check.Message += ": " + status.LastError
+		}
+	}
+	return check
+}
+
+// CheckOpenCodeVersion runs the configured opencode binary with --version
+// and returns the check together with the version.
+func CheckOpenCodeVersion(ctx context.Context, config models.ServerProcess) (models.DiagnosticCheck, string) {
+	check := models.DiagnosticCheck{Name: DiagnosticOpenCodeVersion}
+	binary := config.Binary
+	if binary == "" {
+		binary = defaultServerBinary
+	}
+	ctx, cancel := context.WithTimeout(ctx, diagnosticTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, binary, "--version")
+	cmd.Env = serverEnv(config.Env)
+	output, err := cmd.Output()
+	if err != nil {
+		check.Status = models.DiagnosticWarning
+		check.Message = fmt.Sprintf("failed to run %s --version: %v", binary, err)
+		return check, ""
+	}
+	version := strings.TrimSpace(string(output))
+	check.Status = models.DiagnosticOK
+	check.Message = version
+	return check, version
+}
+
+// CheckServerVersion asks the server for its version. It is used instead of
+// CheckOpenCodeVersion when the server is not started by the app, since the
+// local binary may differ from the one serving.
+func CheckServerVersion(serverURL string) (models.DiagnosticCheck, string) {
+	check := models.DiagnosticCheck{Name: DiagnosticOpenCodeVersion}
+	resp, err := diagnosticClient.Get(strings.TrimRight(serverURL, "/") + "/global/health")
+	if err != nil {
+		check.Status = models.DiagnosticWarning
+		check.Message = fmt.Sprintf("failed to get the server version: %v", err)
+		return check, ""
+	}
+	defer resp.Body.Close()
+	var health struct {
+		Version string `json:"version"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	} else if err = json.NewDecoder(resp.Body).Decode(&health); err == nil && health.Version == "" {
+		err = fmt.Errorf("no version in response")
+	}
+	if err != nil {
+		check.Status = models.DiagnosticWarning
+		check.Message = fmt.Sprintf("failed to get the server version: %v", err)
+		return check, ""
+	}
+	check.Status = models.DiagnosticOK
+	check.Message = health.Version + " (reported by the server)"
+	return check, health.Version
+}
+
+// CheckLLMEndpoint checks that the LLM endpoint is reachable and accepts the
+// credentials. A nil client means the LLM is not configured.
+func CheckLLMEndpoint(ctx context.Context, client *api.LLMClient) models.DiagnosticCheck {
+	check := models.DiagnosticCheck{Name: DiagnosticLLMEndpoint}
+	if client == nil {
+		check.Status = models.DiagnosticSkipped
+		check.Message = "LLM is not configured"
+		return check
+	}
+	ctx, cancel := context.WithTimeout(ctx, diagnosticTimeout)
+	defer cancel()
+	started := time.Now()
+	err := client.Ping(ctx)
+	check.LatencyMs = time.Since(started).Milliseconds()
+	if err != nil {
+		return failCheck(check, err)
+	}
+	check.Status = models.DiagnosticOK
+	check.Message = fmt.Sprintf("answered in %d ms", check.LatencyMs)
+	return check
+}
+
+func failCheck(check models.DiagnosticCheck, err error) models.DiagnosticCheck {
+	check.Status = models.DiagnosticError
+	check.Message = err.Error()
+	return check
+}
+
+// secretPatterns match credentials that commonly show up in URLs and logs.
+// The credential is the last group; the groups before it are kept.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(://[^/\s:@"]+:)([^/\s@"]+)@`),
+	regexp.MustCompile(`(?i)(bearer\s+)([A-Za-z0-9._~+/=-]{8,})`),
+	regexp.MustCompile(`\b(sk-)([A-Za-z0-9_-]{16,})`),
+	regexp.MustCompile(`(?i)((?:api[_-]?key|token|secret|password)["']?\s*[:=]\s*["']?)([^\s"',}]{4,})`),
+}
+
+// RedactBundle removes secrets from a diagnostics bundle: the LLM API key,
+// server environment values, credentials in URLs, the given secret values
+// and anything in the text that looks like a credential.
+func RedactBundle(bundle *models.DiagnosticsBundle, secrets []string) ([]byte, error) {
+	bundle.Config = bundle.Config.Masked()
+	if len(bundle.Config.Server.Env) > 0 {
+		env := make(map[string]string, len(bundle.Config.Server.Env))
+		for key, value := range bundle.Config.Server.Env {
+			env[key] = models.SecretMask
+			secrets = append(secrets, value)
+		}
+		bundle.Config.Server.Env = env
+	}
+
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal diagnostics: %w", err)
+	}
+	text := string(data)
+
+	for _, pattern := range secretPatterns {
+		text = pattern.ReplaceAllStringFunc(text, func(match string) string {
+			groups := pattern.FindStringSubmatchIndex(match)
+			credential := len(groups)/2 - 1
+			return match[:groups[2*credential]] + models.SecretMask + match[groups[2*credential+1]:]
+		})
+	}
+
+	// Replace longer secrets first so that a secret containing another is
+	// not left partially visible.
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	for _, secret := range secrets {
+		if len(secret) >= 4 {
+			text = strings.ReplaceAll(text, secret, models.SecretMask)
+		}
+	}
+	return []byte(text), nil
+}
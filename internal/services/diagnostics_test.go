@@ -0,0 +1,44 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fm-opencode-tinyapp/internal/models"
+)
+
+func TestCheckServerVersion(t *testing.T) {
+	cases := []struct {
+		name        string
+		status      int
+		body        string
+		wantStatus  string
+		wantVersion string
+	}{
+		{"version reported", http.StatusOK, `{"healthy": true, "version": "0.15.3"}`, models.DiagnosticOK, "0.15.3"},
+		{"no version", http.StatusOK, `{"healthy": true}`, models.DiagnosticWarning, ""},
+		{"not found", http.StatusNotFound, `not found`, models.DiagnosticWarning, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/global/health" {
+					http.NotFound(w, r)
+					return
+				}
+				w.WriteHeader(c.status)
+				w.Write([]byte(c.body))
+			}))
+			defer server.Close()
+
+			check, version := CheckServerVersion(server.URL + "/")
+			if check.Name != DiagnosticOpenCodeVersion || check.Status != c.wantStatus {
+				t.Errorf("check = %+v, want status %s", check, c.wantStatus)
+			}
+			if version != c.wantVersion {
+				t.Errorf("version = %q, want %q", version, c.wantVersion)
+			}
+		})
+	}
+}
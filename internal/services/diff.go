@@ -0,0 +1,277 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"fm-opencode-tinyapp/internal/models"
+)
+
+// maxDiffEdits bounds the work of the diff. Texts differing by more edits
+// are reported as a single replacement of the remaining middle part.
+const maxDiffEdits = 2000
+
+// DiffText computes a structured diff between an original and a revised text.
+// Adjacent changes separated only by whitespace or a single character are
+// merged into one hunk so that each hunk reads as a meaningful edit.
+func DiffText(original, revised string, granularity models.DiffGranularity) *models.TextDiff {
+	a := tokenizeForDiff(original, granularity)
+	b := tokenizeForDiff(revised, granularity)
+
+	// Common prefix and suffix are cheap to strip before the O(ND) search.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []tokenEdit
+	for _, tok := range a[:prefix] {
+		edits = append(edits, tokenEdit{models.DiffEqual, tok})
+	}
+	edits = append(edits, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, tok := range a[len(a)-suffix:] {
+		edits = append(edits, tokenEdit{models.DiffEqual, tok})
+	}
+
+	diff := &models.TextDiff{Original: original, Revised: revised}
+	diff.Spans, diff.Hunks = buildHunks(mergeEdits(edits))
+	return diff
+}
+
+// ApplyDiffHunks rebuilds the text keeping the revision of the accepted hunks
+// and the original text of all others.
+func ApplyDiffHunks(diff *models.TextDiff, accepted []int) (string, error) {
+	if diff == nil {
+		return "", fmt.Errorf("diff is required")
+	}
+	keep := make(map[int]bool, len(accepted))
+	for _, index := range accepted {
+		if index < 0 || index >= len(diff.Hunks) {
+			return "", fmt.Errorf("hunk %d out of range", index)
+		}
+		keep[index] = true
+	}
+
+	var sb strings.Builder
+	for _, span := range diff.Spans {
+		switch span.Op {
+		case models.DiffEqual:
+			sb.WriteString(span.Text)
+		case models.DiffDelete:
+			if !keep[span.Hunk] {
+				sb.WriteString(span.Text)
+			}
+		case models.DiffInsert:
+			if keep[span.Hunk] {
+				sb.WriteString(span.Text)
+			}
+		}
+	}
+	return sb.String(), nil
+}
+
+type tokenEdit struct {
+	op    models.DiffOp
+	token string
+}
+
+// tokenizeForDiff splits text into diff tokens. In word mode runs of Latin
+// letters and digits form one token, while CJK characters, punctuation and
+// other symbols are tokens on their own; whitespace runs are kept together.
+func tokenizeForDiff(text string, granularity models.DiffGranularity) []string {
+	var tokens []string
+	if granularity == models.DiffByChar {
+		for _, r := range text {
+			tokens = append(tokens, string(r))
+		}
+		return tokens
+	}
+
+	const (
+		classOther = iota
+		classWord
+		classSpace
+	)
+	classify := func(r rune) int {
+		switch {
+		case unicode.IsSpace(r):
+			return classSpace
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			return classOther
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			return classWord
+		}
+		return classOther
+	}
+
+	start := 0
+	prevClass := -1
+	for i, r := range text {
+		class := classify(r)
+		if i > start && (class != prevClass || class == classOther) {
+			tokens = append(tokens, text[start:i])
+			start = i
+		}
+		prevClass = class
+	}
+	if start < len(text) {
+		tokens = append(tokens, text[start:])
+	}
+	return tokens
+}
+
+// myersDiff returns the shortest edit script turning a into b.
+func myersDiff(a, b []string) []tokenEdit {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replaceAll(a, b)
+	}
+
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	// trace[d] holds v for k in [-d-1, d+1] before step d.
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		if d > maxDiffEdits {
+			return replaceAll(a, b)
+		}
+		snapshot := make([]int, 2*d+3)
+		copy(snapshot, v[offset-d-1:offset+d+2])
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrackEdits(a, b, trace)
+			}
+		}
+	}
+	return replaceAll(a, b)
+}
+
+func backtrackEdits(a, b []string, trace [][]int) []tokenEdit {
+	var reversed []tokenEdit
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		at := func(k int) int { return v[k+d+1] }
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			reversed = append(reversed, tokenEdit{models.DiffEqual, a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, tokenEdit{models.DiffInsert, b[y-1]})
+			} else {
+				reversed = append(reversed, tokenEdit{models.DiffDelete, a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	edits := make([]tokenEdit, len(reversed))
+	for i, edit := range reversed {
+		edits[len(reversed)-1-i] = edit
+	}
+	return edits
+}
+
+func replaceAll(a, b []string) []tokenEdit {
+	edits := make([]tokenEdit, 0, len(a)+len(b))
+	for _, tok := range a {
+		edits = append(edits, tokenEdit{models.DiffDelete, tok})
+	}
+	for _, tok := range b {
+		edits = append(edits, tokenEdit{models.DiffInsert, tok})
+	}
+	return edits
+}
+
+// mergeEdits coalesces token edits into spans and turns short equal runs
+// between two changes into a deletion plus insertion.
+func mergeEdits(edits []tokenEdit) []models.DiffSpan {
+	var spans []models.DiffSpan
+	for _, edit := range edits {
+		if n := len(spans); n > 0 && spans[n-1].Op == edit.op {
+			spans[n-1].Text += edit.token
+			continue
+		}
+		spans = append(spans, models.DiffSpan{Op: edit.op, Text: edit.token, Hunk: -1})
+	}
+
+	for i := 1; i < len(spans)-1; i++ {
+		span := spans[i]
+		if span.Op != models.DiffEqual || spans[i-1].Op == models.DiffEqual || spans[i+1].Op == models.DiffEqual {
+			continue
+		}
+		if strings.TrimSpace(span.Text) == "" || utf8.RuneCountInString(span.Text) == 1 {
+			spans[i].Op = models.DiffDelete
+			spans = append(spans[:i+1], append([]models.DiffSpan{{Op: models.DiffInsert, Text: span.Text, Hunk: -1}}, spans[i+1:]...)...)
+		}
+	}
+	return spans
+}
+
+// buildHunks groups consecutive changes into hunks, emitting the deletion of
+// each hunk before its insertion.
+func buildHunks(spans []models.DiffSpan) ([]models.DiffSpan, []models.DiffHunk) {
+	result := []models.DiffSpan{}
+	hunks := []models.DiffHunk{}
+	offset := 0
+	for i := 0; i < len(spans); {
+		if spans[i].Op == models.DiffEqual {
+			result = append(result, spans[i])
+			offset += utf8.RuneCountInString(spans[i].Text)
+			i++
+			continue
+		}
+
+		hunk := models.DiffHunk{Index: len(hunks), Offset: offset}
+		for ; i < len(spans) && spans[i].Op != models.DiffEqual; i++ {
+			if spans[i].Op == models.DiffDelete {
+				hunk.Original += spans[i].Text
+			} else {
+				hunk.Replacement += spans[i].Text
+			}
+		}
+		if hunk.Original != "" {
+			result = append(result, models.DiffSpan{Op: models.DiffDelete, Text: hunk.Original, Hunk: hunk.Index})
+		}
+		if hunk.Replacement != "" {
+			result = append(result, models.DiffSpan{Op: models.DiffInsert, Text: hunk.Replacement, Hunk: hunk.Index})
+		}
+		offset += utf8.RuneCountInString(hunk.Original)
+		hunks = append(hunks, hunk)
+	}
+	return result, hunks
+}
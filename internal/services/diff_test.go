@@ -0,0 +1,135 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"fm-opencode-tinyapp/internal/models"
+)
+
+func allHunks(diff *models.TextDiff) []int {
+	accepted := make([]int, len(diff.Hunks))
+	for i := range accepted {
+		accepted[i] = i
+	}
+	return accepted
+}
+
+func TestDiffTextRoundTrip(t *testing.T) {
+	cases := []struct {
+		name        string
+		original    string
+		revised     string
+		granularity models.DiffGranularity
+	}{
+		{"identical", "same text", "same text", models.DiffByWord},
+		{"empty original", "", "new text", models.DiffByWord},
+		{"empty revised", "old text", "", models.DiffByWord},
+		{"words", "The quick brown fox jumps over the lazy dog.", "The quick red fox jumped over a lazy dog!", models.DiffByWord},
+		{"whitespace", "a  b\tc\nd", "a b c\n\nd", models.DiffByWord},
+		{"japanese words", "今日は天気が良いので散歩に行きます。", "今日は天気が悪いので家で本を読みます。", models.DiffByWord},
+		{"japanese chars", "ありがとうございます", "ありがとうございました", models.DiffByChar},
+		{"mixed", "opencode の設定を変更した", "OpenCode の config を変更しました", models.DiffByWord},
+		{"emoji", "完了 🎉 です", "完了しました 🎉🎉", models.DiffByChar},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			diff := DiffText(c.original, c.revised, c.granularity)
+
+			got, err := ApplyDiffHunks(diff, allHunks(diff))
+			if err != nil {
+				t.Fatalf("ApplyDiffHunks(all): %v", err)
+			}
+			if got != c.revised {
+				t.Errorf("all hunks applied = %q, want %q", got, c.revised)
+			}
+
+			got, err = ApplyDiffHunks(diff, nil)
+			if err != nil {
+				t.Fatalf("ApplyDiffHunks(none): %v", err)
+			}
+			if got != c.original {
+				t.Errorf("no hunks applied = %q, want %q", got, c.original)
+			}
+
+			if c.original == c.revised && len(diff.Hunks) != 0 {
+				t.Errorf("identical texts produced %d hunks", len(diff.Hunks))
+			}
+		})
+	}
+}
+
+func TestDiffTextHunks(t *testing.T) {
+	diff := DiffText("今日は天気が良い。明日は雨です。", "今日は天気が悪い。明日は晴れです。", models.DiffByWord)
+	if len(diff.Hunks) != 2 {
+		t.Fatalf("hunks = %+v, want 2", diff.Hunks)
+	}
+	if h := diff.Hunks[0]; h.Original != "良" || h.Replacement != "悪" || h.Offset != 6 {
+		t.Errorf("hunk 0 = %+v", h)
+	}
+	if h := diff.Hunks[1]; h.Original != "雨" || h.Replacement != "晴れ" || h.Offset != 12 {
+		t.Errorf("hunk 1 = %+v", h)
+	}
+
+	// Accepting only the second hunk keeps the first from the original.
+	got, err := ApplyDiffHunks(diff, []int{1})
+	if err != nil {
+		t.Fatalf("ApplyDiffHunks: %v", err)
+	}
+	if want := "今日は天気が良い。明日は晴れです。"; got != want {
+		t.Errorf("ApplyDiffHunks = %q, want %q", got, want)
+	}
+
+	if _, err := ApplyDiffHunks(diff, []int{2}); err == nil {
+		t.Error("ApplyDiffHunks accepted an out-of-range hunk")
+	}
+}
+
+func TestDiffTextWordTokens(t *testing.T) {
+	diff := DiffText("update the config file", "update the configuration file", models.DiffByWord)
+	if len(diff.Hunks) != 1 {
+		t.Fatalf("hunks = %+v, want 1", diff.Hunks)
+	}
+	if h := diff.Hunks[0]; h.Original != "config" || h.Replacement != "configuration" {
+		t.Errorf("hunk = %+v, want the whole word replaced", h)
+	}
+}
+
+func TestDiffTextEditCap(t *testing.T) {
+	build := func(changed string, count int) string {
+		var sb strings.Builder
+		sb.WriteString("始め")
+		for i := 0; i < count; i++ {
+			sb.WriteString(changed + "同じ")
+		}
+		sb.WriteString("終わり")
+		return sb.String()
+	}
+
+	// Within the cap every changed character is its own hunk.
+	small := DiffText(build("あ", 100), build("い", 100), models.DiffByChar)
+	if len(small.Hunks) != 100 {
+		t.Errorf("got %d hunks below the cap, want 100", len(small.Hunks))
+	}
+
+	// Each change needs a deletion and an insertion, so this exceeds the cap
+	// and the differing middle is replaced as a whole.
+	count := maxDiffEdits/2 + 1
+	original, revised := build("あ", count), build("い", count)
+	diff := DiffText(original, revised, models.DiffByChar)
+	if len(diff.Hunks) != 1 {
+		t.Fatalf("got %d hunks above the cap, want 1", len(diff.Hunks))
+	}
+	if h := diff.Hunks[0]; h.Offset != 2 || !strings.HasPrefix(h.Original, "あ") || !strings.HasPrefix(h.Replacement, "い") {
+		t.Errorf("hunk does not start at the first change: offset %d", h.Offset)
+	}
+
+	got, err := ApplyDiffHunks(diff, allHunks(diff))
+	if err != nil || got != revised {
+		t.Errorf("all hunks applied does not give the revision (err %v)", err)
+	}
+	got, err = ApplyDiffHunks(diff, nil)
+	if err != nil || got != original {
+		t.Errorf("no hunks applied does not give the original (err %v)", err)
+	}
+}
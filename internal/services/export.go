@@ -0,0 +1,420 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"fm-opencode-tinyapp/internal/api"
+	"fm-opencode-tinyapp/internal/models"
+)
+
+// ExportService handles exporting sessions to files.
+type ExportService struct {
+	apiClient *api.Client
+}
+
+// exportNow returns the export time; replaced in tests.
+var exportNow = time.Now
+
+// NewExportService creates a new ExportService.
+func NewExportService(apiClient *api.Client) *ExportService {
+	return &ExportService{apiClient: apiClient}
+}
+
+// ExportSession writes the session to path in the format given by options.
+func (s *ExportService) ExportSession(ctx context.Context, sessionID string, path string, options *models.ExportOptions) error {
+	if path == "" {
+		return fmt.Errorf("export path is empty")
+	}
+	if options == nil {
+		options = &models.ExportOptions{}
+	}
+
+	data, err := s.RenderSession(ctx, sessionID, options)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0640); err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+	return nil
+}
+
+// RenderSession renders the session in the format given by options without writing it.
+func (s *ExportService) RenderSession(ctx context.Context, sessionID string, options *models.ExportOptions) ([]byte, error) {
+	session, err := s.apiClient.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	messages, err := s.apiClient.GetMessages(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+
+	switch normalizeExportFormat(options.Format) {
+	case models.ExportFormatJSON:
+		return renderExportJSON(session, messages, options)
+	case models.ExportFormatHTML:
+		return renderExportHTML(buildExportView(session, messages, options))
+	case models.ExportFormatMarkdown:
+		return renderExportMarkdown(buildExportView(session, messages, options)), nil
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", options.Format)
+	}
+}
+
+func normalizeExportFormat(format string) string {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", "md", models.ExportFormatMarkdown:
+		return models.ExportFormatMarkdown
+	case models.ExportFormatJSON:
+		return models.ExportFormatJSON
+	case "htm", models.ExportFormatHTML:
+		return models.ExportFormatHTML
+	}
+	return format
+}
+
+// === JSON ===
+
+func renderExportJSON(session *models.Session, messages []models.MessageWithParts, options *models.ExportOptions) ([]byte, error) {
+	export := models.SessionExport{
+		Version:    models.SessionExportVersion,
+		ExportedAt: exportNow().UnixMilli(),
+		Session:    session,
+		Messages:   make([]json.RawMessage, 0, len(messages)),
+	}
+
+	for _, msg := range messages {
+		raw := msg.Raw
+		if raw == nil {
+			encoded, err := json.Marshal(msg)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal message: %w", err)
+			}
+			raw = encoded
+		}
+		if options.HideReasoning || options.HideToolOutputs || options.RedactPaths {
+			filtered, err := filterRawMessage(raw, options)
+			if err != nil {
+				return nil, err
+			}
+			raw = filtered
+		}
+		export.Messages = append(export.Messages, raw)
+	}
+
+	if options.RedactPaths && session != nil {
+		redacted := *session
+		redacted.Title = redactAbsolutePaths(redacted.Title)
+		export.Session = &redacted
+	}
+
+	return json.MarshalIndent(export, "", "  ")
+}
+
+// filterRawMessage applies the export options to a raw message without
+// dropping fields that are not modelled in the models package.
+func filterRawMessage(raw json.RawMessage, options *models.ExportOptions) (json.RawMessage, error) {
+	var msg map[string]interface{}
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+
+	if parts, ok := msg["parts"].([]interface{}); ok {
+		kept := make([]interface{}, 0, len(parts))
+		for _, p := range parts {
+			part, ok := p.(map[string]interface{})
+			if !ok {
+				kept = append(kept, p)
+				continue
+			}
+			if options.HideReasoning && part["type"] == "reasoning" {
+				continue
+			}
+			if options.HideToolOutputs && part["type"] == "tool" {
+				if state, ok := part["state"].(map[string]interface{}); ok {
+					delete(state, "output")
+					delete(state, "error")
+					delete(state, "metadata")
+				}
+			}
+			kept = append(kept, part)
+		}
+		msg["parts"] = kept
+	}
+
+	var out interface{} = msg
+	if options.RedactPaths {
+		out = redactValue(out)
+	}
+	return json.Marshal(out)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		return redactAbsolutePaths(val)
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = redactValue(item)
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = redactValue(item)
+		}
+		return val
+	}
+	return v
+}
+
+// absolutePathPattern matches Unix (/a/b) and Windows (C:\a\b) absolute paths
+// with at least two components. The first group captures the preceding
+// delimiter so that URLs such as https://host/path are left alone.
+var absolutePathPattern = regexp.MustCompile("(^|[\\s\"'(=`])((?:[A-Za-z]:[\\\\/]|/)(?:[^\\s\"'<>|*?\\\\/`]+[\\\\/])+[^\\s\"'<>|*?\\\\/`)]*)")
+
+// redactAbsolutePaths replaces absolute paths with "<redacted>/<basename>".
+func redactAbsolutePaths(s string) string {
+	return absolutePathPattern.ReplaceAllStringFunc(s, func(match string) string {
+		sub := absolutePathPattern.FindStringSubmatch(match)
+		prefix, path := sub[1], sub[2]
+		path = strings.TrimRight(path, "\\/")
+		base := path
+		if i := strings.LastIndexAny(path, "\\/"); i >= 0 {
+			base = path[i+1:]
+		}
+		return prefix + "<redacted>/" + base
+	})
+}
+
+// === Markdown / HTML ===
+
+type exportView struct {
+	Title      string
+	SessionID  string
+	Created    string
+	ExportedAt string
+	Messages   []exportMessage
+}
+
+type exportMessage struct {
+	Role   string
+	Label  string
+	Time   string
+	Blocks []exportBlock
+}
+
+type exportBlock struct {
+	Kind   string // "text", "reasoning" or "tool"
+	Text   string
+	Tool   string
+	Title  string
+	Status string
+	Input  string
+	Output string
+}
+
+func buildExportView(session *models.Session, messages []models.MessageWithParts, options *models.ExportOptions) *exportView {
+	clean := func(s string) string {
+		if options.RedactPaths {
+			return redactAbsolutePaths(s)
+		}
+		return s
+	}
+
+	view := &exportView{
+		Title:      clean(session.Title),
+		SessionID:  session.ID,
+		Created:    formatExportTime(session.Time.Created),
+		ExportedAt: exportNow().Format(time.RFC3339),
+	}
+	if view.Title == "" {
+		view.Title = session.ID
+	}
+
+	for _, msg := range messages {
+		if msg.Info == nil {
+			continue
+		}
+		em := exportMessage{Role: msg.Info.GetRole()}
+		switch info := msg.Info.(type) {
+		case models.UserMessage:
+			em.Label = "User"
+			em.Time = formatExportTime(info.Time.Created)
+		case models.AssistantMessage:
+			em.Label = "Assistant"
+			if info.ModelID != "" {
+				em.Label = fmt.Sprintf("Assistant (%s/%s)", info.ProviderID, info.ModelID)
+			}
+			em.Time = formatExportTime(info.Time.Created)
+		}
+
+		for _, part := range msg.Parts {
+			switch p := part.(type) {
+			case models.TextPart:
+				if strings.TrimSpace(p.Text) == "" {
+					continue
+				}
+				em.Blocks = append(em.Blocks, exportBlock{Kind: "text", Text: clean(p.Text)})
+			case models.ReasoningPart:
+				if options.HideReasoning || strings.TrimSpace(p.Text) == "" {
+					continue
+				}
+				em.Blocks = append(em.Blocks, exportBlock{Kind: "reasoning", Text: clean(p.Text)})
+			case models.ToolPart:
+				block := exportBlock{Kind: "tool", Tool: p.Tool, Status: p.Status()}
+				state := p.StateMap()
+				if title, ok := state["title"].(string); ok {
+					block.Title = clean(title)
+				}
+				if input, ok := state["input"]; ok && input != nil {
+					if encoded, err := json.MarshalIndent(input, "", "  "); err == nil {
+						block.Input = clean(string(encoded))
+					}
+				}
+				if !options.HideToolOutputs {
+					if output, ok := state["output"].(string); ok {
+						block.Output = clean(output)
+					} else if errText, ok := state["error"].(string); ok {
+						block.Output = clean(errText)
+					}
+				}
+				em.Blocks = append(em.Blocks, block)
+			}
+		}
+
+		if len(em.Blocks) > 0 {
+			view.Messages = append(view.Messages, em)
+		}
+	}
+
+	return view
+}
+
+func formatExportTime(ms int64) string {
+	if ms <= 0 {
+		return ""
+	}
+	return time.UnixMilli(ms).Format(time.RFC3339)
+}
+
+func renderExportMarkdown(view *exportView) []byte {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", view.Title)
+	fmt.Fprintf(&b, "- Session ID: `%s`\n", view.SessionID)
+	if view.Created != "" {
+		fmt.Fprintf(&b, "- Created: %s\n", view.Created)
+	}
+	fmt.Fprintf(&b, "- Exported: %s\n", view.ExportedAt)
+
+	for _, msg := range view.Messages {
+		b.WriteString("\n---\n\n")
+		fmt.Fprintf(&b, "## %s", msg.Label)
+		if msg.Time != "" {
+			fmt.Fprintf(&b, " — %s", msg.Time)
+		}
+		b.WriteString("\n\n")
+
+		for _, block := range msg.Blocks {
+			switch block.Kind {
+			case "text":
+				b.WriteString(strings.TrimSpace(block.Text))
+				b.WriteString("\n\n")
+			case "reasoning":
+				b.WriteString("<details>\n<summary>Reasoning</summary>\n\n")
+				b.WriteString(strings.TrimSpace(block.Text))
+				b.WriteString("\n\n</details>\n\n")
+			case "tool":
+				fmt.Fprintf(&b, "<details>\n<summary>%s</summary>\n\n", template.HTMLEscapeString(toolSummary(block)))
+				if block.Input != "" {
+					b.WriteString("**Input**\n\n")
+					writeFenced(&b, "json", block.Input)
+				}
+				if block.Output != "" {
+					b.WriteString("**Output**\n\n")
+					writeFenced(&b, "", block.Output)
+				}
+				b.WriteString("</details>\n\n")
+			}
+		}
+	}
+
+	return []byte(b.String())
+}
+
+func toolSummary(block exportBlock) string {
+	summary := "Tool: " + block.Tool
+	if block.Title != "" {
+		summary += " — " + block.Title
+	}
+	if block.Status != "" {
+		summary += " (" + block.Status + ")"
+	}
+	return summary
+}
+
+// writeFenced writes content in a code fence long enough not to be closed
+// by backticks inside the content.
+func writeFenced(b *strings.Builder, lang string, content string) {
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+	fmt.Fprintf(b, "%s%s\n%s\n%s\n\n", fence, lang, strings.TrimRight(content, "\n"), fence)
+}
+
+var exportHTMLTemplate = template.Must(template.New("export").Funcs(template.FuncMap{
+	"toolSummary": toolSummary,
+}).Parse(`<!DOCTYPE html>
+<html lang="ja">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { margin: 0; padding: 2rem; background: #1b2636; color: #e6e6e6; font-family: -apple-system, "Segoe UI", "Hiragino Sans", "Noto Sans JP", sans-serif; line-height: 1.6; }
+main { max-width: 960px; margin: 0 auto; }
+h1 { font-size: 1.5rem; margin-bottom: 0.25rem; }
+.meta { color: #9aa5b1; font-size: 0.85rem; margin-bottom: 2rem; }
+.message { border-radius: 8px; padding: 1rem 1.25rem; margin-bottom: 1rem; background: #233144; }
+.message.user { background: #2c3e57; }
+.header { font-weight: bold; margin-bottom: 0.5rem; }
+.header .time { font-weight: normal; color: #9aa5b1; font-size: 0.8rem; margin-left: 0.5rem; }
+.text { white-space: pre-wrap; word-break: break-word; }
+details { margin: 0.5rem 0; border: 1px solid #3a4a60; border-radius: 6px; padding: 0.25rem 0.75rem; }
+details.reasoning { color: #b8c2cc; font-style: italic; }
+summary { cursor: pointer; color: #9ecbff; }
+pre { background: #111a26; padding: 0.75rem; border-radius: 4px; overflow-x: auto; white-space: pre-wrap; word-break: break-word; }
+.label { color: #9aa5b1; font-size: 0.8rem; margin-top: 0.5rem; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Title}}</h1>
+<div class="meta">Session ID: {{.SessionID}}{{if .Created}} · Created: {{.Created}}{{end}} · Exported: {{.ExportedAt}}</div>
+{{range .Messages}}<section class="message {{.Role}}">
+<div class="header">{{.Label}}{{if .Time}}<span class="time">{{.Time}}</span>{{end}}</div>
+{{range .Blocks}}{{if eq .Kind "text"}}<div class="text">{{.Text}}</div>
+{{else if eq .Kind "reasoning"}}<details class="reasoning"><summary>Reasoning</summary><div class="text">{{.Text}}</div></details>
+{{else if eq .Kind "tool"}}<details class="tool"><summary>{{toolSummary .}}</summary>{{if .Input}}<div class="label">Input</div><pre>{{.Input}}</pre>{{end}}{{if .Output}}<div class="label">Output</div><pre>{{.Output}}</pre>{{end}}</details>
+{{end}}{{end}}</section>
+{{end}}</main>
+</body>
+</html>
+`))
+
+func renderExportHTML(view *exportView) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := exportHTMLTemplate.Execute(&buf, view); err != nil {
+		return nil, fmt.Errorf("failed to render html: %w", err)
+	}
+	return buf.Bytes(), nil
+}
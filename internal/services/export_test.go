@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fm-opencode-tinyapp/internal/api"
+	"fm-opencode-tinyapp/internal/models"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+const exportSessionJSON = `{"id": "ses_1", "projectID": "prj_1", "title": "Fix /home/alice/app/main.go",
+	"time": {"created": 1700000000000, "updated": 1700000100000}}`
+
+const exportMessagesJSON = `[
+	{"info": {"id": "msg_1", "sessionID": "ses_1", "role": "user", "time": {"created": 1700000000000}},
+	 "parts": [{"id": "prt_1", "sessionID": "ses_1", "messageID": "msg_1", "type": "text", "text": "Read /home/alice/app/main.go"}]},
+	{"info": {"id": "msg_2", "sessionID": "ses_1", "role": "assistant", "providerID": "anthropic", "modelID": "claude",
+	          "time": {"created": 1700000001000, "completed": 1700000002000}},
+	 "parts": [
+		{"id": "prt_2", "sessionID": "ses_1", "messageID": "msg_2", "type": "reasoning", "text": "The user wants the file."},
+		{"id": "prt_3", "sessionID": "ses_1", "messageID": "msg_2", "type": "tool", "tool": "read", "callID": "call_1",
+		 "state": {"status": "completed", "title": "main.go", "input": {"filePath": "/home/alice/app/main.go"},
+		           "output": "package main", "metadata": {"lines": 1}}},
+		{"id": "prt_4", "sessionID": "ses_1", "messageID": "msg_2", "type": "tool", "tool": "bash", "callID": "call_2",
+		 "state": {"status": "error", "input": {"command": "go build"}, "error": "secret build error"}},
+		{"id": "prt_5", "sessionID": "ses_1", "messageID": "msg_2", "type": "text", "text": "The file declares ` + "`package main`" + `."}
+	 ]}
+]`
+
+func newExportTestService(t *testing.T) *ExportService {
+	t.Helper()
+	// Set up the clock before the server starts so that its goroutines see it.
+	local, now := time.Local, exportNow
+	time.Local = time.UTC
+	exportNow = func() time.Time { return time.UnixMilli(1700000200000).UTC() }
+	t.Cleanup(func() { time.Local, exportNow = local, now })
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/session/ses_1":
+			w.Write([]byte(exportSessionJSON))
+		case "/session/ses_1/message":
+			w.Write([]byte(exportMessagesJSON))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return NewExportService(api.NewClient(server.URL))
+}
+
+func TestRenderSessionGolden(t *testing.T) {
+	s := newExportTestService(t)
+	cases := []struct {
+		name    string
+		options models.ExportOptions
+		golden  string
+	}{
+		{"markdown", models.ExportOptions{Format: models.ExportFormatMarkdown}, "export.md"},
+		{"markdown without tool outputs", models.ExportOptions{Format: models.ExportFormatMarkdown, HideToolOutputs: true}, "export_hide_tool_outputs.md"},
+		{"html", models.ExportOptions{Format: models.ExportFormatHTML}, "export.html"},
+		{"html without tool outputs", models.ExportOptions{Format: models.ExportFormatHTML, HideToolOutputs: true}, "export_hide_tool_outputs.html"},
+		{"json", models.ExportOptions{Format: models.ExportFormatJSON}, "export.json"},
+		{"json without tool outputs", models.ExportOptions{Format: models.ExportFormatJSON, HideToolOutputs: true}, "export_hide_tool_outputs.json"},
+		{"markdown redacted without reasoning", models.ExportOptions{HideReasoning: true, RedactPaths: true}, "export_redacted.md"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := s.RenderSession(context.Background(), "ses_1", &c.options)
+			if err != nil {
+				t.Fatalf("RenderSession: %v", err)
+			}
+
+			if c.options.HideToolOutputs {
+				for _, hidden := range []string{"package main\n", `"package main"`, "secret build error", `"metadata"`} {
+					if strings.Contains(string(got), hidden) {
+						t.Errorf("export contains %q with HideToolOutputs", hidden)
+					}
+				}
+			}
+
+			path := filepath.Join("testdata", c.golden)
+			if *updateGolden {
+				if err := os.WriteFile(path, got, 0644); err != nil {
+					t.Fatalf("WriteFile: %v", err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile: %v (run with -update to create it)", err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("export differs from %s:\n%s", path, got)
+			}
+		})
+	}
+}
+
+func TestRenderSessionUnsupportedFormat(t *testing.T) {
+	s := newExportTestService(t)
+	if _, err := s.RenderSession(context.Background(), "ses_1", &models.ExportOptions{Format: "pdf"}); err == nil {
+		t.Error("RenderSession accepted an unsupported format")
+	}
+}
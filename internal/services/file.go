@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"fm-opencode-tinyapp/internal/api"
 	"fm-opencode-tinyapp/internal/models"
 )
@@ -16,21 +17,21 @@ func NewFileService(apiClient *api.Client) *FileService {
 }
 
 // FindInFiles searches for a pattern in files.
-func (s *FileService) FindInFiles(pattern string) ([]models.SearchResult, error) {
-	return s.apiClient.FindInFiles(pattern)
+func (s *FileService) FindInFiles(ctx context.Context, pattern string) ([]models.SearchResult, error) {
+	return s.apiClient.FindInFiles(ctx, pattern)
 }
 
 // FindFiles finds files by a query.
-func (s *FileService) FindFiles(query string) ([]string, error) {
-	return s.apiClient.FindFiles(query)
+func (s *FileService) FindFiles(ctx context.Context, query string) ([]string, error) {
+	return s.apiClient.FindFiles(ctx, query)
 }
 
 // FindSymbols finds symbols by a query.
-func (s *FileService) FindSymbols(query string) ([]models.Symbol, error) {
-	return s.apiClient.FindSymbols(query)
+func (s *FileService) FindSymbols(ctx context.Context, query string) ([]models.Symbol, error) {
+	return s.apiClient.FindSymbols(ctx, query)
 }
 
 // ReadFile reads the content of a file.
-func (s *FileService) ReadFile(path string) (*models.FileContent, error) {
-	return s.apiClient.ReadFile(path)
+func (s *FileService) ReadFile(ctx context.Context, path string) (*models.FileContent, error) {
+	return s.apiClient.ReadFile(ctx, path)
 }
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+
+	"fm-opencode-tinyapp/internal/models"
+)
+
+const (
+	defaultHookConcurrency = 4
+	defaultHookTimeout     = 30 * time.Second
+	maxHookOutput          = 64 * 1024
+	maxHookLog             = 200
+	maxHookQueue           = 64 // Runs waiting or running; more are dropped
+	// hookWaitDelay bounds the wait for output from processes the shell left
+	// running after a timeout.
+	hookWaitDelay = time.Second
+)
+
+// HookService runs local commands in response to server events.
+type HookService struct {
+	mu     sync.Mutex
+	config models.HooksConfig
+	sem    chan struct{}
+	queued int             // Runs started and not yet finished
+	fired  map[string]bool // tool part ID + status already handled
+	log    []models.HookRun
+}
+
+// NewHookService creates a new HookService.
+func NewHookService() *HookService {
+	return &HookService{
+		sem:   make(chan struct{}, defaultHookConcurrency),
+		fired: make(map[string]bool),
+	}
+}
+
+// SetConfig replaces the hook configuration.
+func (s *HookService) SetConfig(config models.HooksConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	limit := config.MaxConcurrent
+	if limit <= 0 {
+		limit = defaultHookConcurrency
+	}
+	if cap(s.sem) != limit {
+		// Runs in flight keep the old semaphore and release into it.
+		s.sem = make(chan struct{}, limit)
+	}
+	s.config = config
+}
+
+// HandleEvent starts every hook matching the event in the background, in
+// dir ("" for the app's working directory). When maxHookQueue runs are
+// already waiting or running the hook is dropped and logged as such.
+func (s *HookService) HandleEvent(event *models.Event, dir string) {
+	if event == nil {
+		return
+	}
+
+	s.mu.Lock()
+	hooks := s.config.Hooks
+	sem := s.sem
+	var matched []models.HookConfig
+	for _, hook := range hooks {
+		if !hook.Disabled && hook.Command != "" && s.hookMatches(hook, event) {
+			matched = append(matched, hook)
+		}
+	}
+	s.mu.Unlock()
+	if len(matched) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	for _, hook := range matched {
+		s.mu.Lock()
+		full := s.queued >= maxHookQueue
+		if !full {
+			s.queued++
+		}
+		s.mu.Unlock()
+		if full {
+			record := newHookRun(hook, event)
+			record.Error = "dropped: too many hooks waiting to run"
+			s.record(record)
+			continue
+		}
+		go s.run(hook, event, dir, payload, sem)
+	}
+}
+
+// hookMatches must be called with the lock held.
+func (s *HookService) hookMatches(hook models.HookConfig, event *models.Event) bool {
+	if hook.Event != event.Type {
+		return false
+	}
+	if hook.SessionID != "" && hook.SessionID != event.SessionID() {
+		return false
+	}
+	if hook.Tool == "" && hook.Status == "" {
+		return true
+	}
+
+	part, ok := event.Properties["part"].(map[string]interface{})
+	if !ok || part["type"] != "tool" {
+		return false
+	}
+	tool := models.ToolPart{State: part["state"]}
+	tool.Tool, _ = part["tool"].(string)
+	if hook.Tool != "" && hook.Tool != tool.Tool {
+		return false
+	}
+	status := tool.Status()
+	if hook.Status != "" && hook.Status != status {
+		return false
+	}
+
+	// Tool parts are updated repeatedly; run once per part and status.
+	partID, _ := part["id"].(string)
+	key := hook.Name + "/" + hook.Command + "/" + partID + "/" + status
+	if s.fired[key] {
+		return false
+	}
+	if len(s.fired) > 10000 {
+		s.fired = make(map[string]bool)
+	}
+	s.fired[key] = true
+	return true
+}
+
+func (s *HookService) run(hook models.HookConfig, event *models.Event, dir string, payload []byte, sem chan struct{}) {
+	defer func() {
+		s.mu.Lock()
+		s.queued--
+		s.mu.Unlock()
+	}()
+	sem <- struct{}{}
+	defer func() { <-sem }()
+
+	timeout := defaultHookTimeout
+	if hook.Timeout > 0 {
+		timeout = time.Duration(hook.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	record := newHookRun(hook, event)
+	output := &limitedBuffer{limit: maxHookOutput}
+	cmd := shellCommand(ctx, hook.Command)
+	cmd.Dir = dir
+	cmd.WaitDelay = hookWaitDelay
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err := cmd.Run()
+
+	record.Duration = time.Now().UnixMilli() - record.Started
+	record.Output = output.String()
+	if cmd.ProcessState != nil {
+		record.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		record.Error = "timed out after " + timeout.String()
+	} else if err != nil {
+		record.Error = err.Error()
+	}
+	s.record(record)
+}
+
+func newHookRun(hook models.HookConfig, event *models.Event) models.HookRun {
+	name := hook.Name
+	if name == "" {
+		name = hook.Event
+	}
+	return models.HookRun{
+		Hook:      name,
+		Event:     event.Type,
+		SessionID: event.SessionID(),
+		Command:   hook.Command,
+		Started:   time.Now().UnixMilli(),
+	}
+}
+
+func (s *HookService) record(run models.HookRun) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.log = append(s.log, run)
+	if len(s.log) > maxHookLog {
+		s.log = s.log[len(s.log)-maxHookLog:]
+	}
+}
+
+// Log returns the most recent hook runs, newest last. limit <= 0 returns all.
+func (s *HookService) Log(limit int) []models.HookRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := s.log
+	if limit > 0 && len(runs) > limit {
+		runs = runs[len(runs)-limit:]
+	}
+	return append([]models.HookRun(nil), runs...)
+}
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// limitedBuffer keeps the first limit bytes written to it.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.buf.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.buf.Write(p[:remaining])
+			b.truncated = true
+		} else {
+			b.buf.Write(p)
+		}
+	} else if len(p) > 0 {
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n... (output truncated)"
+	}
+	return b.buf.String()
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"fm-opencode-tinyapp/internal/models"
+)
+
+func toolPartEvent(sessionID, partID, tool, status string) *models.Event {
+	return &models.Event{
+		Type: "message.part.updated",
+		Properties: map[string]interface{}{
+			"part": map[string]interface{}{
+				"id": partID, "sessionID": sessionID, "type": "tool", "tool": tool,
+				"state": map[string]interface{}{"status": status},
+			},
+		},
+	}
+}
+
+func sessionIdleEvent(sessionID string) *models.Event {
+	return &models.Event{Type: "session.idle", Properties: map[string]interface{}{"sessionID": sessionID}}
+}
+
+// waitHookLog waits until the service has logged n runs.
+func waitHookLog(t *testing.T, s *HookService, n int) []models.HookRun {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		runs := s.Log(0)
+		if len(runs) >= n {
+			return runs
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d hook runs, want %d", len(runs), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func skipOnWindows(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("hook tests use sh")
+	}
+}
+
+func TestHookMatches(t *testing.T) {
+	cases := []struct {
+		name  string
+		hook  models.HookConfig
+		event *models.Event
+		want  bool
+	}{
+		{"event type", models.HookConfig{Event: "session.idle"}, sessionIdleEvent("ses_1"), true},
+		{"other event type", models.HookConfig{Event: "session.error"}, sessionIdleEvent("ses_1"), false},
+		{"session", models.HookConfig{Event: "session.idle", SessionID: "ses_1"}, sessionIdleEvent("ses_1"), true},
+		{"other session", models.HookConfig{Event: "session.idle", SessionID: "ses_2"}, sessionIdleEvent("ses_1"), false},
+		{"tool", models.HookConfig{Event: "message.part.updated", Tool: "bash"}, toolPartEvent("ses_1", "prt_1", "bash", "running"), true},
+		{"other tool", models.HookConfig{Event: "message.part.updated", Tool: "edit"}, toolPartEvent("ses_1", "prt_1", "bash", "running"), false},
+		{"tool status", models.HookConfig{Event: "message.part.updated", Tool: "bash", Status: "completed"}, toolPartEvent("ses_1", "prt_1", "bash", "completed"), true},
+		{"other tool status", models.HookConfig{Event: "message.part.updated", Status: "completed"}, toolPartEvent("ses_1", "prt_1", "bash", "running"), false},
+		{"tool filter on a text part", models.HookConfig{Event: "message.part.updated", Tool: "bash"}, textPartEvent("ses_1", "msg_1", "prt_1", "hello"), false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := NewHookService()
+			if got := s.hookMatches(c.hook, c.event); got != c.want {
+				t.Errorf("hookMatches = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestHookMatchesToolPartOnce(t *testing.T) {
+	s := NewHookService()
+	hook := models.HookConfig{Event: "message.part.updated", Tool: "bash", Status: "completed", Command: "true"}
+	event := toolPartEvent("ses_1", "prt_1", "bash", "completed")
+	if !s.hookMatches(hook, event) {
+		t.Fatal("first update did not match")
+	}
+	if s.hookMatches(hook, event) {
+		t.Error("repeated update of the same part and status matched again")
+	}
+	if !s.hookMatches(hook, toolPartEvent("ses_1", "prt_2", "bash", "completed")) {
+		t.Error("another part did not match")
+	}
+}
+
+func TestHookRun(t *testing.T) {
+	skipOnWindows(t)
+	dir := t.TempDir()
+	cases := []struct {
+		name       string
+		hook       models.HookConfig
+		dir        string
+		wantOutput string
+		wantExit   int
+		wantError  string
+	}{
+		{"event on stdin", models.HookConfig{Command: "cat"}, "", `"type":"session.idle"`, 0, ""},
+		{"working directory", models.HookConfig{Command: "pwd"}, dir, dir, 0, ""},
+		{"exit code", models.HookConfig{Command: "echo failed >&2; exit 3"}, "", "failed", 3, "exit status 3"},
+		{"timeout", models.HookConfig{Command: "sleep 5", Timeout: 1}, "", "", -1, "timed out after 1s"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := NewHookService()
+			c.hook.Event = "session.idle"
+			s.SetConfig(models.HooksConfig{Hooks: []models.HookConfig{c.hook}})
+			s.HandleEvent(sessionIdleEvent("ses_1"), c.dir)
+
+			run := waitHookLog(t, s, 1)[0]
+			if !strings.Contains(run.Output, c.wantOutput) {
+				t.Errorf("output = %q, want it to contain %q", run.Output, c.wantOutput)
+			}
+			if run.ExitCode != c.wantExit {
+				t.Errorf("exit code = %d, want %d", run.ExitCode, c.wantExit)
+			}
+			if run.Error != c.wantError {
+				t.Errorf("error = %q, want %q", run.Error, c.wantError)
+			}
+			if run.SessionID != "ses_1" || run.Hook != "session.idle" {
+				t.Errorf("run = %+v", run)
+			}
+		})
+	}
+}
+
+func TestHookQueueDropsWhenFull(t *testing.T) {
+	skipOnWindows(t)
+	release := filepath.Join(t.TempDir(), "release")
+	s := NewHookService()
+	s.SetConfig(models.HooksConfig{MaxConcurrent: 1, Hooks: []models.HookConfig{
+		{Event: "session.idle", Command: "while [ ! -e " + release + " ]; do sleep 0.05; done"},
+	}})
+
+	for i := 0; i < maxHookQueue+2; i++ {
+		s.HandleEvent(sessionIdleEvent("ses_1"), "")
+	}
+	dropped := waitHookLog(t, s, 2)
+	for _, run := range dropped {
+		if !strings.HasPrefix(run.Error, "dropped") {
+			t.Errorf("run = %+v, want a dropped run", run)
+		}
+	}
+
+	if err := os.WriteFile(release, nil, 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	runs := waitHookLog(t, s, maxHookQueue+2)
+	for _, run := range runs[2:] {
+		if run.Error != "" {
+			t.Errorf("queued run failed: %+v", run)
+		}
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	cases := []struct {
+		name   string
+		writes []string
+		want   string
+	}{
+		{"below the limit", []string{"abc", "de"}, "abcde"},
+		{"at the limit", []string{"abcdefgh"}, "abcdefgh"},
+		{"over the limit in one write", []string{"abcdefghij"}, "abcdefgh\n... (output truncated)"},
+		{"over the limit across writes", []string{"abcdef", "ghij", "kl"}, "abcdefgh\n... (output truncated)"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b := &limitedBuffer{limit: 8}
+			for _, w := range c.writes {
+				if n, err := b.Write([]byte(w)); n != len(w) || err != nil {
+					t.Fatalf("Write = %d, %v; want %d, nil", n, err, len(w))
+				}
+			}
+			if got := b.String(); got != c.want {
+				t.Errorf("String = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
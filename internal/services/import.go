@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"fm-opencode-tinyapp/internal/api"
+	"fm-opencode-tinyapp/internal/models"
+)
+
+// transcriptTextLimit caps each text part in a condensed transcript (in runes).
+const transcriptTextLimit = 2000
+
+// ImportService handles importing exported sessions into a new session.
+type ImportService struct {
+	apiClient *api.Client
+}
+
+// NewImportService creates a new ImportService.
+func NewImportService(apiClient *api.Client) *ImportService {
+	return &ImportService{apiClient: apiClient}
+}
+
+// importedMessage is a decoded message from an export file.
+type importedMessage struct {
+	message models.MessageWithParts
+	agent   string
+	model   *models.ModelSelection
+}
+
+// ImportSession reads a JSON export from path, creates a new session and
+// replays it according to mode. progress is called for every step and may be nil.
+func (s *ImportService) ImportSession(ctx context.Context, path string, mode string, progress func(models.ImportProgress)) (*models.Session, error) {
+	if progress == nil {
+		progress = func(models.ImportProgress) {}
+	}
+	if mode == "" {
+		mode = models.ImportModeTranscript
+	}
+	if mode != models.ImportModeReplay && mode != models.ImportModeTranscript {
+		return nil, fmt.Errorf("unsupported import mode: %s", mode)
+	}
+
+	export, messages, err := readSessionExport(path)
+	if err != nil {
+		return nil, err
+	}
+
+	title := "Imported Session"
+	sourceID := ""
+	if export.Session != nil {
+		sourceID = export.Session.ID
+		if export.Session.Title != "" {
+			title = export.Session.Title + " (imported)"
+		}
+	}
+
+	session, err := s.apiClient.CreateSession(ctx, title)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	status := models.ImportProgress{
+		SessionID:       session.ID,
+		SourceSessionID: sourceID,
+		Mode:            mode,
+		Status:          "started",
+	}
+
+	fail := func(err error) (*models.Session, error) {
+		status.Status = "failed"
+		status.Error = err.Error()
+		progress(status)
+		return session, err
+	}
+
+	if mode == models.ImportModeTranscript {
+		status.Total = 1
+		progress(status)
+
+		transcript := buildImportTranscript(export.Session, messages)
+		if transcript == "" {
+			return fail(fmt.Errorf("export contains no message content"))
+		}
+		input := &models.ChatInput{
+			Parts: []models.TextInputPart{{Type: "text", Text: transcript}},
+		}
+		if _, err := s.apiClient.SendMessage(ctx, session.ID, input); err != nil {
+			return fail(fmt.Errorf("failed to send transcript: %w", err))
+		}
+		status.Current = 1
+	} else {
+		userMessages := make([]importedMessage, 0, len(messages))
+		for _, msg := range messages {
+			if msg.message.Info != nil && msg.message.Info.GetRole() == "user" && messageText(msg.message) != "" {
+				userMessages = append(userMessages, msg)
+			}
+		}
+		if len(userMessages) == 0 {
+			return fail(fmt.Errorf("export contains no user messages"))
+		}
+
+		status.Total = len(userMessages)
+		progress(status)
+
+		for i, msg := range userMessages {
+			input := &models.ChatInput{
+				Parts: []models.TextInputPart{{Type: "text", Text: messageText(msg.message)}},
+				Model: msg.model,
+				Agent: msg.agent,
+			}
+			if _, err := s.apiClient.SendMessage(ctx, session.ID, input); err != nil {
+				return fail(fmt.Errorf("failed to replay message %d/%d: %w", i+1, len(userMessages), err))
+			}
+			status.Current = i + 1
+			status.Status = "progress"
+			progress(status)
+		}
+	}
+
+	status.Status = "completed"
+	progress(status)
+	return session, nil
+}
+
+func readSessionExport(path string) (*models.SessionExport, []importedMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read export file: %w", err)
+	}
+
+	var export models.SessionExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse export file: %w", err)
+	}
+	if export.Version == 0 || export.Version > models.SessionExportVersion {
+		return nil, nil, fmt.Errorf("unsupported export version: %d", export.Version)
+	}
+
+	messages := make([]importedMessage, 0, len(export.Messages))
+	for i, raw := range export.Messages {
+		var imported importedMessage
+		if err := json.Unmarshal(raw, &imported.message); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse message %d: %w", i, err)
+		}
+
+		// Agent and model are recorded on user messages but not modelled in UserMessage.
+		var meta struct {
+			Info struct {
+				Agent string                 `json:"agent"`
+				Model *models.ModelSelection `json:"model"`
+			} `json:"info"`
+		}
+		if err := json.Unmarshal(raw, &meta); err == nil {
+			imported.agent = meta.Info.Agent
+			if meta.Info.Model != nil && meta.Info.Model.ModelID != "" {
+				imported.model = meta.Info.Model
+			}
+		}
+		messages = append(messages, imported)
+	}
+
+	return &export, messages, nil
+}
+
+// messageText joins the text parts of a message.
+func messageText(msg models.MessageWithParts) string {
+	texts := make([]string, 0, len(msg.Parts))
+	for _, part := range msg.Parts {
+		if textPart, ok := part.(models.TextPart); ok {
+			if text := strings.TrimSpace(textPart.Text); text != "" {
+				texts = append(texts, text)
+			}
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+func buildImportTranscript(session *models.Session, messages []importedMessage) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		if msg.message.Info == nil {
+			continue
+		}
+		lines := make([]string, 0, len(msg.message.Parts))
+		for _, part := range msg.message.Parts {
+			switch p := part.(type) {
+			case models.TextPart:
+				if text := strings.TrimSpace(p.Text); text != "" {
+					lines = append(lines, truncateRunes(text, transcriptTextLimit))
+				}
+			case models.ToolPart:
+				line := "[tool: " + p.Tool
+				if title, ok := p.StateMap()["title"].(string); ok && title != "" {
+					line += " — " + title
+				}
+				lines = append(lines, line+"]")
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "### %s\n%s\n\n", msg.message.Info.GetRole(), strings.Join(lines, "\n"))
+	}
+
+	body := strings.TrimSpace(b.String())
+	if body == "" {
+		return ""
+	}
+
+	header := "以下は別の環境から引き継いだ過去の会話の記録です。内容を把握し、この文脈を踏まえて以降の会話を続けてください。この記録への返答は「了解しました」とだけ答えてください。\n"
+	if session != nil && session.Title != "" {
+		header += "元のセッション: " + session.Title + "\n"
+	}
+	return header + "---\n\n" + body
+}
+
+func truncateRunes(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit]) + "…"
+}
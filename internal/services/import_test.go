@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"fm-opencode-tinyapp/internal/api"
+	"fm-opencode-tinyapp/internal/models"
+)
+
+// fakeImportServer creates sessions and records the messages sent to them.
+type fakeImportServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	title  string
+	sent   []models.ChatInput
+	failAt int // When > 0, the failAt-th message is rejected
+}
+
+func newFakeImportServer(t *testing.T) *fakeImportServer {
+	t.Helper()
+	f := &fakeImportServer{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/session":
+			var body struct {
+				Title string `json:"title"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			f.title = body.Title
+			w.Write([]byte(`{"id": "ses_new", "title": ` + jsonString(body.Title) + `}`))
+		case r.Method == "POST" && r.URL.Path == "/session/ses_new/message":
+			var input models.ChatInput
+			json.NewDecoder(r.Body).Decode(&input)
+			f.sent = append(f.sent, input)
+			if len(f.sent) == f.failAt {
+				http.Error(w, `{"error": "busy"}`, http.StatusInternalServerError)
+				return
+			}
+			w.Write([]byte(`{"info": {"id": "msg_reply", "sessionID": "ses_new", "role": "assistant"}, "parts": []}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func jsonString(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+const importExportJSON = `{"version": 1, "exportedAt": 1700000200000,
+	"session": {"id": "ses_1", "title": "Fix the build"},
+	"messages": [
+		{"info": {"id": "msg_1", "sessionID": "ses_1", "role": "user", "agent": "build",
+		          "model": {"providerID": "anthropic", "modelID": "claude"}},
+		 "parts": [{"id": "prt_1", "type": "text", "text": "First question"}]},
+		{"info": {"id": "msg_2", "sessionID": "ses_1", "role": "assistant"},
+		 "parts": [
+			{"id": "prt_2", "type": "text", "text": "First answer"},
+			{"id": "prt_3", "type": "tool", "tool": "bash", "callID": "call_1",
+			 "state": {"status": "completed", "title": "go build", "output": "build succeeded"}}
+		 ]},
+		{"info": {"id": "msg_3", "sessionID": "ses_1", "role": "user"},
+		 "parts": [{"id": "prt_4", "type": "text", "text": "  "}]},
+		{"info": {"id": "msg_4", "sessionID": "ses_1", "role": "user"},
+		 "parts": [{"id": "prt_5", "type": "text", "text": "Second question"}]}
+	]}`
+
+func writeImportFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "export.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestImportSessionReplay(t *testing.T) {
+	server := newFakeImportServer(t)
+	s := NewImportService(api.NewClient(server.URL))
+
+	var progress []models.ImportProgress
+	session, err := s.ImportSession(context.Background(), writeImportFile(t, importExportJSON), models.ImportModeReplay, func(p models.ImportProgress) {
+		progress = append(progress, p)
+	})
+	if err != nil {
+		t.Fatalf("ImportSession: %v", err)
+	}
+	if session.ID != "ses_new" || server.title != "Fix the build (imported)" {
+		t.Errorf("session = %+v, created with title %q", session, server.title)
+	}
+
+	// User messages are replayed in order; empty ones are skipped.
+	if len(server.sent) != 2 {
+		t.Fatalf("sent %d messages, want 2", len(server.sent))
+	}
+	first, second := server.sent[0], server.sent[1]
+	if first.Parts[0].Text != "First question" || first.Agent != "build" || first.Model == nil || first.Model.ModelID != "claude" {
+		t.Errorf("first message = %+v", first)
+	}
+	if second.Parts[0].Text != "Second question" || second.Agent != "" || second.Model != nil {
+		t.Errorf("second message = %+v", second)
+	}
+
+	var statuses []string
+	for _, p := range progress {
+		if p.SourceSessionID != "ses_1" || p.Total != 2 {
+			t.Errorf("progress = %+v", p)
+		}
+		statuses = append(statuses, p.Status)
+	}
+	if got := strings.Join(statuses, ","); got != "started,progress,progress,completed" {
+		t.Errorf("progress statuses = %s", got)
+	}
+}
+
+func TestImportSessionReplayFailure(t *testing.T) {
+	server := newFakeImportServer(t)
+	server.failAt = 2
+	s := NewImportService(api.NewClient(server.URL))
+
+	var last models.ImportProgress
+	session, err := s.ImportSession(context.Background(), writeImportFile(t, importExportJSON), models.ImportModeReplay, func(p models.ImportProgress) {
+		last = p
+	})
+	if err == nil || !strings.Contains(err.Error(), "2/2") {
+		t.Fatalf("ImportSession = %v, want a failure on message 2/2", err)
+	}
+	if session == nil || session.ID != "ses_new" {
+		t.Errorf("session = %+v, want the created session", session)
+	}
+	if last.Status != "failed" || last.Current != 1 || last.Error == "" {
+		t.Errorf("last progress = %+v", last)
+	}
+}
+
+func TestImportSessionTranscript(t *testing.T) {
+	server := newFakeImportServer(t)
+	s := NewImportService(api.NewClient(server.URL))
+
+	if _, err := s.ImportSession(context.Background(), writeImportFile(t, importExportJSON), "", nil); err != nil {
+		t.Fatalf("ImportSession: %v", err)
+	}
+	if len(server.sent) != 1 {
+		t.Fatalf("sent %d messages, want a single transcript", len(server.sent))
+	}
+	transcript := server.sent[0].Parts[0].Text
+	want := []string{"元のセッション: Fix the build", "### user\nFirst question", "### assistant\nFirst answer\n[tool: bash — go build]", "### user\nSecond question"}
+	last := -1
+	for _, w := range want {
+		i := strings.Index(transcript, w)
+		if i < 0 {
+			t.Fatalf("transcript does not contain %q:\n%s", w, transcript)
+		}
+		if i < last {
+			t.Errorf("%q is out of order in the transcript", w)
+		}
+		last = i
+	}
+	if strings.Contains(transcript, "build succeeded") {
+		t.Error("transcript contains tool output")
+	}
+}
+
+func TestImportSessionInvalidFile(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		mode    string
+		wantErr string
+	}{
+		{"not JSON", `{`, models.ImportModeReplay, "failed to parse export file"},
+		{"missing version", `{"messages": []}`, models.ImportModeReplay, "unsupported export version: 0"},
+		{"newer version", `{"version": 2, "messages": []}`, models.ImportModeReplay, "unsupported export version: 2"},
+		{"invalid message", `{"version": 1, "messages": [{"info": {"role": "unknown"}}]}`, models.ImportModeReplay, "failed to parse message 0"},
+		{"unknown mode", importExportJSON, "merge", "unsupported import mode"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			server := newFakeImportServer(t)
+			s := NewImportService(api.NewClient(server.URL))
+			_, err := s.ImportSession(context.Background(), writeImportFile(t, c.content), c.mode, nil)
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Errorf("ImportSession = %v, want %q", err, c.wantErr)
+			}
+			if server.title != "" {
+				t.Error("a session was created for an invalid import")
+			}
+		})
+	}
+}
+
+func TestImportSessionWithoutContent(t *testing.T) {
+	empty := `{"version": 1, "session": {"id": "ses_1"}, "messages": [
+		{"info": {"id": "msg_1", "sessionID": "ses_1", "role": "assistant"}, "parts": []}]}`
+	cases := []struct {
+		mode    string
+		wantErr string
+	}{
+		{models.ImportModeReplay, "no user messages"},
+		{models.ImportModeTranscript, "no message content"},
+	}
+	for _, c := range cases {
+		t.Run(c.mode, func(t *testing.T) {
+			server := newFakeImportServer(t)
+			s := NewImportService(api.NewClient(server.URL))
+			_, err := s.ImportSession(context.Background(), writeImportFile(t, empty), c.mode, nil)
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Errorf("ImportSession = %v, want %q", err, c.wantErr)
+			}
+			if server.title != "Imported Session" {
+				t.Errorf("session title = %q, want the default title", server.title)
+			}
+		})
+	}
+}
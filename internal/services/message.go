@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"fm-opencode-tinyapp/internal/api"
 	"fm-opencode-tinyapp/internal/models"
 )
@@ -16,26 +17,31 @@ func NewMessageService(apiClient *api.Client) *MessageService {
 }
 
 // GetMessages retrieves all messages for a session.
-func (s *MessageService) GetMessages(sessionID string) ([]models.MessageWithParts, error) {
-	return s.apiClient.GetMessages(sessionID)
+func (s *MessageService) GetMessages(ctx context.Context, sessionID string) ([]models.MessageWithParts, error) {
+	return s.apiClient.GetMessages(ctx, sessionID)
 }
 
 // SendMessage sends a new message.
-func (s *MessageService) SendMessage(sessionID string, req *models.ChatInput) (*models.MessageWithParts, error) {
-	return s.apiClient.SendMessage(sessionID, req)
+func (s *MessageService) SendMessage(ctx context.Context, sessionID string, req *models.ChatInput) (*models.MessageWithParts, error) {
+	return s.apiClient.SendMessage(ctx, sessionID, req)
+}
+
+// SendMessageAsync sends a new message without waiting for the agent to finish.
+func (s *MessageService) SendMessageAsync(ctx context.Context, sessionID string, req *models.ChatInput) error {
+	return s.apiClient.SendMessageAsync(ctx, sessionID, req)
 }
 
 // StopMessage stops the current agent execution in a session.
-func (s *MessageService) StopMessage(sessionID string) error {
-	return s.apiClient.StopMessage(sessionID)
+func (s *MessageService) StopMessage(ctx context.Context, sessionID string) error {
+	return s.apiClient.StopMessage(ctx, sessionID)
 }
 
 // RespondPermission responds to a pending permission/question request in a session.
-func (s *MessageService) RespondPermission(sessionID string, permissionID string, response string) error {
-	return s.apiClient.RespondPermission(sessionID, permissionID, response)
+func (s *MessageService) RespondPermission(ctx context.Context, sessionID string, permissionID string, response string) error {
+	return s.apiClient.RespondPermission(ctx, sessionID, permissionID, response)
 }
 
 // SendTUIControlResponse sends a response body for interactive TUI control requests.
-func (s *MessageService) SendTUIControlResponse(body interface{}) error {
-	return s.apiClient.SendTUIControlResponse(body)
+func (s *MessageService) SendTUIControlResponse(ctx context.Context, body interface{}) error {
+	return s.apiClient.SendTUIControlResponse(ctx, body)
 }
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"fm-opencode-tinyapp/internal/models"
+)
+
+// PermissionAuditService keeps an append-only JSONL log of permission responses.
+type PermissionAuditService struct {
+	logPath string
+
+	mu      sync.Mutex
+	pending map[string]*models.PermissionRequest // permissionID -> request seen in events
+}
+
+// NewPermissionAuditService creates a new PermissionAuditService writing to
+// permission-audit.jsonl in the application directory.
+func NewPermissionAuditService() (*PermissionAuditService, error) {
+	appDir, err := AppDataDir()
+	if err != nil {
+		return nil, err
+	}
+	return &PermissionAuditService{
+		logPath: filepath.Join(appDir, "permission-audit.jsonl"),
+		pending: make(map[string]*models.PermissionRequest),
+	}, nil
+}
+
+// HandleEvent remembers permission requests so that their details can be
+// logged when they are answered.
+func (s *PermissionAuditService) HandleEvent(event *models.Event) {
+	if event == nil {
+		return
+	}
+	switch event.Type {
+	case "permission.updated":
+		req, err := models.ParsePermissionRequest(event.Properties)
+		if err != nil || req.ID == "" {
+			return
+		}
+		s.mu.Lock()
+		s.pending[req.ID] = req
+		s.mu.Unlock()
+	case "permission.replied":
+		permissionID, _ := event.Properties["permissionID"].(string)
+		s.mu.Lock()
+		delete(s.pending, permissionID)
+		s.mu.Unlock()
+	}
+}
+
+// Pending returns the request seen in events for a permission, or nil. Take
+// it before responding: the permission.replied event may arrive before the
+// response returns and drops the request.
+func (s *PermissionAuditService) Pending(permissionID string) *models.PermissionRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending[permissionID]
+}
+
+// RecordUser logs a response given by the user. req is the request taken
+// with Pending before responding and may be nil.
+func (s *PermissionAuditService) RecordUser(req *models.PermissionRequest, sessionID, permissionID, response string, respErr error) error {
+	entry := models.PermissionAuditEntry{
+		SessionID:    sessionID,
+		PermissionID: permissionID,
+		Responder:    models.PermissionResponderUser,
+		Response:     response,
+	}
+	if respErr != nil {
+		entry.Error = respErr.Error()
+	}
+	return s.append(entry, req)
+}
+
+// RecordDecision logs a response given by the permission policy. Dry-run and
+// "ask" decisions are not responses and are not logged.
+func (s *PermissionAuditService) RecordDecision(decision *models.PermissionDecision) error {
+	if decision == nil || decision.Request == nil || decision.DryRun || decision.Action == models.PermissionAsk {
+		return nil
+	}
+	return s.append(models.PermissionAuditEntry{
+		SessionID:    decision.Request.SessionID,
+		PermissionID: decision.Request.ID,
+		Responder:    models.PermissionResponderRule,
+		Rule:         decision.Rule,
+		Response:     decision.Action,
+		Error:        decision.Error,
+	}, decision.Request)
+}
+
+func (s *PermissionAuditService) append(entry models.PermissionAuditEntry, req *models.PermissionRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.Time = time.Now().UnixMilli()
+	if req != nil {
+		entry.Type = req.Type
+		entry.Title = req.Title
+		entry.Patterns = req.Patterns()
+		entry.Command = req.Command()
+		entry.Path = req.FilePath()
+		if entry.SessionID == "" {
+			entry.SessionID = req.SessionID
+		}
+	}
+	if entry.Error == "" {
+		delete(s.pending, entry.PermissionID)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	file, err := os.OpenFile(s.logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// Query returns the audit entries matching filter, oldest first.
+func (s *PermissionAuditService) Query(filter *models.PermissionAuditFilter) ([]models.PermissionAuditEntry, error) {
+	if filter == nil {
+		filter = &models.PermissionAuditFilter{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]models.PermissionAuditEntry, 0)
+	file, err := os.Open(s.logPath)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var entry models.PermissionAuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// Skip a partially written trailing line rather than failing the whole query.
+			continue
+		}
+		if auditEntryMatches(entry, filter) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[len(entries)-filter.Limit:]
+	}
+	return entries, nil
+}
+
+func auditEntryMatches(entry models.PermissionAuditEntry, filter *models.PermissionAuditFilter) bool {
+	switch {
+	case filter.SessionID != "" && entry.SessionID != filter.SessionID:
+		return false
+	case filter.Responder != "" && entry.Responder != filter.Responder:
+		return false
+	case filter.Response != "" && entry.Response != filter.Response:
+		return false
+	case filter.Since > 0 && entry.Time < filter.Since:
+		return false
+	case filter.Until > 0 && entry.Time >= filter.Until:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"fm-opencode-tinyapp/internal/models"
+)
+
+var errTestRespond = errors.New("connection refused")
+
+func newTestPermissionAudit(t *testing.T) *PermissionAuditService {
+	return &PermissionAuditService{
+		logPath: filepath.Join(t.TempDir(), "permission-audit.jsonl"),
+		pending: make(map[string]*models.PermissionRequest),
+	}
+}
+
+func permissionUpdatedEvent() *models.Event {
+	return &models.Event{
+		Type: "permission.updated",
+		Properties: map[string]interface{}{
+			"id":        "per_1",
+			"type":      "bash",
+			"pattern":   "git status",
+			"sessionID": "ses_1",
+			"messageID": "msg_1",
+			"title":     "git status",
+			"metadata":  map[string]interface{}{"command": "git status"},
+		},
+	}
+}
+
+func permissionRepliedEvent() *models.Event {
+	return &models.Event{
+		Type: "permission.replied",
+		Properties: map[string]interface{}{
+			"sessionID":    "ses_1",
+			"permissionID": "per_1",
+			"response":     "once",
+		},
+	}
+}
+
+func TestRecordUserAfterRepliedEvent(t *testing.T) {
+	s := newTestPermissionAudit(t)
+	s.HandleEvent(permissionUpdatedEvent())
+
+	// The replied event is delivered before the response call returns.
+	req := s.Pending("per_1")
+	s.HandleEvent(permissionRepliedEvent())
+	if err := s.RecordUser(req, "ses_1", "per_1", "once", nil); err != nil {
+		t.Fatalf("RecordUser: %v", err)
+	}
+
+	entries, err := s.Query(nil)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	entry := entries[0]
+	if entry.Responder != models.PermissionResponderUser || entry.Response != "once" {
+		t.Errorf("got responder %q response %q", entry.Responder, entry.Response)
+	}
+	if entry.Type != "bash" || entry.Title != "git status" || entry.Command != "git status" {
+		t.Errorf("request details lost: %+v", entry)
+	}
+	if len(entry.Patterns) != 1 || entry.Patterns[0] != "git status" {
+		t.Errorf("got patterns %v", entry.Patterns)
+	}
+}
+
+func TestRecordDecisionAfterRepliedEvent(t *testing.T) {
+	s := newTestPermissionAudit(t)
+	event := permissionUpdatedEvent()
+	s.HandleEvent(event)
+	req, err := models.ParsePermissionRequest(event.Properties)
+	if err != nil {
+		t.Fatalf("ParsePermissionRequest: %v", err)
+	}
+
+	s.HandleEvent(permissionRepliedEvent())
+	decision := &models.PermissionDecision{Request: req, Rule: "git", Action: "once"}
+	if err := s.RecordDecision(decision); err != nil {
+		t.Fatalf("RecordDecision: %v", err)
+	}
+
+	entries, err := s.Query(&models.PermissionAuditFilter{Responder: models.PermissionResponderRule})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Command != "git status" || entries[0].Rule != "git" {
+		t.Errorf("got entries %+v", entries)
+	}
+}
+
+func TestRecordUserFailureKeepsPending(t *testing.T) {
+	s := newTestPermissionAudit(t)
+	s.HandleEvent(permissionUpdatedEvent())
+
+	req := s.Pending("per_1")
+	if err := s.RecordUser(req, "ses_1", "per_1", "once", errTestRespond); err != nil {
+		t.Fatalf("RecordUser: %v", err)
+	}
+	if s.Pending("per_1") == nil {
+		t.Error("pending request dropped after a failed response")
+	}
+	if err := s.RecordUser(s.Pending("per_1"), "ses_1", "per_1", "once", nil); err != nil {
+		t.Fatalf("RecordUser: %v", err)
+	}
+	if s.Pending("per_1") != nil {
+		t.Error("pending request kept after a successful response")
+	}
+}
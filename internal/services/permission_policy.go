@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"fm-opencode-tinyapp/internal/api"
+	"fm-opencode-tinyapp/internal/models"
+)
+
+// PermissionPolicyService answers permission requests automatically
+// according to the configured rules.
+type PermissionPolicyService struct {
+	apiClient *api.Client
+
+	mu     sync.RWMutex
+	policy models.PermissionPolicy
+}
+
+// NewPermissionPolicyService creates a new PermissionPolicyService.
+func NewPermissionPolicyService(apiClient *api.Client) *PermissionPolicyService {
+	return &PermissionPolicyService{apiClient: apiClient}
+}
+
+// SetPolicy replaces the permission policy.
+func (s *PermissionPolicyService) SetPolicy(policy models.PermissionPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = policy
+}
+
+// Evaluate returns the decision of the policy for a request, regardless of
+// whether the policy is enabled.
+func (s *PermissionPolicyService) Evaluate(req *models.PermissionRequest) *models.PermissionDecision {
+	s.mu.RLock()
+	policy := s.policy
+	s.mu.RUnlock()
+
+	decision := &models.PermissionDecision{Request: req, Action: models.PermissionAsk, DryRun: policy.DryRun}
+	for i, rule := range policy.Rules {
+		if !ruleMatches(rule, req) {
+			continue
+		}
+		decision.Rule = rule.Name
+		if decision.Rule == "" {
+			decision.Rule = fmt.Sprintf("#%d", i+1)
+		}
+		switch rule.Action {
+		case models.PermissionOnce, models.PermissionAlways, models.PermissionReject:
+			decision.Action = rule.Action
+		}
+		break
+	}
+	return decision
+}
+
+// HandleEvent evaluates a permission.updated event and responds to it when a
+// rule decides. It returns nil when the policy is disabled or the event is
+// not a permission request. In dry-run mode the decision is returned without
+// responding.
+func (s *PermissionPolicyService) HandleEvent(ctx context.Context, event *models.Event) (*models.PermissionDecision, error) {
+	if event == nil || event.Type != "permission.updated" {
+		return nil, nil
+	}
+	s.mu.RLock()
+	enabled := s.policy.Enabled
+	s.mu.RUnlock()
+	if !enabled {
+		return nil, nil
+	}
+
+	req, err := models.ParsePermissionRequest(event.Properties)
+	if err != nil || req.ID == "" || req.SessionID == "" {
+		return nil, fmt.Errorf("invalid permission request: %v", event.Properties)
+	}
+
+	decision := s.Evaluate(req)
+	if decision.Action == models.PermissionAsk || decision.DryRun {
+		return decision, nil
+	}
+	if err := s.apiClient.RespondPermission(ctx, req.SessionID, req.ID, decision.Action); err != nil {
+		decision.Error = err.Error()
+		return decision, fmt.Errorf("failed to respond to permission %s: %w", req.ID, err)
+	}
+	return decision, nil
+}
+
+func ruleMatches(rule models.PermissionRule, req *models.PermissionRequest) bool {
+	if rule.SessionID != "" && rule.SessionID != req.SessionID {
+		return false
+	}
+	if rule.Tool != "" && !globMatch(rule.Tool, req.Type, globText) {
+		return false
+	}
+	if rule.Command != "" {
+		command := req.Command()
+		if command == "" || !globMatch(rule.Command, strings.TrimSpace(command), globCommand) {
+			return false
+		}
+	}
+	if rule.Path != "" {
+		path := req.FilePath()
+		if path == "" || !globMatch(rule.Path, filepathToSlash(path), globPath) {
+			return false
+		}
+	}
+	return true
+}
+
+// globMode selects what the wildcards of a glob may match.
+type globMode int
+
+const (
+	globText    globMode = iota // Wildcards match anything
+	globPath                    // "*" and "?" do not cross "/"
+	globCommand                 // Wildcards do not match shell metacharacters
+)
+
+// shellMetachars are the characters that separate, chain, substitute or
+// redirect commands. A command glob never matches them with a wildcard, so
+// that "git status*" does not approve "git status; rm -rf /".
+const shellMetachars = ";&|`$()<>\n\r"
+
+// globMatch matches s against a glob pattern. "?" matches one character and
+// "**" matches anything. In globPath mode, "*" does not cross "/" and a
+// pattern without "/" is matched against the base name. In globCommand mode
+// no wildcard matches a shell metacharacter; they only match when written
+// literally in the pattern.
+func globMatch(pattern, s string, mode globMode) bool {
+	if mode == globPath && !strings.Contains(pattern, "/") {
+		if i := strings.LastIndex(s, "/"); i >= 0 {
+			s = s[i+1:]
+		}
+	}
+	re, err := globToRegexp(pattern, mode)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(s)
+}
+
+func globToRegexp(pattern string, mode globMode) (*regexp.Regexp, error) {
+	many, one := ".", "."
+	switch mode {
+	case globPath:
+		one = "[^/]"
+	case globCommand:
+		many = "[^" + regexp.QuoteMeta(shellMetachars) + "]"
+		one = many
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				i++
+				// "**/" also matches zero directories.
+				if mode == globPath && i+1 < len(runes) && runes[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(many + "*")
+				}
+			} else {
+				b.WriteString(one + "*")
+			}
+		case '?':
+			b.WriteString(one)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile("(?s)" + b.String())
+}
+
+func filepathToSlash(path string) string {
+	return strings.ReplaceAll(path, "\\", "/")
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"fm-opencode-tinyapp/internal/models"
+)
+
+// projectStore is the content of projects.json.
+type projectStore struct {
+	Active   string           `json:"active,omitempty"`
+	Projects []models.Project `json:"projects"`
+}
+
+// ProjectService keeps the registry of project directories and the active
+// project in projects.json in the application directory.
+type ProjectService struct {
+	storePath string
+
+	mu     sync.Mutex
+	loaded bool
+	store  projectStore
+}
+
+// NewProjectService creates a new ProjectService.
+func NewProjectService() (*ProjectService, error) {
+	appDir, err := AppDataDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewProjectServiceAt(filepath.Join(appDir, "projects.json")), nil
+}
+
+// NewProjectServiceAt creates a ProjectService keeping the registry in the
+// file at storePath.
+func NewProjectServiceAt(storePath string) *ProjectService {
+	return &ProjectService{storePath: storePath}
+}
+
+// Add registers a directory. Adding a registered directory returns the
+// existing project.
+func (s *ProjectService) Add(path string) (*models.Project, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open project directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", absPath)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256([]byte(absPath))
+	id := hex.EncodeToString(sum[:6])
+	if i := s.find(id); i >= 0 {
+		project := s.store.Projects[i]
+		return &project, nil
+	}
+	project := models.Project{
+		ID:      id,
+		Name:    filepath.Base(absPath),
+		Path:    absPath,
+		AddedAt: time.Now().UnixMilli(),
+	}
+	s.store.Projects = append(s.store.Projects, project)
+	if err := s.save(); err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// Remove unregisters a project. Removing the active project clears it.
+func (s *ProjectService) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	i := s.find(id)
+	if i < 0 {
+		return fmt.Errorf("project not found: %s", id)
+	}
+	s.store.Projects = append(s.store.Projects[:i], s.store.Projects[i+1:]...)
+	if s.store.Active == id {
+		s.store.Active = ""
+	}
+	return s.save()
+}
+
+// Get returns a project.
+func (s *ProjectService) Get(id string) (*models.Project, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	i := s.find(id)
+	if i < 0 {
+		return nil, fmt.Errorf("project not found: %s", id)
+	}
+	project := s.store.Projects[i]
+	return &project, nil
+}
+
+// List returns the projects, most recently opened first.
+func (s *ProjectService) List() ([]models.Project, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	projects := append([]models.Project{}, s.store.Projects...)
+	sort.SliceStable(projects, func(i, j int) bool {
+		return projects[i].LastOpened > projects[j].LastOpened
+	})
+	return projects, nil
+}
+
+// Active returns the active project, or nil if the server URL from the
+// config is used.
+func (s *ProjectService) Active() (*models.Project, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	i := s.find(s.store.Active)
+	if i < 0 {
+		return nil, nil
+	}
+	project := s.store.Projects[i]
+	return &project, nil
+}
+
+// SetActive makes a project active and records it as opened. An empty id
+// clears the active project.
+func (s *ProjectService) SetActive(id string) (*models.Project, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if id == "" {
+		s.store.Active = ""
+		return nil, s.save()
+	}
+	i := s.find(id)
+	if i < 0 {
+		return nil, fmt.Errorf("project not found: %s", id)
+	}
+	s.store.Active = id
+	s.store.Projects[i].LastOpened = time.Now().UnixMilli()
+	project := s.store.Projects[i]
+	return &project, s.save()
+}
+
+// SetPort records the port of a project's server.
+func (s *ProjectService) SetPort(id string, port int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	i := s.find(id)
+	if i < 0 {
+		return fmt.Errorf("project not found: %s", id)
+	}
+	s.store.Projects[i].Port = port
+	return s.save()
+}
+
+// find must be called with the lock held.
+func (s *ProjectService) find(id string) int {
+	for i, project := range s.store.Projects {
+		if project.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// load must be called with the lock held.
+func (s *ProjectService) load() error {
+	if s.loaded {
+		return nil
+	}
+	data, err := os.ReadFile(s.storePath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read projects: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &s.store); err != nil {
+			return fmt.Errorf("failed to parse projects: %w", err)
+		}
+	}
+	s.loaded = true
+	return nil
+}
+
+// save must be called with the lock held.
+func (s *ProjectService) save() error {
+	data, err := json.MarshalIndent(s.store, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal projects: %w", err)
+	}
+	if err := writeFileAtomic(s.storePath, data, 0640); err != nil {
+		return fmt.Errorf("failed to write projects: %w", err)
+	}
+	return nil
+}
+
+// FreePort returns a TCP port on the loopback interface that is currently unused.
+func FreePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// PortInUse reports whether something listens on the loopback port.
+func PortInUse(port int) bool {
+	return isTCPPortOpen(fmt.Sprintf("127.0.0.1:%d", port), 200*time.Millisecond)
+}
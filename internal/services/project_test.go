@@ -0,0 +1,151 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestProjectService(t *testing.T) (*ProjectService, string) {
+	t.Helper()
+	storePath := filepath.Join(t.TempDir(), "projects.json")
+	return NewProjectServiceAt(storePath), storePath
+}
+
+func TestProjectAdd(t *testing.T) {
+	s, _ := newTestProjectService(t)
+	dir := t.TempDir()
+
+	project, err := s.Add(dir)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if project.ID == "" || project.Path != dir || project.Name != filepath.Base(dir) || project.AddedAt == 0 {
+		t.Errorf("project = %+v", project)
+	}
+
+	// The same directory, however it is spelled, is registered once.
+	again, err := s.Add(filepath.Join(dir, "sub", ".."))
+	if err != nil {
+		t.Fatalf("Add again: %v", err)
+	}
+	if *again != *project {
+		t.Errorf("adding the directory again = %+v, want %+v", again, project)
+	}
+	if projects, _ := s.List(); len(projects) != 1 {
+		t.Errorf("registered %d projects, want 1", len(projects))
+	}
+
+	file := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := s.Add(file); err == nil || !strings.Contains(err.Error(), "not a directory") {
+		t.Errorf("Add(file) = %v", err)
+	}
+	if _, err := s.Add(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Add accepted a missing directory")
+	}
+}
+
+func TestProjectActive(t *testing.T) {
+	s, _ := newTestProjectService(t)
+	first, _ := s.Add(t.TempDir())
+	second, _ := s.Add(t.TempDir())
+
+	if active, err := s.Active(); err != nil || active != nil {
+		t.Fatalf("Active without a switch = %+v, %v", active, err)
+	}
+	if _, err := s.SetActive("unknown"); err == nil {
+		t.Error("SetActive accepted an unknown project")
+	}
+
+	if _, err := s.SetActive(first.ID); err != nil {
+		t.Fatalf("SetActive: %v", err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	active, err := s.SetActive(second.ID)
+	if err != nil {
+		t.Fatalf("SetActive: %v", err)
+	}
+	if active.ID != second.ID || active.LastOpened == 0 {
+		t.Errorf("active = %+v", active)
+	}
+
+	// List is ordered by the time a project was last opened.
+	projects, err := s.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(projects) != 2 || projects[0].ID != second.ID || projects[1].ID != first.ID {
+		t.Errorf("List = %+v, want the second project first", projects)
+	}
+
+	if _, err := s.SetActive(""); err != nil {
+		t.Fatalf("SetActive(\"\"): %v", err)
+	}
+	if active, _ := s.Active(); active != nil {
+		t.Errorf("Active after clearing = %+v", active)
+	}
+}
+
+func TestProjectRemove(t *testing.T) {
+	s, _ := newTestProjectService(t)
+	project, _ := s.Add(t.TempDir())
+	s.SetActive(project.ID)
+
+	if err := s.Remove(project.ID); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if active, _ := s.Active(); active != nil {
+		t.Errorf("removed project is still active: %+v", active)
+	}
+	if _, err := s.Get(project.ID); err == nil {
+		t.Error("Get returned a removed project")
+	}
+	if err := s.Remove(project.ID); err == nil {
+		t.Error("Remove accepted an unknown project")
+	}
+}
+
+func TestProjectPersistence(t *testing.T) {
+	s, storePath := newTestProjectService(t)
+	project, _ := s.Add(t.TempDir())
+	if err := s.SetPort(project.ID, 4100); err != nil {
+		t.Fatalf("SetPort: %v", err)
+	}
+	if _, err := s.SetActive(project.ID); err != nil {
+		t.Fatalf("SetActive: %v", err)
+	}
+
+	reloaded := NewProjectServiceAt(storePath)
+	active, err := reloaded.Active()
+	if err != nil {
+		t.Fatalf("Active: %v", err)
+	}
+	if active == nil || active.ID != project.ID || active.Port != 4100 || active.LastOpened == 0 {
+		t.Errorf("reloaded active project = %+v", active)
+	}
+	if err := reloaded.SetPort("unknown", 1); err == nil {
+		t.Error("SetPort accepted an unknown project")
+	}
+
+	if err := os.WriteFile(storePath, []byte("{"), 0640); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := NewProjectServiceAt(storePath).List(); err == nil {
+		t.Error("List accepted a corrupt projects.json")
+	}
+}
+
+func TestFreePort(t *testing.T) {
+	port, err := FreePort()
+	if err != nil {
+		t.Fatalf("FreePort: %v", err)
+	}
+	if port == 0 || PortInUse(port) {
+		t.Errorf("FreePort returned port %d, which is in use", port)
+	}
+}
@@ -0,0 +1,505 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf16"
+
+	"fm-opencode-tinyapp/internal/api"
+	"fm-opencode-tinyapp/internal/models"
+)
+
+const (
+	defaultSearchLimit = 50
+	snippetRadius      = 40
+)
+
+// searchDoc is a single indexed message part.
+type searchDoc struct {
+	sessionID  string
+	messageID  string
+	partID     string
+	partType   string
+	text       []rune // original text
+	normalized []rune // normalized text, rune-aligned with text
+}
+
+// SearchService maintains a local full-text index over all sessions' messages.
+// Text is tokenized into character bigrams so that languages without word
+// separators (e.g. Japanese) can be searched.
+type SearchService struct {
+	apiClient *api.Client
+
+	mu            sync.RWMutex
+	built         bool
+	building      int                            // Number of BuildIndex calls in progress
+	buffered      []*models.Event                // Events received while building
+	docs          map[string]*searchDoc          // partKey -> doc
+	grams         map[string]map[string]struct{} // bigram -> partKeys
+	messageRoles  map[string]string              // messageID -> role
+	sessionTitles map[string]string              // sessionID -> title
+}
+
+// NewSearchService creates a new SearchService. The index is built lazily on
+// the first search or explicitly via BuildIndex.
+func NewSearchService(apiClient *api.Client) *SearchService {
+	return &SearchService{
+		apiClient:     apiClient,
+		docs:          make(map[string]*searchDoc),
+		grams:         make(map[string]map[string]struct{}),
+		messageRoles:  make(map[string]string),
+		sessionTitles: make(map[string]string),
+	}
+}
+
+// BuildIndex (re)builds the index from all sessions on the server. Events
+// received while building are replayed on the new index before it replaces
+// the current one, so that changes made during the build are not lost.
+func (s *SearchService) BuildIndex(ctx context.Context) error {
+	s.mu.Lock()
+	s.building++
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.building--
+		if s.building == 0 {
+			s.buffered = nil
+		}
+		s.mu.Unlock()
+	}()
+
+	sessions, err := s.apiClient.GetSessions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	fresh := NewSearchService(s.apiClient)
+	for _, session := range sessions {
+		fresh.sessionTitles[session.ID] = session.Title
+		messages, err := s.apiClient.GetMessages(ctx, session.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get messages for session %s: %w", session.ID, err)
+		}
+		for _, msg := range messages {
+			fresh.addMessage(msg)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, event := range s.buffered {
+		fresh.applyEvent(event)
+	}
+	s.docs = fresh.docs
+	s.grams = fresh.grams
+	s.messageRoles = fresh.messageRoles
+	s.sessionTitles = fresh.sessionTitles
+	s.built = true
+	return nil
+}
+
+// HandleEvent updates the index incrementally from a server event.
+func (s *SearchService) HandleEvent(event *models.Event) {
+	if event == nil || event.Properties == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.building > 0 {
+		s.buffered = append(s.buffered, event)
+	}
+	s.applyEvent(event)
+}
+
+// applyEvent updates the index from a server event. Callers must hold the
+// lock or own the service exclusively.
+func (s *SearchService) applyEvent(event *models.Event) {
+	switch event.Type {
+	case "message.updated":
+		if info, ok := event.Properties["info"].(map[string]interface{}); ok {
+			id, _ := info["id"].(string)
+			role, _ := info["role"].(string)
+			if id != "" && role != "" {
+				s.messageRoles[id] = role
+			}
+		}
+	case "message.part.updated":
+		part, ok := event.Properties["part"].(map[string]interface{})
+		if !ok {
+			return
+		}
+		sessionID, _ := part["sessionID"].(string)
+		messageID, _ := part["messageID"].(string)
+		partID, _ := part["id"].(string)
+		partType, _ := part["type"].(string)
+		if sessionID == "" || messageID == "" || partID == "" {
+			return
+		}
+		var text string
+		switch partType {
+		case "text":
+			text, _ = part["text"].(string)
+		case "tool":
+			tool, _ := part["tool"].(string)
+			text = toolPartSearchText(models.ToolPart{Tool: tool, State: part["state"]})
+		default:
+			return
+		}
+		s.putDoc(sessionID, messageID, partID, partType, text)
+	case "message.removed":
+		sessionID, _ := event.Properties["sessionID"].(string)
+		messageID, _ := event.Properties["messageID"].(string)
+		for key, doc := range s.docs {
+			if doc.sessionID == sessionID && doc.messageID == messageID {
+				s.removeDoc(key)
+			}
+		}
+		delete(s.messageRoles, messageID)
+	case "session.updated", "session.created":
+		if info, ok := event.Properties["info"].(map[string]interface{}); ok {
+			id, _ := info["id"].(string)
+			title, _ := info["title"].(string)
+			if id != "" {
+				s.sessionTitles[id] = title
+			}
+		}
+	case "session.deleted":
+		if info, ok := event.Properties["info"].(map[string]interface{}); ok {
+			id, _ := info["id"].(string)
+			for key, doc := range s.docs {
+				if doc.sessionID == id {
+					s.removeDoc(key)
+				}
+			}
+			delete(s.sessionTitles, id)
+		}
+	}
+}
+
+// Search returns the message parts matching every whitespace-separated term in query.
+func (s *SearchService) Search(ctx context.Context, query string, filters *models.MessageSearchFilters) ([]models.MessageSearchResult, error) {
+	s.mu.RLock()
+	built := s.built
+	s.mu.RUnlock()
+	if !built {
+		if err := s.BuildIndex(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if filters == nil {
+		filters = &models.MessageSearchFilters{}
+	}
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	terms := make([][]rune, 0)
+	for _, field := range strings.Fields(query) {
+		terms = append(terms, normalizeRunes([]rune(field)))
+	}
+	if len(terms) == 0 {
+		return []models.MessageSearchResult{}, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	results := make([]models.MessageSearchResult, 0)
+	for key := range s.candidates(terms) {
+		doc := s.docs[key]
+		if !s.matchesFilters(doc, filters) {
+			continue
+		}
+
+		var spans []models.HighlightSpan
+		matched := true
+		for _, term := range terms {
+			found := findAll(doc.normalized, term)
+			if len(found) == 0 {
+				matched = false
+				break
+			}
+			spans = append(spans, found...)
+		}
+		if !matched {
+			continue
+		}
+
+		snippet, highlights := buildSnippet(doc.text, spans)
+		results = append(results, models.MessageSearchResult{
+			SessionID:    doc.sessionID,
+			SessionTitle: s.sessionTitles[doc.sessionID],
+			MessageID:    doc.messageID,
+			PartID:       doc.partID,
+			Role:         s.messageRoles[doc.messageID],
+			PartType:     doc.partType,
+			Snippet:      snippet,
+			Highlights:   highlights,
+			Score:        len(spans),
+		})
+	}
+
+	// Higher score first; opencode IDs sort chronologically, so newer messages come first on ties.
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].MessageID > results[j].MessageID
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// candidates returns the part keys that contain all bigrams of every term.
+// Single-character terms cannot use the bigram index and fall back to a full scan.
+func (s *SearchService) candidates(terms [][]rune) map[string]struct{} {
+	var result map[string]struct{}
+	for _, term := range terms {
+		grams := bigrams(term)
+		if len(grams) == 0 {
+			continue
+		}
+		for _, gram := range grams {
+			postings := s.grams[gram]
+			if result == nil {
+				result = make(map[string]struct{}, len(postings))
+				for key := range postings {
+					result[key] = struct{}{}
+				}
+				continue
+			}
+			for key := range result {
+				if _, ok := postings[key]; !ok {
+					delete(result, key)
+				}
+			}
+		}
+	}
+	if result == nil {
+		result = make(map[string]struct{}, len(s.docs))
+		for key := range s.docs {
+			result[key] = struct{}{}
+		}
+	}
+	return result
+}
+
+func (s *SearchService) matchesFilters(doc *searchDoc, filters *models.MessageSearchFilters) bool {
+	if len(filters.SessionIDs) > 0 && !containsString(filters.SessionIDs, doc.sessionID) {
+		return false
+	}
+	if filters.Role != "" && s.messageRoles[doc.messageID] != filters.Role {
+		return false
+	}
+	if len(filters.PartTypes) > 0 && !containsString(filters.PartTypes, doc.partType) {
+		return false
+	}
+	return true
+}
+
+// addMessage indexes the text and tool parts of a message. Callers must hold the lock
+// or own the service exclusively.
+func (s *SearchService) addMessage(msg models.MessageWithParts) {
+	if msg.Info == nil {
+		return
+	}
+	s.messageRoles[msg.Info.GetID()] = msg.Info.GetRole()
+	for _, part := range msg.Parts {
+		switch p := part.(type) {
+		case models.TextPart:
+			s.putDoc(msg.Info.GetSessionID(), msg.Info.GetID(), p.ID, p.Type, p.Text)
+		case models.ToolPart:
+			s.putDoc(msg.Info.GetSessionID(), msg.Info.GetID(), p.ID, p.Type, toolPartSearchText(p))
+		}
+	}
+}
+
+func (s *SearchService) putDoc(sessionID, messageID, partID, partType, text string) {
+	key := sessionID + "/" + messageID + "/" + partID
+	s.removeDoc(key)
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+
+	runes := []rune(text)
+	doc := &searchDoc{
+		sessionID:  sessionID,
+		messageID:  messageID,
+		partID:     partID,
+		partType:   partType,
+		text:       runes,
+		normalized: normalizeRunes(runes),
+	}
+	s.docs[key] = doc
+	for _, gram := range bigrams(doc.normalized) {
+		postings, ok := s.grams[gram]
+		if !ok {
+			postings = make(map[string]struct{})
+			s.grams[gram] = postings
+		}
+		postings[key] = struct{}{}
+	}
+}
+
+func (s *SearchService) removeDoc(key string) {
+	doc, ok := s.docs[key]
+	if !ok {
+		return
+	}
+	for _, gram := range bigrams(doc.normalized) {
+		if postings, ok := s.grams[gram]; ok {
+			delete(postings, key)
+			if len(postings) == 0 {
+				delete(s.grams, gram)
+			}
+		}
+	}
+	delete(s.docs, key)
+}
+
+// toolPartSearchText returns the searchable text of a tool part: name, title, input and output.
+func toolPartSearchText(p models.ToolPart) string {
+	fields := []string{p.Tool}
+	state := p.StateMap()
+	if title, ok := state["title"].(string); ok {
+		fields = append(fields, title)
+	}
+	if input, ok := state["input"]; ok && input != nil {
+		if encoded, err := json.Marshal(input); err == nil {
+			fields = append(fields, string(encoded))
+		}
+	}
+	if output, ok := state["output"].(string); ok {
+		fields = append(fields, output)
+	}
+	return strings.Join(fields, "\n")
+}
+
+// normalizeRunes lowercases and folds full-width ASCII to half-width, one rune
+// per rune so offsets stay aligned with the original text.
+func normalizeRunes(runes []rune) []rune {
+	out := make([]rune, len(runes))
+	for i, r := range runes {
+		switch {
+		case r >= 0xFF01 && r <= 0xFF5E:
+			r -= 0xFEE0
+		case r == 0x3000:
+			r = ' '
+		}
+		out[i] = unicode.ToLower(r)
+	}
+	return out
+}
+
+// bigrams returns the distinct character bigrams of runes, skipping whitespace.
+func bigrams(runes []rune) []string {
+	seen := make(map[string]struct{})
+	grams := make([]string, 0, len(runes))
+	for i := 0; i+1 < len(runes); i++ {
+		if unicode.IsSpace(runes[i]) || unicode.IsSpace(runes[i+1]) {
+			continue
+		}
+		gram := string(runes[i : i+2])
+		if _, ok := seen[gram]; ok {
+			continue
+		}
+		seen[gram] = struct{}{}
+		grams = append(grams, gram)
+	}
+	return grams
+}
+
+// findAll returns the non-overlapping occurrences of term in text.
+func findAll(text, term []rune) []models.HighlightSpan {
+	var spans []models.HighlightSpan
+	if len(term) == 0 {
+		return spans
+	}
+	for i := 0; i+len(term) <= len(text); {
+		if runesEqual(text[i:i+len(term)], term) {
+			spans = append(spans, models.HighlightSpan{Start: i, End: i + len(term)})
+			i += len(term)
+			continue
+		}
+		i++
+	}
+	return spans
+}
+
+func runesEqual(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// buildSnippet cuts a window around the earliest match and rebases the
+// highlight spans that fall inside it. spans are rune offsets into text; the
+// returned highlights are UTF-16 offsets into the snippet.
+func buildSnippet(text []rune, spans []models.HighlightSpan) (string, []models.HighlightSpan) {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+
+	start := spans[0].Start - snippetRadius
+	if start < 0 {
+		start = 0
+	}
+	end := spans[0].End + snippetRadius
+	if end > len(text) {
+		end = len(text)
+	}
+
+	prefix := ""
+	if start > 0 {
+		prefix = "…"
+	}
+	suffix := ""
+	if end < len(text) {
+		suffix = "…"
+	}
+
+	offset := len([]rune(prefix)) - start
+	snippet := []rune(prefix + string(text[start:end]) + suffix)
+	highlights := make([]models.HighlightSpan, 0, len(spans))
+	for _, span := range spans {
+		if span.Start < start || span.End > end {
+			continue
+		}
+		highlights = append(highlights, models.HighlightSpan{
+			Start: utf16Len(snippet[:span.Start+offset]),
+			End:   utf16Len(snippet[:span.End+offset]),
+		})
+	}
+
+	return string(snippet), highlights
+}
+
+// utf16Len returns the length of runes in UTF-16 code units, the unit of
+// JavaScript string offsets.
+func utf16Len(runes []rune) int {
+	n := 0
+	for _, r := range runes {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
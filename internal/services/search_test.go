@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"unicode/utf16"
+
+	"fm-opencode-tinyapp/internal/api"
+	"fm-opencode-tinyapp/internal/models"
+)
+
+func messageEvent(sessionID, messageID, role string) *models.Event {
+	return &models.Event{
+		Type: "message.updated",
+		Properties: map[string]interface{}{
+			"info": map[string]interface{}{"id": messageID, "sessionID": sessionID, "role": role},
+		},
+	}
+}
+
+func textPartEvent(sessionID, messageID, partID, text string) *models.Event {
+	return &models.Event{
+		Type: "message.part.updated",
+		Properties: map[string]interface{}{
+			"part": map[string]interface{}{
+				"id": partID, "sessionID": sessionID, "messageID": messageID, "type": "text", "text": text,
+			},
+		},
+	}
+}
+
+// newTestSearchService returns a built index containing the given events.
+func newTestSearchService(events ...*models.Event) *SearchService {
+	s := NewSearchService(nil)
+	s.built = true
+	for _, event := range events {
+		s.HandleEvent(event)
+	}
+	return s
+}
+
+func TestBigrams(t *testing.T) {
+	got := bigrams([]rune("日本語 ab日本"))
+	want := []string{"日本", "本語", "ab", "b日"}
+	if len(got) != len(want) {
+		t.Fatalf("bigrams = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("bigrams = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestSearchJapanese(t *testing.T) {
+	s := newTestSearchService(
+		messageEvent("ses_1", "msg_1", "user"),
+		textPartEvent("ses_1", "msg_1", "prt_1", "検索インデックスを作り直してください"),
+		messageEvent("ses_1", "msg_2", "assistant"),
+		textPartEvent("ses_1", "msg_2", "prt_2", "インデックスの作成を始めます"),
+	)
+
+	results, err := s.Search(context.Background(), "インデックス 作り", nil)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 || results[0].PartID != "prt_1" {
+		t.Fatalf("results = %+v, want prt_1 only", results)
+	}
+
+	// A single-character term has no bigram and matches by scanning.
+	results, err = s.Search(context.Background(), "始", nil)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 || results[0].PartID != "prt_2" {
+		t.Fatalf("results = %+v, want prt_2 only", results)
+	}
+}
+
+func TestSearchNormalizesWidthAndCase(t *testing.T) {
+	s := newTestSearchService(
+		messageEvent("ses_1", "msg_1", "user"),
+		textPartEvent("ses_1", "msg_1", "prt_1", "ＧｏのTestを書く"),
+	)
+	results, err := s.Search(context.Background(), "go test", nil)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	s := newTestSearchService(
+		messageEvent("ses_1", "msg_1", "user"),
+		textPartEvent("ses_1", "msg_1", "prt_1", "deploy the app"),
+		messageEvent("ses_1", "msg_2", "assistant"),
+		textPartEvent("ses_1", "msg_2", "prt_2", "deploy finished"),
+		messageEvent("ses_2", "msg_3", "user"),
+		textPartEvent("ses_2", "msg_3", "prt_3", "deploy again"),
+	)
+
+	cases := []struct {
+		name    string
+		filters *models.MessageSearchFilters
+		want    []string
+	}{
+		{"no filters", nil, []string{"prt_3", "prt_2", "prt_1"}},
+		{"session", &models.MessageSearchFilters{SessionIDs: []string{"ses_1"}}, []string{"prt_2", "prt_1"}},
+		{"role", &models.MessageSearchFilters{Role: "assistant"}, []string{"prt_2"}},
+		{"part type", &models.MessageSearchFilters{PartTypes: []string{"tool"}}, nil},
+		{"limit", &models.MessageSearchFilters{Limit: 1}, []string{"prt_3"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			results, err := s.Search(context.Background(), "deploy", c.filters)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			if len(results) != len(c.want) {
+				t.Fatalf("results = %+v, want %v", results, c.want)
+			}
+			for i, result := range results {
+				if result.PartID != c.want[i] {
+					t.Errorf("results[%d] = %s, want %s", i, result.PartID, c.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSearchHighlightsAreUTF16Offsets(t *testing.T) {
+	s := newTestSearchService(
+		messageEvent("ses_1", "msg_1", "user"),
+		textPartEvent("ses_1", "msg_1", "prt_1", "🎉 お祝いの準備とお祝いの会"),
+	)
+	results, err := s.Search(context.Background(), "お祝い", nil)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+
+	snippet := utf16.Encode([]rune(results[0].Snippet))
+	highlights := results[0].Highlights
+	if len(highlights) != 2 {
+		t.Fatalf("highlights = %+v, want 2", highlights)
+	}
+	// The emoji before the first match takes two UTF-16 code units.
+	if highlights[0].Start != 3 {
+		t.Errorf("first highlight starts at %d, want 3", highlights[0].Start)
+	}
+	for _, h := range highlights {
+		if got := string(utf16.Decode(snippet[h.Start:h.End])); got != "お祝い" {
+			t.Errorf("highlight %+v covers %q", h, got)
+		}
+	}
+}
+
+func TestSearchSnippetHighlightsAfterEllipsis(t *testing.T) {
+	text := ""
+	for i := 0; i < 60; i++ {
+		text += "あ"
+	}
+	text += "目印"
+	s := newTestSearchService(
+		messageEvent("ses_1", "msg_1", "user"),
+		textPartEvent("ses_1", "msg_1", "prt_1", text),
+	)
+	results, err := s.Search(context.Background(), "目印", nil)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 || len(results[0].Highlights) != 1 {
+		t.Fatalf("results = %+v", results)
+	}
+	snippet := utf16.Encode([]rune(results[0].Snippet))
+	h := results[0].Highlights[0]
+	if got := string(utf16.Decode(snippet[h.Start:h.End])); got != "目印" {
+		t.Errorf("highlight %+v covers %q in %q", h, got, results[0].Snippet)
+	}
+}
+
+func TestSearchMessageRemoved(t *testing.T) {
+	s := newTestSearchService(
+		messageEvent("ses_1", "msg_1", "user"),
+		textPartEvent("ses_1", "msg_1", "prt_1", "remove me"),
+	)
+	s.HandleEvent(&models.Event{
+		Type:       "message.removed",
+		Properties: map[string]interface{}{"sessionID": "ses_1", "messageID": "msg_1"},
+	})
+	results, err := s.Search(context.Background(), "remove", nil)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("results = %+v, want none", results)
+	}
+}
+
+func TestBuildIndexReplaysEventsReceivedDuringBuild(t *testing.T) {
+	requested := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/session":
+			close(requested)
+			<-release
+			w.Write([]byte(`[{"id": "ses_1", "title": "old"}]`))
+		case "/session/ses_1/message":
+			w.Write([]byte(`[{"info": {"id": "msg_1", "sessionID": "ses_1", "role": "user"},
+				"parts": [{"id": "prt_1", "sessionID": "ses_1", "messageID": "msg_1", "type": "text", "text": "fetched text"}]}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	s := NewSearchService(api.NewClient(server.URL))
+	done := make(chan error)
+	go func() { done <- s.BuildIndex(context.Background()) }()
+
+	<-requested
+	s.HandleEvent(messageEvent("ses_1", "msg_2", "assistant"))
+	s.HandleEvent(textPartEvent("ses_1", "msg_2", "prt_2", "streamed during the build"))
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("BuildIndex: %v", err)
+	}
+
+	for _, query := range []string{"fetched", "streamed"} {
+		results, err := s.Search(context.Background(), query, nil)
+		if err != nil {
+			t.Fatalf("Search: %v", err)
+		}
+		if len(results) != 1 {
+			t.Errorf("Search(%q) = %+v, want 1 result", query, results)
+		}
+	}
+	if len(s.buffered) != 0 {
+		t.Errorf("%d events still buffered after the build", len(s.buffered))
+	}
+}
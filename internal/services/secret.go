@@ -0,0 +1,403 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"fm-opencode-tinyapp/internal/models"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// SecretPassphraseEnv overrides the per-install encryption key.
+	SecretPassphraseEnv = "FMOC_SECRET_PASSPHRASE"
+	// LLMAPIKeySecret is the secret name of the LLM API key.
+	LLMAPIKeySecret = "llm-api-key"
+
+	secretFileVersion = 1
+	secretKDFRounds   = 200000
+)
+
+// SecretStore stores named secrets.
+type SecretStore interface {
+	// Get returns the secret and whether it exists.
+	Get(name string) (string, bool, error)
+	Set(name, value string) error
+	Delete(name string) error
+}
+
+// secretFile is the on-disk format of EncryptedFileStore.
+type secretFile struct {
+	Version    int    `json:"version"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// EncryptedFileStore keeps secrets in a file encrypted with AES-256-GCM. The
+// key is derived from a passphrase with PBKDF2-HMAC-SHA256. A file that
+// cannot be decrypted with the passphrase is moved aside and the store
+// starts empty, so the secrets can be entered again.
+type EncryptedFileStore struct {
+	path       string
+	passphrase string
+	logger     *logrus.Logger
+
+	mu      sync.Mutex
+	loaded  bool
+	salt    []byte
+	secrets map[string]string
+}
+
+// NewEncryptedFileStore creates a store backed by the file at path. A nil
+// logger uses the standard logger.
+func NewEncryptedFileStore(path, passphrase string, logger *logrus.Logger) *EncryptedFileStore {
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+	return &EncryptedFileStore{
+		path:       path,
+		passphrase: passphrase,
+		logger:     logger,
+		secrets:    make(map[string]string),
+	}
+}
+
+// Get returns the named secret.
+func (s *EncryptedFileStore) Get(name string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return "", false, err
+	}
+	value, ok := s.secrets[name]
+	return value, ok, nil
+}
+
+// Set stores the named secret.
+func (s *EncryptedFileStore) Set(name, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	s.secrets[name] = value
+	return s.save()
+}
+
+// Delete removes the named secret.
+func (s *EncryptedFileStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	if _, ok := s.secrets[name]; !ok {
+		return nil
+	}
+	delete(s.secrets, name)
+	return s.save()
+}
+
+// load must be called with the lock held.
+func (s *EncryptedFileStore) load() error {
+	if s.loaded {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		s.loaded = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read secrets: %w", err)
+	}
+
+	var file secretFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse secrets: %w", err)
+	}
+	if file.Version != secretFileVersion {
+		return fmt.Errorf("unsupported secrets file version %d", file.Version)
+	}
+	plaintext, err := decryptSecretFile(s.passphrase, file)
+	if err != nil {
+		// The key changed (e.g. FMOC_SECRET_PASSPHRASE was unset). Keep the
+		// old file for recovery and start over instead of failing every write.
+		backup := s.path + ".unreadable"
+		if renameErr := os.Rename(s.path, backup); renameErr != nil {
+			return fmt.Errorf("%w; failed to move the file aside: %v", err, renameErr)
+		}
+		s.logger.Warnf("%v; moved it to %s and starting with an empty secret store", err, backup)
+		s.loaded = true
+		return nil
+	}
+	if err := json.Unmarshal(plaintext, &s.secrets); err != nil {
+		return fmt.Errorf("failed to parse secrets: %w", err)
+	}
+	if s.secrets == nil {
+		s.secrets = make(map[string]string)
+	}
+	s.salt = file.Salt
+	s.loaded = true
+	return nil
+}
+
+// save must be called with the lock held.
+func (s *EncryptedFileStore) save() error {
+	if s.salt == nil {
+		s.salt = make([]byte, 16)
+		if _, err := rand.Read(s.salt); err != nil {
+			return fmt.Errorf("failed to generate salt: %w", err)
+		}
+	}
+	gcm, err := newSecretCipher(s.passphrase, s.salt)
+	if err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(s.secrets)
+	if err != nil {
+		return fmt.Errorf("failed to marshal secrets: %w", err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	data, err := json.MarshalIndent(secretFile{
+		Version:    secretFileVersion,
+		Salt:       s.salt,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plaintext, nil),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal secrets: %w", err)
+	}
+	if err := writeFileAtomic(s.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write secrets: %w", err)
+	}
+	return nil
+}
+
+func decryptSecretFile(passphrase string, file secretFile) ([]byte, error) {
+	gcm, err := newSecretCipher(passphrase, file.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secrets (wrong passphrase?): %w", err)
+	}
+	return plaintext, nil
+}
+
+// reencrypt rewrites the file encrypted with from using the store's
+// passphrase. A missing file or one that from does not decrypt is left alone.
+func (s *EncryptedFileStore) reencrypt(from string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read secrets: %w", err)
+	}
+	var file secretFile
+	if err := json.Unmarshal(data, &file); err != nil || file.Version != secretFileVersion {
+		return nil
+	}
+	plaintext, err := decryptSecretFile(from, file)
+	if err != nil {
+		return nil
+	}
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return fmt.Errorf("failed to parse secrets: %w", err)
+	}
+	s.secrets = secrets
+	s.salt = nil
+	s.loaded = true
+	return s.save()
+}
+
+func newSecretCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2.Key([]byte(passphrase), salt, secretKDFRounds, 32, sha256.New))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return gcm, nil
+}
+
+// installKey returns the random key stored in secret.key in appDir, creating
+// it on first use. The key keeps secrets out of plain sight but does not
+// protect them from someone with access to the same account. created reports
+// whether the key was just generated.
+func installKey(appDir string) (key string, created bool, err error) {
+	path := filepath.Join(appDir, "secret.key")
+	data, err := os.ReadFile(path)
+	if err == nil && len(strings.TrimSpace(string(data))) > 0 {
+		return strings.TrimSpace(string(data)), false, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return "", false, fmt.Errorf("failed to read secret key: %w", err)
+	}
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", false, fmt.Errorf("failed to generate secret key: %w", err)
+	}
+	key = base64.StdEncoding.EncodeToString(raw)
+	if err := writeFileAtomic(path, []byte(key+"\n"), 0600); err != nil {
+		return "", false, fmt.Errorf("failed to write secret key: %w", err)
+	}
+	return key, true, nil
+}
+
+// legacyMachinePassphrase is the passphrase earlier versions derived from
+// the hostname, user and machine ID. It is only used to re-encrypt an
+// existing secret store with the install key.
+func legacyMachinePassphrase() string {
+	parts := []string{"fm-opencode-tinyapp"}
+	if host, err := os.Hostname(); err == nil {
+		parts = append(parts, host)
+	}
+	if u, err := user.Current(); err == nil {
+		parts = append(parts, u.Uid, u.Username)
+	}
+	for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		if id, err := os.ReadFile(path); err == nil {
+			parts = append(parts, strings.TrimSpace(string(id)))
+			break
+		}
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// SecretService resolves secret references in the app config. Environment
+// variables (FMOC_<NAME>, e.g. FMOC_LLM_API_KEY) take precedence over the store.
+type SecretService struct {
+	store SecretStore
+}
+
+// NewSecretService creates a SecretService backed by secrets.enc in the
+// application directory, encrypted with FMOC_SECRET_PASSPHRASE or, if unset,
+// a random per-install key kept in secret.key.
+func NewSecretService(logger *logrus.Logger) (*SecretService, error) {
+	appDir, err := AppDataDir()
+	if err != nil {
+		return nil, err
+	}
+	return newSecretServiceAt(appDir, os.Getenv(SecretPassphraseEnv), logger)
+}
+
+func newSecretServiceAt(appDir, passphrase string, logger *logrus.Logger) (*SecretService, error) {
+	path := filepath.Join(appDir, "secrets.enc")
+	if passphrase != "" {
+		return NewSecretServiceWithStore(NewEncryptedFileStore(path, passphrase, logger)), nil
+	}
+	key, created, err := installKey(appDir)
+	if err != nil {
+		return nil, err
+	}
+	store := NewEncryptedFileStore(path, key, logger)
+	if created {
+		if err := store.reencrypt(legacyMachinePassphrase()); err != nil {
+			return nil, err
+		}
+	}
+	return NewSecretServiceWithStore(store), nil
+}
+
+// NewSecretServiceWithStore creates a SecretService using the given store.
+func NewSecretServiceWithStore(store SecretStore) *SecretService {
+	return &SecretService{store: store}
+}
+
+// SecretEnvName returns the environment variable overriding the named secret.
+func SecretEnvName(name string) string {
+	return "FMOC_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// Resolve returns the secret a config value refers to. Plain values are
+// returned unchanged.
+func (s *SecretService) Resolve(value string) (string, error) {
+	name, ok := models.SecretName(value)
+	if !ok {
+		return value, nil
+	}
+	if env := os.Getenv(SecretEnvName(name)); env != "" {
+		return env, nil
+	}
+	secret, _, err := s.store.Get(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %s: %w", name, err)
+	}
+	return secret, nil
+}
+
+// ResolveLLM returns the LLM config with its API key resolved.
+func (s *SecretService) ResolveLLM(config models.LLMConfig) (models.LLMConfig, error) {
+	if config.APIKey == "" {
+		config.APIKey = os.Getenv(SecretEnvName(LLMAPIKeySecret))
+		return config, nil
+	}
+	key, err := s.Resolve(config.APIKey)
+	if err != nil {
+		return config, err
+	}
+	config.APIKey = key
+	return config, nil
+}
+
+// Protect moves plain secrets of config into the store and replaces them
+// with references. A masked value keeps the secret referenced by previous.
+// It reports whether config was changed.
+func (s *SecretService) Protect(config *models.AppConfig, previous *models.AppConfig) (bool, error) {
+	ref := models.SecretRef(LLMAPIKeySecret)
+	switch key := config.LLM.APIKey; {
+	case key == models.SecretMask:
+		if previous != nil {
+			config.LLM.APIKey = previous.LLM.APIKey
+		}
+		return false, nil
+	case key == "":
+		if previous != nil && previous.LLM.APIKey != "" {
+			if err := s.store.Delete(LLMAPIKeySecret); err != nil {
+				return false, fmt.Errorf("failed to delete secret: %w", err)
+			}
+		}
+		return false, nil
+	case strings.HasPrefix(key, models.SecretRefPrefix):
+		return false, nil
+	default:
+		if err := s.store.Set(LLMAPIKeySecret, key); err != nil {
+			return false, fmt.Errorf("failed to store secret: %w", err)
+		}
+		config.LLM.APIKey = ref
+		return true, nil
+	}
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fm-opencode-tinyapp/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestEncryptedFileStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	store := NewEncryptedFileStore(path, "passphrase", testLogger())
+	if err := store.Set("llm-api-key", "sk-test"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := store.Set("other", "value"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := store.Delete("other"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if strings.Contains(string(data), "sk-test") {
+		t.Error("secret stored in plain text")
+	}
+
+	reopened := NewEncryptedFileStore(path, "passphrase", testLogger())
+	value, ok, err := reopened.Get("llm-api-key")
+	if err != nil || !ok || value != "sk-test" {
+		t.Errorf("Get = %q, %v, %v; want sk-test, true, nil", value, ok, err)
+	}
+	if _, ok, _ := reopened.Get("other"); ok {
+		t.Error("deleted secret still present")
+	}
+}
+
+func TestEncryptedFileStorePassphraseChanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	if err := NewEncryptedFileStore(path, "passphrase", testLogger()).Set("llm-api-key", "sk-old"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	old, _ := os.ReadFile(path)
+
+	// A store opened with another passphrase starts empty and can be written.
+	store := NewEncryptedFileStore(path, "other passphrase", testLogger())
+	if _, ok, err := store.Get("llm-api-key"); err != nil || ok {
+		t.Fatalf("Get with changed passphrase = %v, %v; want an empty store", ok, err)
+	}
+	if err := store.Set("llm-api-key", "sk-new"); err != nil {
+		t.Fatalf("Set after passphrase change: %v", err)
+	}
+	value, ok, err := NewEncryptedFileStore(path, "other passphrase", testLogger()).Get("llm-api-key")
+	if err != nil || !ok || value != "sk-new" {
+		t.Errorf("Get = %q, %v, %v; want sk-new, true, nil", value, ok, err)
+	}
+
+	// The unreadable file is kept for recovery.
+	backup, err := os.ReadFile(path + ".unreadable")
+	if err != nil || string(backup) != string(old) {
+		t.Errorf("unreadable secrets not kept: %v", err)
+	}
+}
+
+func TestSecretServiceInstallKey(t *testing.T) {
+	dir := t.TempDir()
+	s, err := newSecretServiceAt(dir, "", testLogger())
+	if err != nil {
+		t.Fatalf("newSecretServiceAt: %v", err)
+	}
+	if err := s.store.Set(LLMAPIKeySecret, "sk-test"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "secret.key"))
+	if err != nil {
+		t.Fatalf("secret.key not created: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("secret.key mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	// Reopening uses the same key, independent of the hostname.
+	s, err = newSecretServiceAt(dir, "", testLogger())
+	if err != nil {
+		t.Fatalf("newSecretServiceAt: %v", err)
+	}
+	if value, ok, err := s.store.Get(LLMAPIKeySecret); err != nil || !ok || value != "sk-test" {
+		t.Errorf("Get = %q, %v, %v; want sk-test, true, nil", value, ok, err)
+	}
+}
+
+func TestSecretServiceReencryptsLegacyStore(t *testing.T) {
+	dir := t.TempDir()
+	legacy := NewEncryptedFileStore(filepath.Join(dir, "secrets.enc"), legacyMachinePassphrase(), testLogger())
+	if err := legacy.Set(LLMAPIKeySecret, "sk-legacy"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	s, err := newSecretServiceAt(dir, "", testLogger())
+	if err != nil {
+		t.Fatalf("newSecretServiceAt: %v", err)
+	}
+	if value, ok, err := s.store.Get(LLMAPIKeySecret); err != nil || !ok || value != "sk-legacy" {
+		t.Errorf("Get = %q, %v, %v; want sk-legacy, true, nil", value, ok, err)
+	}
+	key, _ := os.ReadFile(filepath.Join(dir, "secret.key"))
+	store := NewEncryptedFileStore(filepath.Join(dir, "secrets.enc"), strings.TrimSpace(string(key)), testLogger())
+	if value, _, _ := store.Get(LLMAPIKeySecret); value != "sk-legacy" {
+		t.Error("legacy store was not re-encrypted with the install key")
+	}
+}
+
+func TestEncryptedFileStoreVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	if err := NewEncryptedFileStore(path, "passphrase", testLogger()).Set("llm-api-key", "sk-test"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	var file secretFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if file.Version != secretFileVersion {
+		t.Errorf("got version %d, want %d", file.Version, secretFileVersion)
+	}
+
+	file.Version = secretFileVersion + 1
+	data, _ = json.Marshal(file)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	_, _, err = NewEncryptedFileStore(path, "passphrase", testLogger()).Get("llm-api-key")
+	if err == nil || !strings.Contains(err.Error(), "unsupported secrets file version") {
+		t.Errorf("Get with newer version: err = %v", err)
+	}
+}
+
+// testLogger returns a logger that discards its output.
+func testLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// memorySecretStore is a SecretStore kept in memory.
+type memorySecretStore map[string]string
+
+func (s memorySecretStore) Get(name string) (string, bool, error) {
+	value, ok := s[name]
+	return value, ok, nil
+}
+
+func (s memorySecretStore) Set(name, value string) error {
+	s[name] = value
+	return nil
+}
+
+func (s memorySecretStore) Delete(name string) error {
+	delete(s, name)
+	return nil
+}
+
+func TestSecretServiceProtect(t *testing.T) {
+	ref := models.SecretRef(LLMAPIKeySecret)
+	cases := []struct {
+		name        string
+		key         string
+		previous    string
+		stored      map[string]string
+		wantKey     string
+		wantChanged bool
+		wantStored  map[string]string
+	}{
+		{
+			name:       "masked value keeps the previous reference",
+			key:        models.SecretMask,
+			previous:   ref,
+			stored:     map[string]string{LLMAPIKeySecret: "sk-old"},
+			wantKey:    ref,
+			wantStored: map[string]string{LLMAPIKeySecret: "sk-old"},
+		},
+		{
+			name:       "empty value deletes the secret",
+			key:        "",
+			previous:   ref,
+			stored:     map[string]string{LLMAPIKeySecret: "sk-old"},
+			wantKey:    "",
+			wantStored: map[string]string{},
+		},
+		{
+			name:        "plain value becomes a reference",
+			key:         "sk-new",
+			previous:    ref,
+			stored:      map[string]string{LLMAPIKeySecret: "sk-old"},
+			wantKey:     ref,
+			wantChanged: true,
+			wantStored:  map[string]string{LLMAPIKeySecret: "sk-new"},
+		},
+		{
+			name:       "reference is kept",
+			key:        ref,
+			previous:   ref,
+			stored:     map[string]string{LLMAPIKeySecret: "sk-old"},
+			wantKey:    ref,
+			wantStored: map[string]string{LLMAPIKeySecret: "sk-old"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			store := memorySecretStore{}
+			for name, value := range c.stored {
+				store[name] = value
+			}
+			s := NewSecretServiceWithStore(store)
+			config := &models.AppConfig{LLM: models.LLMConfig{APIKey: c.key}}
+			previous := &models.AppConfig{LLM: models.LLMConfig{APIKey: c.previous}}
+
+			changed, err := s.Protect(config, previous)
+			if err != nil {
+				t.Fatalf("Protect: %v", err)
+			}
+			if changed != c.wantChanged {
+				t.Errorf("changed = %v, want %v", changed, c.wantChanged)
+			}
+			if config.LLM.APIKey != c.wantKey {
+				t.Errorf("APIKey = %q, want %q", config.LLM.APIKey, c.wantKey)
+			}
+			if len(store) != len(c.wantStored) {
+				t.Errorf("store = %v, want %v", store, c.wantStored)
+			}
+			for name, value := range c.wantStored {
+				if store[name] != value {
+					t.Errorf("store[%s] = %q, want %q", name, store[name], value)
+				}
+			}
+		})
+	}
+}
+
+func TestSecretServiceResolveLLM(t *testing.T) {
+	t.Setenv(SecretEnvName(LLMAPIKeySecret), "")
+	s := NewSecretServiceWithStore(memorySecretStore{LLMAPIKeySecret: "sk-stored"})
+
+	config, err := s.ResolveLLM(models.LLMConfig{APIKey: models.SecretRef(LLMAPIKeySecret)})
+	if err != nil || config.APIKey != "sk-stored" {
+		t.Errorf("ResolveLLM = %q, %v; want sk-stored", config.APIKey, err)
+	}
+
+	t.Setenv(SecretEnvName(LLMAPIKeySecret), "sk-env")
+	config, err = s.ResolveLLM(models.LLMConfig{APIKey: models.SecretRef(LLMAPIKeySecret)})
+	if err != nil || config.APIKey != "sk-env" {
+		t.Errorf("ResolveLLM = %q, %v; want sk-env", config.APIKey, err)
+	}
+}
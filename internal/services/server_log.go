@@ -0,0 +1,235 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"fm-opencode-tinyapp/internal/models"
+)
+
+const (
+	maxServerLogLines         = 2000
+	maxServerLogLineLength    = 16 * 1024
+	defaultServerLogMaxSizeKB = 5 * 1024
+	defaultServerLogMaxFiles  = 3
+)
+
+// ServerLog keeps the most recent output lines of the opencode server in a
+// ring buffer and optionally appends them to a size-rotated file.
+type ServerLog struct {
+	onLine func(line models.ServerLogLine)
+
+	mu    sync.Mutex
+	lines []models.ServerLogLine // Ring buffer
+	next  int                    // Index of the oldest line once full
+	file  *rotatingFile
+}
+
+// NewServerLog creates a new ServerLog. onLine is called for every line and may be nil.
+func NewServerLog(onLine func(line models.ServerLogLine)) *ServerLog {
+	return &ServerLog{
+		onLine: onLine,
+		lines:  make([]models.ServerLogLine, 0, maxServerLogLines),
+	}
+}
+
+// SetFile configures the log file from the server process settings. An
+// empty LogFile disables writing to a file.
+func (l *ServerLog) SetFile(config models.ServerProcess) error {
+	var file *rotatingFile
+	if config.LogFile != "" {
+		path := config.LogFile
+		if !filepath.IsAbs(path) {
+			appDir, err := AppDataDir()
+			if err != nil {
+				return err
+			}
+			path = filepath.Join(appDir, path)
+		}
+		maxSize := int64(config.LogMaxSize) * 1024
+		if maxSize <= 0 {
+			maxSize = defaultServerLogMaxSizeKB * 1024
+		}
+		maxFiles := config.LogMaxFiles
+		if maxFiles <= 0 {
+			maxFiles = defaultServerLogMaxFiles
+		}
+		file = &rotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		if file != nil && file.path == l.file.path && file.maxSize == l.file.maxSize && file.maxFiles == l.file.maxFiles {
+			return nil
+		}
+		l.file.Close()
+	}
+	l.file = file
+	return nil
+}
+
+// Append records a line.
+func (l *ServerLog) Append(stream, text string) {
+	line := models.ServerLogLine{Time: time.Now().UnixMilli(), Stream: stream, Text: text}
+
+	var fileErr error
+	l.mu.Lock()
+	if len(l.lines) < maxServerLogLines {
+		l.lines = append(l.lines, line)
+	} else {
+		l.lines[l.next] = line
+		l.next = (l.next + 1) % maxServerLogLines
+	}
+	if l.file != nil {
+		stamp := time.UnixMilli(line.Time).Format("2006-01-02T15:04:05.000Z07:00")
+		if fileErr = l.file.WriteLine(fmt.Sprintf("%s [%s] %s", stamp, stream, text)); fileErr != nil {
+			// Keep the in-memory log working without the file.
+			l.file.Close()
+			l.file = nil
+		}
+	}
+	l.mu.Unlock()
+
+	if l.onLine != nil {
+		l.onLine(line)
+	}
+	if fileErr != nil {
+		l.Append(models.ServerLogSupervisor, "log file disabled: "+fileErr.Error())
+	}
+}
+
+// Lines returns the last n lines, oldest first. n <= 0 returns all kept lines.
+func (l *ServerLog) Lines(n int) []models.ServerLogLine {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ordered := make([]models.ServerLogLine, 0, len(l.lines))
+	ordered = append(ordered, l.lines[l.next:]...)
+	ordered = append(ordered, l.lines[:l.next]...)
+	if n > 0 && len(ordered) > n {
+		ordered = ordered[len(ordered)-n:]
+	}
+	return ordered
+}
+
+// Writer returns a writer that records every line written to it under the
+// given stream. Close flushes an unterminated last line.
+func (l *ServerLog) Writer(stream string) io.WriteCloser {
+	return &serverLogWriter{log: l, stream: stream}
+}
+
+// Close closes the log file.
+func (l *ServerLog) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+}
+
+// serverLogWriter splits process output into lines.
+type serverLogWriter struct {
+	log    *ServerLog
+	stream string
+	mu     sync.Mutex
+	buf    bytes.Buffer
+}
+
+func (w *serverLogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		line := string(w.buf.Next(i + 1))
+		w.log.Append(w.stream, strings.TrimRight(line, "\r\n"))
+	}
+	// Split overly long lines instead of buffering without bound.
+	for w.buf.Len() >= maxServerLogLineLength {
+		w.log.Append(w.stream, string(w.buf.Next(maxServerLogLineLength)))
+	}
+	return len(p), nil
+}
+
+func (w *serverLogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.buf.Len() > 0 {
+		w.log.Append(w.stream, strings.TrimRight(w.buf.String(), "\r\n"))
+		w.buf.Reset()
+	}
+	return nil
+}
+
+// rotatingFile appends lines to path. When the file would exceed maxSize it
+// is renamed to path.1, shifting older files up to path.<maxFiles>.
+type rotatingFile struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	f    *os.File
+	size int64
+}
+
+func (r *rotatingFile) WriteLine(line string) error {
+	data := []byte(line + "\n")
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+	if r.size > 0 && r.size+int64(len(data)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := r.f.Write(data)
+	r.size += int64(n)
+	return err
+}
+
+func (r *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0750); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) rotate() error {
+	r.Close()
+	for i := r.maxFiles - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Close() {
+	if r.f != nil {
+		r.f.Close()
+		r.f = nil
+	}
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fm-opencode-tinyapp/internal/models"
+)
+
+func logTexts(lines []models.ServerLogLine) []string {
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = line.Text
+	}
+	return texts
+}
+
+func TestServerLogLines(t *testing.T) {
+	l := NewServerLog(nil)
+	if lines := l.Lines(0); len(lines) != 0 {
+		t.Errorf("empty log has lines %v", lines)
+	}
+	for i := 0; i < 5; i++ {
+		l.Append(models.ServerLogStdout, fmt.Sprint(i))
+	}
+
+	cases := []struct {
+		n    int
+		want string
+	}{
+		{0, "0 1 2 3 4"},
+		{-1, "0 1 2 3 4"},
+		{2, "3 4"},
+		{5, "0 1 2 3 4"},
+		{10, "0 1 2 3 4"},
+	}
+	for _, c := range cases {
+		if got := strings.Join(logTexts(l.Lines(c.n)), " "); got != c.want {
+			t.Errorf("Lines(%d) = %s, want %s", c.n, got, c.want)
+		}
+	}
+}
+
+func TestServerLogWrapsAround(t *testing.T) {
+	l := NewServerLog(nil)
+	total := maxServerLogLines + 10
+	for i := 0; i < total; i++ {
+		l.Append(models.ServerLogStdout, fmt.Sprint(i))
+	}
+
+	lines := l.Lines(0)
+	if len(lines) != maxServerLogLines {
+		t.Fatalf("kept %d lines, want %d", len(lines), maxServerLogLines)
+	}
+	for i, line := range lines {
+		if want := fmt.Sprint(total - maxServerLogLines + i); line.Text != want {
+			t.Fatalf("line %d = %s, want %s (oldest first)", i, line.Text, want)
+		}
+	}
+	if got := strings.Join(logTexts(l.Lines(3)), " "); got != fmt.Sprintf("%d %d %d", total-3, total-2, total-1) {
+		t.Errorf("Lines(3) after wrapping = %s", got)
+	}
+}
+
+func TestServerLogWriter(t *testing.T) {
+	var streamed []models.ServerLogLine
+	l := NewServerLog(func(line models.ServerLogLine) { streamed = append(streamed, line) })
+	w := l.Writer(models.ServerLogStderr)
+
+	w.Write([]byte("first\r\nsec"))
+	w.Write([]byte("ond\n\nthi"))
+	if got := logTexts(l.Lines(0)); strings.Join(got, "|") != "first|second|" {
+		t.Errorf("lines before Close = %q", got)
+	}
+	w.Close()
+	if got := logTexts(l.Lines(0)); strings.Join(got, "|") != "first|second||thi" {
+		t.Errorf("lines after Close = %q", got)
+	}
+	if len(streamed) != 4 || streamed[0].Stream != models.ServerLogStderr || streamed[0].Time == 0 {
+		t.Errorf("onLine received %+v", streamed)
+	}
+
+	// Long lines are split instead of buffered without bound.
+	l = NewServerLog(nil)
+	w = l.Writer(models.ServerLogStdout)
+	w.Write([]byte(strings.Repeat("x", maxServerLogLineLength+5)))
+	if lines := l.Lines(0); len(lines) != 1 || len(lines[0].Text) != maxServerLogLineLength {
+		t.Errorf("long line was not split at %d bytes", maxServerLogLineLength)
+	}
+	w.Close()
+	if lines := l.Lines(0); len(lines) != 2 || lines[1].Text != "xxxxx" {
+		t.Errorf("rest of the long line = %+v", lines[len(lines)-1])
+	}
+}
+
+func TestServerLogFileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "server.log")
+	l := NewServerLog(nil)
+	defer l.Close()
+	if err := l.SetFile(models.ServerProcess{LogFile: path, LogMaxSize: 1, LogMaxFiles: 2}); err != nil {
+		t.Fatalf("SetFile: %v", err)
+	}
+
+	// Each line is about 100 bytes, so a 1 KB file holds fewer than 15.
+	line := strings.Repeat("y", 60)
+	for i := 0; i < 40; i++ {
+		l.Append(models.ServerLogStdout, fmt.Sprintf("%02d %s", i, line))
+	}
+	l.Close()
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("Stat: %v", err)
+		}
+		if info.Size() > 1024 {
+			t.Errorf("%s has %d bytes, want at most 1024", filepath.Base(name), info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("more than LogMaxFiles rotated files are kept: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !strings.Contains(string(data), "[stdout] 39 "+line+"\n") {
+		t.Errorf("current file does not end with the last line:\n%s", data)
+	}
+	if lines := l.Lines(0); len(lines) != 40 {
+		t.Errorf("in-memory log has %d lines, want 40", len(lines))
+	}
+}
+
+func TestServerLogFileErrorKeepsMemoryLog(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "file")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	l := NewServerLog(nil)
+	// The log directory cannot be created below a regular file.
+	if err := l.SetFile(models.ServerProcess{LogFile: filepath.Join(blocker, "server.log")}); err != nil {
+		t.Fatalf("SetFile: %v", err)
+	}
+	l.Append(models.ServerLogStdout, "hello")
+	l.Append(models.ServerLogStdout, "world")
+
+	lines := l.Lines(0)
+	if len(lines) != 3 || lines[0].Text != "hello" || lines[2].Text != "world" {
+		t.Fatalf("lines = %+v", lines)
+	}
+	if lines[1].Stream != models.ServerLogSupervisor || !strings.HasPrefix(lines[1].Text, "log file disabled: ") {
+		t.Errorf("file error was not reported once: %+v", lines[1])
+	}
+}
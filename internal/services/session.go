@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"fm-opencode-tinyapp/internal/api"
 	"fm-opencode-tinyapp/internal/models"
 )
@@ -16,34 +17,34 @@ func NewSessionService(apiClient *api.Client) *SessionService {
 }
 
 // GetSessions retrieves all sessions.
-func (s *SessionService) GetSessions() ([]models.Session, error) {
-	return s.apiClient.GetSessions()
+func (s *SessionService) GetSessions(ctx context.Context) ([]models.Session, error) {
+	return s.apiClient.GetSessions(ctx)
 }
 
 // GetSession retrieves a single session by its ID.
-func (s *SessionService) GetSession(id string) (*models.Session, error) {
-	return s.apiClient.GetSession(id)
+func (s *SessionService) GetSession(ctx context.Context, id string) (*models.Session, error) {
+	return s.apiClient.GetSession(ctx, id)
 }
 
 // CreateSession creates a new session.
-func (s *SessionService) CreateSession(title string) (*models.Session, error) {
+func (s *SessionService) CreateSession(ctx context.Context, title string) (*models.Session, error) {
 	if title == "" {
 		title = "New Session"
 	}
-	return s.apiClient.CreateSession(title)
+	return s.apiClient.CreateSession(ctx, title)
 }
 
 // UpdateSession updates a session's title.
-func (s *SessionService) UpdateSession(id, title string) (*models.Session, error) {
-	return s.apiClient.UpdateSession(id, title)
+func (s *SessionService) UpdateSession(ctx context.Context, id, title string) (*models.Session, error) {
+	return s.apiClient.UpdateSession(ctx, id, title)
 }
 
 // DeleteSession deletes a session.
-func (s *SessionService) DeleteSession(id string) error {
-	return s.apiClient.DeleteSession(id)
+func (s *SessionService) DeleteSession(ctx context.Context, id string) error {
+	return s.apiClient.DeleteSession(ctx, id)
 }
 
 // SummarizeSession summarizes a session (generates session title/summary).
-func (s *SessionService) SummarizeSession(id string, providerID string, modelID string) error {
-	return s.apiClient.SummarizeSession(id, providerID, modelID)
+func (s *SessionService) SummarizeSession(ctx context.Context, id string, providerID string, modelID string) error {
+	return s.apiClient.SummarizeSession(ctx, id, providerID, modelID)
 }
@@ -0,0 +1,392 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"fm-opencode-tinyapp/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultServerBinary       = "opencode"
+	defaultServerReadyTimeout = 30 * time.Second
+	serverPollInterval        = 250 * time.Millisecond
+	serverRestartMinDelay     = time.Second
+	serverRestartMaxDelay     = 30 * time.Second
+	serverStableAfter         = time.Minute // Resets the restart delay
+	serverStopTimeout         = 5 * time.Second
+)
+
+// ServerSupervisor runs the local opencode server. It starts the process on
+// the host and port of the server URL, waits for it to become ready and
+// restarts it with exponential backoff when it exits.
+type ServerSupervisor struct {
+	logger   *logrus.Logger
+	log      *ServerLog
+	onStatus func(status models.ServerStatus)
+
+	mu        sync.Mutex
+	serverURL string
+	config    models.ServerProcess
+	status    models.ServerStatus
+	stop      chan struct{} // Closed to stop the running supervision loop
+	done      chan struct{} // Closed when the supervision loop has exited
+}
+
+// NewServerSupervisor creates a new ServerSupervisor. The process output is
+// recorded in serverLog. onStatus is called on every status change and may be nil.
+func NewServerSupervisor(logger *logrus.Logger, serverLog *ServerLog, onStatus func(status models.ServerStatus)) *ServerSupervisor {
+	return &ServerSupervisor{
+		logger:   logger,
+		log:      serverLog,
+		onStatus: onStatus,
+		status:   models.ServerStatus{State: models.ServerStateStopped},
+	}
+}
+
+// Configure sets the server URL and process settings used by the next Start.
+func (s *ServerSupervisor) Configure(serverURL string, config models.ServerProcess) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.serverURL = serverURL
+	s.config = config
+	if err := s.log.SetFile(config); err != nil {
+		s.logger.Warnf("failed to set server log file: %v", err)
+	}
+}
+
+// Status returns the current status.
+func (s *ServerSupervisor) Status() models.ServerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Start starts supervising the server. Nothing is started if the URL is not
+// local, management is disabled or a server is already listening.
+func (s *ServerSupervisor) Start() error {
+	s.mu.Lock()
+	if s.stop != nil {
+		s.mu.Unlock()
+		return nil
+	}
+	serverURL, config := s.serverURL, s.config
+	s.mu.Unlock()
+
+	host, port, err := serverListenAddress(serverURL)
+	if err != nil {
+		s.update(func(status *models.ServerStatus) {
+			*status = models.ServerStatus{State: models.ServerStateExternal, URL: serverURL, LastError: err.Error()}
+		})
+		return err
+	}
+	external := func(reason string) error {
+		s.logger.Infof("not starting opencode server: %s", reason)
+		s.update(func(status *models.ServerStatus) {
+			*status = models.ServerStatus{State: models.ServerStateExternal, URL: serverURL}
+		})
+		return nil
+	}
+	if config.Disabled {
+		return external("disabled in config")
+	}
+	if !isLocalHost(host) {
+		return external(serverURL + " is not on this machine")
+	}
+	if isTCPPortOpen(net.JoinHostPort(dialHost(host), port), 500*time.Millisecond) {
+		return external("a server is already listening on " + serverURL)
+	}
+
+	s.mu.Lock()
+	if s.stop != nil {
+		s.mu.Unlock()
+		return nil
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	s.stop, s.done = stop, done
+	s.status = models.ServerStatus{State: models.ServerStateStarting, URL: serverURL, Managed: true}
+	status := s.status
+	s.mu.Unlock()
+
+	// Report Starting before the loop can report anything newer.
+	if s.onStatus != nil {
+		s.onStatus(status)
+	}
+	go s.run(serverURL, host, port, config, stop, done)
+	return nil
+}
+
+// Stop stops the supervised server and waits for it to exit.
+func (s *ServerSupervisor) Stop() error {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mu.Unlock()
+	if stop == nil {
+		return nil
+	}
+	close(stop)
+	<-done
+	return nil
+}
+
+// Restart stops the server and starts it again with the current configuration.
+func (s *ServerSupervisor) Restart() error {
+	if err := s.Stop(); err != nil {
+		return err
+	}
+	return s.Start()
+}
+
+// WaitReady polls the server URL until the server responds, ctx is done or
+// the configured ready timeout has passed.
+func (s *ServerSupervisor) WaitReady(ctx context.Context) error {
+	s.mu.Lock()
+	serverURL, timeout := s.serverURL, readyTimeout(s.config)
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(serverPollInterval)
+	defer ticker.Stop()
+	for {
+		if serverReady(serverURL) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("server at %s not ready: %w", serverURL, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ServerSupervisor) update(change func(status *models.ServerStatus)) {
+	s.mu.Lock()
+	change(&s.status)
+	status := s.status
+	s.mu.Unlock()
+	if s.onStatus != nil {
+		s.onStatus(status)
+	}
+}
+
+// run is the supervision loop. It restarts the process until stop is closed.
+func (s *ServerSupervisor) run(serverURL, host, port string, config models.ServerProcess, stop, done chan struct{}) {
+	defer close(done)
+
+	delay := serverRestartMinDelay
+	for {
+		started := time.Now()
+		exitCode, err := s.runOnce(serverURL, host, port, config, stop)
+
+		select {
+		case <-stop:
+			s.update(func(status *models.ServerStatus) {
+				status.State = models.ServerStateStopped
+				status.PID = 0
+			})
+			return
+		default:
+		}
+
+		if time.Since(started) > serverStableAfter {
+			delay = serverRestartMinDelay
+		}
+		s.logger.Warnf("opencode server exited: %v; restarting in %s", err, delay)
+		s.log.Append(models.ServerLogSupervisor, fmt.Sprintf("server exited: %v; restarting in %s", err, delay))
+		s.update(func(status *models.ServerStatus) {
+			status.State = models.ServerStateBackoff
+			status.PID = 0
+			status.Restarts++
+			status.LastError = err.Error()
+			status.ExitCode = exitCode
+		})
+
+		select {
+		case <-stop:
+			s.update(func(status *models.ServerStatus) { status.State = models.ServerStateStopped })
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > serverRestartMaxDelay {
+			delay = serverRestartMaxDelay
+		}
+	}
+}
+
+// runOnce starts the process and blocks until it exits or stop is closed,
+// returning the exit code. A process that does not become ready in time is killed.
+func (s *ServerSupervisor) runOnce(serverURL, host, port string, config models.ServerProcess, stop chan struct{}) (int, error) {
+	binary := config.Binary
+	if binary == "" {
+		binary = defaultServerBinary
+	}
+	args := append([]string{"serve", "--hostname", host, "--port", port}, config.Args...)
+	cmd := exec.Command(binary, args...)
+	cmd.Dir = config.Dir
+	cmd.Env = serverEnv(config.Env)
+	stdout := s.log.Writer(models.ServerLogStdout)
+	stderr := s.log.Writer(models.ServerLogStderr)
+	defer stdout.Close()
+	defer stderr.Close()
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Children inheriting the pipes must not keep Wait from returning.
+	cmd.WaitDelay = 2 * time.Second
+	s.log.Append(models.ServerLogSupervisor, "starting: "+binary+" "+strings.Join(args, " "))
+	if err := cmd.Start(); err != nil {
+		s.log.Append(models.ServerLogSupervisor, fmt.Sprintf("failed to start %s: %v", binary, err))
+		return -1, fmt.Errorf("failed to start %s: %w", binary, err)
+	}
+	s.logger.Infof("started opencode server (pid=%d): %s %s", cmd.Process.Pid, binary, strings.Join(args, " "))
+	s.update(func(status *models.ServerStatus) {
+		status.State = models.ServerStateStarting
+		status.PID = cmd.Process.Pid
+		status.StartedAt = time.Now().UnixMilli()
+		status.ExitCode = 0
+	})
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	ticker := time.NewTicker(serverPollInterval)
+	defer ticker.Stop()
+	deadline := time.Now().Add(readyTimeout(config))
+	ready := false
+	for {
+		select {
+		case err := <-exited:
+			if err == nil {
+				err = errors.New("exited with status 0")
+			}
+			return cmd.ProcessState.ExitCode(), err
+		case <-stop:
+			s.log.Append(models.ServerLogSupervisor, "stopping")
+			terminateProcess(cmd, exited)
+			return 0, nil
+		case <-ticker.C:
+			if ready {
+				continue
+			}
+			if serverReady(serverURL) {
+				ready = true
+				s.update(func(status *models.ServerStatus) {
+					status.State = models.ServerStateRunning
+					status.LastError = ""
+				})
+			} else if time.Now().After(deadline) {
+				s.log.Append(models.ServerLogSupervisor, fmt.Sprintf("not ready within %s, stopping", readyTimeout(config)))
+				terminateProcess(cmd, exited)
+				return cmd.ProcessState.ExitCode(), fmt.Errorf("not ready within %s", readyTimeout(config))
+			}
+		}
+	}
+}
+
+// terminateProcess asks the process to exit and kills it if it does not.
+// exited receives the result of cmd.Wait.
+func terminateProcess(cmd *exec.Cmd, exited chan error) {
+	// Interrupt signals are not supported on Windows; Kill is used instead.
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		_ = cmd.Process.Kill()
+	}
+	select {
+	case <-exited:
+	case <-time.After(serverStopTimeout):
+		_ = cmd.Process.Kill()
+		<-exited
+	}
+}
+
+func readyTimeout(config models.ServerProcess) time.Duration {
+	if config.ReadyTimeout > 0 {
+		return time.Duration(config.ReadyTimeout) * time.Second
+	}
+	return defaultServerReadyTimeout
+}
+
+func serverEnv(extra map[string]string) []string {
+	env := os.Environ()
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		env = append(env, key+"="+extra[key])
+	}
+	return env
+}
+
+// serverListenAddress returns the host and port of the server URL, using
+// the scheme's default port if none is given.
+func serverListenAddress(serverURL string) (string, string, error) {
+	parsedURL, err := url.Parse(serverURL)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid server URL %q: %w", serverURL, err)
+	}
+	host := parsedURL.Hostname()
+	if host == "" {
+		return "", "", fmt.Errorf("server URL %q has no host", serverURL)
+	}
+	port := parsedURL.Port()
+	if port == "" {
+		port = "80"
+		if parsedURL.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return host, port, nil
+}
+
+func isLocalHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsUnspecified())
+}
+
+// dialHost returns an address to connect to for a listen host.
+func dialHost(host string) string {
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		return "127.0.0.1"
+	}
+	return host
+}
+
+func isTCPPortOpen(address string, timeout time.Duration) bool {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+var readyClient = &http.Client{Timeout: time.Second}
+
+// serverReady reports whether the opencode API answers at serverURL.
+func serverReady(serverURL string) bool {
+	resp, err := readyClient.Get(strings.TrimRight(serverURL, "/") + "/config")
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode < http.StatusInternalServerError
+}
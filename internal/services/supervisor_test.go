@@ -0,0 +1,265 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"fm-opencode-tinyapp/internal/models"
+)
+
+// fakeServerModeEnv makes the test binary act as "opencode serve" when it is
+// started by a ServerSupervisor: "serve" answers the API, "exit" fails right
+// away and "hang" never becomes ready.
+const fakeServerModeEnv = "SUPERVISOR_TEST_SERVER"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(fakeServerModeEnv) {
+	case "serve":
+		runFakeServer()
+	case "exit":
+		fmt.Fprintln(os.Stderr, "crashed")
+		os.Exit(3)
+	case "hang":
+		select {}
+	}
+	os.Exit(m.Run())
+}
+
+// runFakeServer serves the API on the address given as
+// "serve --hostname <host> --port <port>".
+func runFakeServer() {
+	var host, port string
+	for i, arg := range os.Args {
+		if i+1 < len(os.Args) {
+			switch arg {
+			case "--hostname":
+				host = os.Args[i+1]
+			case "--port":
+				port = os.Args[i+1]
+			}
+		}
+	}
+	fmt.Println("listening on " + net.JoinHostPort(host, port))
+	err := http.ListenAndServe(net.JoinHostPort(host, port), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+// freeServerURL returns the URL of a local port nothing listens on.
+func freeServerURL(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer listener.Close()
+	return "http://" + listener.Addr().String()
+}
+
+// newTestSupervisor returns a supervisor running the test binary in mode
+// and a channel receiving every status change.
+func newTestSupervisor(t *testing.T, serverURL, mode string) (*ServerSupervisor, *ServerLog, chan models.ServerStatus) {
+	t.Helper()
+	skipOnWindows(t)
+	binary, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Executable: %v", err)
+	}
+	statuses := make(chan models.ServerStatus, 100)
+	serverLog := NewServerLog(nil)
+	s := NewServerSupervisor(testLogger(), serverLog, func(status models.ServerStatus) { statuses <- status })
+	s.Configure(serverURL, models.ServerProcess{
+		Binary:       binary,
+		Env:          map[string]string{fakeServerModeEnv: mode},
+		ReadyTimeout: 1,
+	})
+	t.Cleanup(func() { s.Stop() })
+	return s, serverLog, statuses
+}
+
+// waitStatus returns the first reported status in state.
+func waitStatus(t *testing.T, statuses chan models.ServerStatus, state string) models.ServerStatus {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case status := <-statuses:
+			if status.State == state {
+				return status
+			}
+		case <-timeout:
+			t.Fatalf("no %s status reported", state)
+		}
+	}
+}
+
+func TestServerSupervisorRunsServer(t *testing.T) {
+	serverURL := freeServerURL(t)
+	s, serverLog, statuses := newTestSupervisor(t, serverURL, "serve")
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	// The first status is reported by Start itself, before the process runs.
+	select {
+	case first := <-statuses:
+		want := models.ServerStatus{State: models.ServerStateStarting, URL: serverURL, Managed: true}
+		if first != want {
+			t.Errorf("first status = %+v, want %+v", first, want)
+		}
+	default:
+		t.Fatal("Start did not report the starting status")
+	}
+
+	running := waitStatus(t, statuses, models.ServerStateRunning)
+	if running.PID == 0 || running.StartedAt == 0 || !running.Managed {
+		t.Errorf("running status = %+v", running)
+	}
+	if s.Status() != running {
+		t.Errorf("Status() = %+v, want %+v", s.Status(), running)
+	}
+	if err := s.Start(); err != nil {
+		t.Errorf("second Start: %v", err)
+	}
+
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	stopped := waitStatus(t, statuses, models.ServerStateStopped)
+	if stopped.PID != 0 || stopped.Restarts != 0 {
+		t.Errorf("stopped status = %+v", stopped)
+	}
+
+	var text []string
+	for _, line := range serverLog.Lines(0) {
+		text = append(text, line.Stream+": "+line.Text)
+	}
+	log := strings.Join(text, "\n")
+	for _, want := range []string{"supervisor: starting: ", "stdout: listening on ", "supervisor: stopping"} {
+		if !strings.Contains(log, want) {
+			t.Errorf("log does not contain %q:\n%s", want, log)
+		}
+	}
+}
+
+func TestServerSupervisorRestartsAfterExit(t *testing.T) {
+	s, serverLog, statuses := newTestSupervisor(t, freeServerURL(t), "exit")
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	backoff := waitStatus(t, statuses, models.ServerStateBackoff)
+	if backoff.Restarts != 1 || backoff.ExitCode != 3 || backoff.PID != 0 || backoff.LastError == "" {
+		t.Errorf("backoff status = %+v", backoff)
+	}
+	if lines := serverLog.Lines(0); !containsLine(lines, models.ServerLogStderr, "crashed") {
+		t.Errorf("stderr was not recorded: %+v", lines)
+	}
+
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	waitStatus(t, statuses, models.ServerStateStopped)
+}
+
+func TestServerSupervisorKillsUnreadyServer(t *testing.T) {
+	s, _, statuses := newTestSupervisor(t, freeServerURL(t), "hang")
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	backoff := waitStatus(t, statuses, models.ServerStateBackoff)
+	if !strings.Contains(backoff.LastError, "not ready within 1s") {
+		t.Errorf("backoff status = %+v", backoff)
+	}
+}
+
+func TestServerSupervisorExternal(t *testing.T) {
+	listening := httptest.NewServer(http.NotFoundHandler())
+	defer listening.Close()
+
+	cases := []struct {
+		name      string
+		serverURL string
+		config    models.ServerProcess
+		wantErr   bool
+	}{
+		{"disabled", "http://127.0.0.1:1", models.ServerProcess{Disabled: true}, false},
+		{"remote host", "http://example.com:4096", models.ServerProcess{}, false},
+		{"already listening", listening.URL, models.ServerProcess{}, false},
+		{"invalid URL", "http://", models.ServerProcess{}, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var reported []models.ServerStatus
+			s := NewServerSupervisor(testLogger(), NewServerLog(nil), func(status models.ServerStatus) {
+				reported = append(reported, status)
+			})
+			s.Configure(c.serverURL, c.config)
+			if err := s.Start(); (err != nil) != c.wantErr {
+				t.Fatalf("Start = %v, want error %v", err, c.wantErr)
+			}
+			if len(reported) != 1 || reported[0].State != models.ServerStateExternal || reported[0].Managed {
+				t.Fatalf("reported statuses = %+v, want one external status", reported)
+			}
+			if (reported[0].LastError != "") != c.wantErr {
+				t.Errorf("status = %+v", reported[0])
+			}
+			if s.Status() != reported[0] {
+				t.Errorf("Status() = %+v, want %+v", s.Status(), reported[0])
+			}
+		})
+	}
+}
+
+func TestServerListenAddress(t *testing.T) {
+	cases := []struct {
+		serverURL string
+		host      string
+		port      string
+		wantErr   bool
+	}{
+		{"http://127.0.0.1:4096", "127.0.0.1", "4096", false},
+		{"http://localhost", "localhost", "80", false},
+		{"https://localhost/", "localhost", "443", false},
+		{"http://[::1]:4096", "::1", "4096", false},
+		{"http://", "", "", true},
+		{"://bad", "", "", true},
+	}
+	for _, c := range cases {
+		host, port, err := serverListenAddress(c.serverURL)
+		if (err != nil) != c.wantErr || host != c.host || port != c.port {
+			t.Errorf("serverListenAddress(%q) = %q, %q, %v", c.serverURL, host, port, err)
+		}
+	}
+}
+
+func TestIsLocalHost(t *testing.T) {
+	for host, want := range map[string]bool{
+		"localhost":   true,
+		"127.0.0.1":   true,
+		"::1":         true,
+		"0.0.0.0":     true,
+		"192.168.1.2": false,
+		"example.com": false,
+	} {
+		if got := isLocalHost(host); got != want {
+			t.Errorf("isLocalHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func containsLine(lines []models.ServerLogLine, stream, text string) bool {
+	for _, line := range lines {
+		if line.Stream == stream && line.Text == text {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"fm-opencode-tinyapp/internal/models"
+)
+
+// TranslationService stores original and translated message texts locally
+// so that the UI can switch between them. Each session is kept in its own
+// file so that saving an entry rewrites only that session.
+type TranslationService struct {
+	storeDir   string
+	legacyPath string // translations.json of earlier versions, split on first use
+
+	mu       sync.Mutex
+	migrated bool
+	entries  map[string][]models.TranslationEntry // sessionID -> entries, once loaded
+}
+
+// NewTranslationService creates a new TranslationService storing its data in
+// the translations directory in the application directory.
+func NewTranslationService() (*TranslationService, error) {
+	appDir, err := AppDataDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewTranslationServiceAt(appDir), nil
+}
+
+// NewTranslationServiceAt creates a TranslationService storing its data in
+// appDir.
+func NewTranslationServiceAt(appDir string) *TranslationService {
+	return &TranslationService{
+		storeDir:   filepath.Join(appDir, "translations"),
+		legacyPath: filepath.Join(appDir, "translations.json"),
+		entries:    make(map[string][]models.TranslationEntry),
+	}
+}
+
+// Save adds entries, replacing existing entries for the same part and
+// direction. Each affected session is written once.
+func (s *TranslationService) Save(entries ...models.TranslationEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := make(map[string]bool)
+	for _, entry := range entries {
+		sessionEntries, err := s.load(entry.SessionID)
+		if err != nil {
+			return err
+		}
+		replaced := false
+		if entry.PartID != "" {
+			for i, existing := range sessionEntries {
+				if existing.MessageID == entry.MessageID && existing.PartID == entry.PartID && existing.Direction == entry.Direction {
+					sessionEntries[i] = entry
+					replaced = true
+					break
+				}
+			}
+		}
+		if !replaced {
+			sessionEntries = append(sessionEntries, entry)
+		}
+		s.entries[entry.SessionID] = sessionEntries
+		changed[entry.SessionID] = true
+	}
+	for sessionID := range changed {
+		if err := s.save(sessionID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List returns the entries of a session.
+func (s *TranslationService) List(sessionID string) ([]models.TranslationEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := s.load(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return append([]models.TranslationEntry{}, entries...), nil
+}
+
+func (s *TranslationService) sessionPath(sessionID string) (string, error) {
+	if sessionID == "" || filepath.Base(sessionID) != sessionID || sessionID == "." || sessionID == ".." {
+		return "", fmt.Errorf("invalid session ID %q", sessionID)
+	}
+	return filepath.Join(s.storeDir, sessionID+".json"), nil
+}
+
+// load returns the entries of a session, reading them on first use. Must be
+// called with the lock held.
+func (s *TranslationService) load(sessionID string) ([]models.TranslationEntry, error) {
+	if err := s.migrate(); err != nil {
+		return nil, err
+	}
+	if entries, ok := s.entries[sessionID]; ok {
+		return entries, nil
+	}
+	path, err := s.sessionPath(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	var entries []models.TranslationEntry
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read translations: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("failed to parse translations: %w", err)
+		}
+	}
+	s.entries[sessionID] = entries
+	return entries, nil
+}
+
+// save writes the entries of a session. Must be called with the lock held.
+func (s *TranslationService) save(sessionID string) error {
+	path, err := s.sessionPath(sessionID)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s.entries[sessionID], "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal translations: %w", err)
+	}
+	if err := os.MkdirAll(s.storeDir, 0750); err != nil {
+		return fmt.Errorf("failed to create translations directory: %w", err)
+	}
+	if err := writeFileAtomic(path, data, 0640); err != nil {
+		return fmt.Errorf("failed to write translations: %w", err)
+	}
+	return nil
+}
+
+// migrate splits the single translations.json of earlier versions into
+// per-session files. Must be called with the lock held.
+func (s *TranslationService) migrate() error {
+	if s.migrated {
+		return nil
+	}
+	data, err := os.ReadFile(s.legacyPath)
+	if os.IsNotExist(err) {
+		s.migrated = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read translations: %w", err)
+	}
+	var legacy map[string][]models.TranslationEntry
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return fmt.Errorf("failed to parse translations: %w", err)
+	}
+	for sessionID, entries := range legacy {
+		s.entries[sessionID] = entries
+		if err := s.save(sessionID); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(s.legacyPath); err != nil {
+		return fmt.Errorf("failed to remove migrated translations: %w", err)
+	}
+	s.migrated = true
+	return nil
+}
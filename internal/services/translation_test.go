@@ -0,0 +1,120 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fm-opencode-tinyapp/internal/models"
+)
+
+func translationEntry(sessionID, messageID, partID, direction, translated string) models.TranslationEntry {
+	return models.TranslationEntry{
+		SessionID:  sessionID,
+		MessageID:  messageID,
+		PartID:     partID,
+		Direction:  direction,
+		Language:   "English",
+		Original:   "原文",
+		Translated: translated,
+		Time:       1,
+	}
+}
+
+func TestTranslationStoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	s := NewTranslationServiceAt(dir)
+	err := s.Save(
+		translationEntry("ses_1", "msg_1", "", models.TranslationOutgoing, "first"),
+		translationEntry("ses_1", "msg_2", "prt_1", models.TranslationIncoming, "reply"),
+		translationEntry("ses_2", "msg_3", "", models.TranslationOutgoing, "other session"),
+	)
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	// The same part and direction replaces the earlier entry.
+	if err := s.Save(translationEntry("ses_1", "msg_2", "prt_1", models.TranslationIncoming, "reply again")); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	reopened := NewTranslationServiceAt(dir)
+	entries, err := reopened.List("ses_1")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("entries = %+v, want 2", entries)
+	}
+	if entries[0].MessageID != "msg_1" || entries[0].Translated != "first" {
+		t.Errorf("entry 0 = %+v", entries[0])
+	}
+	if entries[1].MessageID != "msg_2" || entries[1].Translated != "reply again" {
+		t.Errorf("entry 1 = %+v", entries[1])
+	}
+	if entries, _ := reopened.List("ses_2"); len(entries) != 1 || entries[0].MessageID != "msg_3" {
+		t.Errorf("ses_2 entries = %+v", entries)
+	}
+	if entries, err := reopened.List("ses_3"); err != nil || len(entries) != 0 {
+		t.Errorf("List of a session without translations = %+v, %v", entries, err)
+	}
+}
+
+func TestTranslationSaveWritesOnlyItsSession(t *testing.T) {
+	dir := t.TempDir()
+	s := NewTranslationServiceAt(dir)
+	if err := s.Save(translationEntry("ses_2", "msg_1", "", models.TranslationOutgoing, "kept")); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	other := filepath.Join(dir, "translations", "ses_2.json")
+	// Corrupt the other session's file; saving ses_1 must not read or rewrite it.
+	if err := os.WriteFile(other, []byte("not json"), 0640); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	s = NewTranslationServiceAt(dir)
+	if err := s.Save(translationEntry("ses_1", "msg_2", "", models.TranslationOutgoing, "new")); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if data, _ := os.ReadFile(other); string(data) != "not json" {
+		t.Error("saving one session rewrote another")
+	}
+	if _, err := s.List("ses_2"); err == nil {
+		t.Error("List accepted a corrupt session file")
+	}
+}
+
+func TestTranslationMigratesLegacyStore(t *testing.T) {
+	dir := t.TempDir()
+	legacy := map[string][]models.TranslationEntry{
+		"ses_1": {translationEntry("ses_1", "msg_1", "", models.TranslationOutgoing, "old")},
+		"ses_2": {translationEntry("ses_2", "msg_2", "prt_1", models.TranslationIncoming, "old reply")},
+	}
+	data, _ := json.Marshal(legacy)
+	if err := os.WriteFile(filepath.Join(dir, "translations.json"), data, 0640); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	s := NewTranslationServiceAt(dir)
+	entries, err := s.List("ses_2")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Translated != "old reply" {
+		t.Errorf("entries = %+v", entries)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "translations.json")); !os.IsNotExist(err) {
+		t.Error("legacy store was not removed")
+	}
+	if entries, _ := NewTranslationServiceAt(dir).List("ses_1"); len(entries) != 1 || entries[0].MessageID != "msg_1" {
+		t.Errorf("migrated ses_1 entries = %+v", entries)
+	}
+}
+
+func TestTranslationRejectsInvalidSessionID(t *testing.T) {
+	s := NewTranslationServiceAt(t.TempDir())
+	for _, sessionID := range []string{"", "..", "../ses_1", "a/b"} {
+		if err := s.Save(translationEntry(sessionID, "msg_1", "", models.TranslationOutgoing, "x")); err == nil {
+			t.Errorf("Save accepted session ID %q", sessionID)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"fm-opencode-tinyapp/internal/api"
+	"fm-opencode-tinyapp/internal/models"
+)
+
+// UsageService aggregates cost and token usage of assistant messages.
+type UsageService struct {
+	apiClient *api.Client
+}
+
+// NewUsageService creates a new UsageService.
+func NewUsageService(apiClient *api.Client) *UsageService {
+	return &UsageService{apiClient: apiClient}
+}
+
+// GetUsageReport aggregates usage of all sessions in rng, grouped by groupBy
+// ("session", "model", "provider" or "day"). Days use the local time zone.
+func (s *UsageService) GetUsageReport(ctx context.Context, rng *models.UsageRange, groupBy string) (*models.UsageReport, error) {
+	if rng == nil {
+		rng = &models.UsageRange{}
+	}
+	if groupBy == "" {
+		groupBy = models.UsageGroupBySession
+	}
+	switch groupBy {
+	case models.UsageGroupBySession, models.UsageGroupByModel, models.UsageGroupByProvider, models.UsageGroupByDay:
+	default:
+		return nil, fmt.Errorf("unsupported usage grouping: %s", groupBy)
+	}
+
+	sessions, err := s.apiClient.GetSessions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	report := &models.UsageReport{Range: *rng, GroupBy: groupBy, Rows: []models.UsageRow{}}
+	rows := make(map[string]*models.UsageRow)
+
+	for _, session := range sessions {
+		// Sessions not updated since the start of the range cannot contain matching messages.
+		if rng.From > 0 && session.Time.Updated > 0 && session.Time.Updated < rng.From {
+			continue
+		}
+		messages, err := s.apiClient.GetMessages(ctx, session.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get messages for session %s: %w", session.ID, err)
+		}
+
+		for _, msg := range messages {
+			assistantMsg, ok := msg.Info.(models.AssistantMessage)
+			if !ok || !rng.Contains(assistantMsg.Time.Created) {
+				continue
+			}
+
+			key, label := usageGroupKey(groupBy, session, assistantMsg)
+			row, ok := rows[key]
+			if !ok {
+				row = &models.UsageRow{Key: key, Label: label}
+				rows[key] = row
+			}
+			row.Add(assistantMsg)
+			report.Total.Add(assistantMsg)
+		}
+	}
+
+	for _, row := range rows {
+		report.Rows = append(report.Rows, *row)
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		if groupBy == models.UsageGroupByDay {
+			return report.Rows[i].Key < report.Rows[j].Key
+		}
+		if report.Rows[i].Cost != report.Rows[j].Cost {
+			return report.Rows[i].Cost > report.Rows[j].Cost
+		}
+		return report.Rows[i].Key < report.Rows[j].Key
+	})
+
+	return report, nil
+}
+
+func usageGroupKey(groupBy string, session models.Session, msg models.AssistantMessage) (string, string) {
+	switch groupBy {
+	case models.UsageGroupByModel:
+		key := msg.ProviderID + "/" + msg.ModelID
+		return key, key
+	case models.UsageGroupByProvider:
+		return msg.ProviderID, msg.ProviderID
+	case models.UsageGroupByDay:
+		day := time.UnixMilli(msg.Time.Created).Format("2006-01-02")
+		return day, day
+	default:
+		label := session.Title
+		if label == "" {
+			label = session.ID
+		}
+		return session.ID, label
+	}
+}
+
+// ExportUsageCSV writes the usage report as CSV to path, with a trailing total row.
+func (s *UsageService) ExportUsageCSV(ctx context.Context, rng *models.UsageRange, groupBy string, path string) error {
+	if path == "" {
+		return fmt.Errorf("export path is empty")
+	}
+	report, err := s.GetUsageReport(ctx, rng, groupBy)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to create csv file: %w", err)
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	header := []string{"key", "label", "messages", "cost", "input", "output", "reasoning", "cache_read", "cache_write"}
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("failed to write csv: %w", err)
+	}
+	for _, row := range report.Rows {
+		if err := w.Write(usageCSVRecord(row.Key, row.Label, row.UsageTotals)); err != nil {
+			return fmt.Errorf("failed to write csv: %w", err)
+		}
+	}
+	if err := w.Write(usageCSVRecord("total", "", report.Total)); err != nil {
+		return fmt.Errorf("failed to write csv: %w", err)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write csv: %w", err)
+	}
+	return nil
+}
+
+func usageCSVRecord(key, label string, totals models.UsageTotals) []string {
+	return []string{
+		key,
+		label,
+		strconv.Itoa(totals.Messages),
+		strconv.FormatFloat(totals.Cost, 'f', 6, 64),
+		strconv.Itoa(totals.Input),
+		strconv.Itoa(totals.Output),
+		strconv.Itoa(totals.Reasoning),
+		strconv.Itoa(totals.CacheRead),
+		strconv.Itoa(totals.CacheWrite),
+	}
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fm-opencode-tinyapp/internal/api"
+	"fm-opencode-tinyapp/internal/models"
+)
+
+// Timestamps of the usage fixtures, at noon local time.
+var (
+	usageDay1 = time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local).UnixMilli()
+	usageDay2 = time.Date(2024, 3, 2, 12, 0, 0, 0, time.Local).UnixMilli()
+)
+
+func usageMessageJSON(id, provider, model string, created int64, cost float64, input, output int) string {
+	return fmt.Sprintf(`{"info": {"id": %q, "role": "assistant", "providerID": %q, "modelID": %q, "cost": %g,
+		"tokens": {"input": %d, "output": %d, "reasoning": 1, "cache": {"read": 2, "write": 3}},
+		"time": {"created": %d, "completed": %d}}, "parts": []}`,
+		id, provider, model, cost, input, output, created, created)
+}
+
+func newTestUsageService(t *testing.T) (*UsageService, func(sessionID string) int) {
+	t.Helper()
+	sessions := fmt.Sprintf(`[
+		{"id": "ses_1", "title": "First", "time": {"created": %d, "updated": %d}},
+		{"id": "ses_2", "title": "", "time": {"created": %d, "updated": %d}},
+		{"id": "ses_old", "title": "Old", "time": {"created": 1000, "updated": 1000}}
+	]`, usageDay1, usageDay2, usageDay2, usageDay2)
+	messages := map[string]string{
+		"ses_1": `[
+			{"info": {"id": "msg_1", "role": "user", "time": {"created": ` + fmt.Sprint(usageDay1) + `}}, "parts": []},
+			` + usageMessageJSON("msg_2", "anthropic", "claude", usageDay1, 0.5, 100, 10) + `,
+			` + usageMessageJSON("msg_3", "openai", "gpt", usageDay2, 0.25, 50, 5) + `
+		]`,
+		"ses_2":   `[` + usageMessageJSON("msg_4", "anthropic", "claude", usageDay2, 1, 200, 20) + `]`,
+		"ses_old": `[` + usageMessageJSON("msg_5", "anthropic", "claude", 1000, 10, 1, 1) + `]`,
+	}
+	var mu sync.Mutex
+	loads := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/session" {
+			w.Write([]byte(sessions))
+			return
+		}
+		sessionID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/session/"), "/message")
+		if body, ok := messages[sessionID]; ok {
+			mu.Lock()
+			loads[sessionID]++
+			mu.Unlock()
+			w.Write([]byte(body))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return NewUsageService(api.NewClient(server.URL)), func(sessionID string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return loads[sessionID]
+	}
+}
+
+func TestGetUsageReportGrouping(t *testing.T) {
+	s, _ := newTestUsageService(t)
+	day := func(ms int64) string { return time.UnixMilli(ms).Format("2006-01-02") }
+	cases := []struct {
+		groupBy string
+		want    []string // key:label:messages:cost in report order
+	}{
+		{"", []string{"ses_old:Old:1:10", "ses_2:ses_2:1:1", "ses_1:First:2:0.75"}},
+		{models.UsageGroupByModel, []string{"anthropic/claude:anthropic/claude:3:11.5", "openai/gpt:openai/gpt:1:0.25"}},
+		{models.UsageGroupByProvider, []string{"anthropic:anthropic:3:11.5", "openai:openai:1:0.25"}},
+		{models.UsageGroupByDay, []string{day(1000) + ":" + day(1000) + ":1:10", "2024-03-01:2024-03-01:1:0.5", "2024-03-02:2024-03-02:2:1.25"}},
+	}
+	for _, c := range cases {
+		t.Run(c.groupBy, func(t *testing.T) {
+			report, err := s.GetUsageReport(context.Background(), nil, c.groupBy)
+			if err != nil {
+				t.Fatalf("GetUsageReport: %v", err)
+			}
+			var got []string
+			for _, row := range report.Rows {
+				got = append(got, fmt.Sprintf("%s:%s:%d:%g", row.Key, row.Label, row.Messages, row.Cost))
+			}
+			if strings.Join(got, " ") != strings.Join(c.want, " ") {
+				t.Errorf("rows = %v, want %v", got, c.want)
+			}
+			want := models.UsageTotals{Messages: 4, Cost: 11.75, Input: 351, Output: 36, Reasoning: 4, CacheRead: 8, CacheWrite: 12}
+			if report.Total != want {
+				t.Errorf("total = %+v, want %+v", report.Total, want)
+			}
+		})
+	}
+}
+
+func TestGetUsageReportRange(t *testing.T) {
+	s, loads := newTestUsageService(t)
+	rng := &models.UsageRange{From: usageDay1, To: usageDay2}
+	report, err := s.GetUsageReport(context.Background(), rng, models.UsageGroupBySession)
+	if err != nil {
+		t.Fatalf("GetUsageReport: %v", err)
+	}
+	if len(report.Rows) != 1 || report.Rows[0].Key != "ses_1" || report.Rows[0].Messages != 1 {
+		t.Errorf("rows = %+v, want only msg_2 of ses_1", report.Rows)
+	}
+	if report.Total.Cost != 0.5 || report.Range != *rng {
+		t.Errorf("report = %+v", report)
+	}
+	if loads("ses_old") != 0 {
+		t.Error("messages of a session not updated in the range were loaded")
+	}
+}
+
+func TestGetUsageReportUnsupportedGrouping(t *testing.T) {
+	s, _ := newTestUsageService(t)
+	if _, err := s.GetUsageReport(context.Background(), nil, "week"); err == nil {
+		t.Error("GetUsageReport accepted an unsupported grouping")
+	}
+}
+
+func TestExportUsageCSV(t *testing.T) {
+	s, _ := newTestUsageService(t)
+	path := filepath.Join(t.TempDir(), "usage.csv")
+	if err := s.ExportUsageCSV(context.Background(), nil, models.UsageGroupByProvider, path); err != nil {
+		t.Fatalf("ExportUsageCSV: %v", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	want := [][]string{
+		{"key", "label", "messages", "cost", "input", "output", "reasoning", "cache_read", "cache_write"},
+		{"anthropic", "anthropic", "3", "11.500000", "301", "31", "3", "6", "9"},
+		{"openai", "openai", "1", "0.250000", "50", "5", "1", "2", "3"},
+		{"total", "", "4", "11.750000", "351", "36", "4", "8", "12"},
+	}
+	if fmt.Sprint(records) != fmt.Sprint(want) {
+		t.Errorf("csv = %v, want %v", records, want)
+	}
+
+	if err := s.ExportUsageCSV(context.Background(), nil, "", ""); err == nil {
+		t.Error("ExportUsageCSV accepted an empty path")
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"net/http"
 	"path"
 	"reflect"
+	"fm-opencode-tinyapp/internal/models"
+	"fm-opencode-tinyapp/internal/services"
 	"os"
 	"os/signal"
 	"strings"
@@ -28,16 +30,38 @@ func main() {
 	serve := flag.Bool("serve", false, "serve the frontend over HTTP for remote browser access")
 	host := flag.String("host", "0.0.0.0", "host interface for --serve mode")
 	port := flag.Int("port", 34115, "port number for --serve mode")
+	configPath := flag.String("config", "", "path to config.json (default: $"+services.ConfigPathEnv+" or the user config directory)")
+	serverURL := flag.String("server-url", "", "opencode server URL, overrides config.json and FMOC_SERVER_URL")
+	llmBaseURL := flag.String("llm-base-url", "", "LLM API base URL, overrides config.json and FMOC_LLM_BASE_URL")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration and exit")
 	flag.Parse()
 
-	if *serve {
-		if err := runHTTPServer(*host, *port); err != nil {
-			log.Fatalf("serve mode failed: %v", err)
+	// Precedence: flags > FMOC_* environment variables > config.json
+	overrides := services.EnvConfigOverrides()
+	if *serverURL != "" {
+		overrides = append(overrides, models.ConfigOverride{Field: "serverURL", Value: *serverURL, Source: "--server-url"})
+	}
+	if *llmBaseURL != "" {
+		overrides = append(overrides, models.ConfigOverride{Field: "llm.baseURL", Value: *llmBaseURL, Source: "--llm-base-url"})
+	}
+
+	if *printConfig {
+		if err := printEffectiveConfig(*configPath, overrides); err != nil {
+			log.Fatalf("invalid configuration: %v", err)
 		}
 		return
 	}
 
 	app := NewApp()
+	app.configPath = *configPath
+	app.configOverrides = overrides
+
+	if *serve {
+		if err := runHTTPServer(app, *host, *port); err != nil {
+			log.Fatalf("serve mode failed: %v", err)
+		}
+		return
+	}
 
 	err := wails.Run(&options.App{
 		Title:  "fm-opencode-tinyapp",
@@ -48,6 +72,7 @@ func main() {
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup:        app.startupWails,
+		OnShutdown:       app.shutdown,
 		Bind: []interface{}{
 			app,
 		},
@@ -58,8 +83,31 @@ func main() {
 	}
 }
 
-func runHTTPServer(host string, port int) error {
-	app := NewApp()
+// printEffectiveConfig writes the merged configuration, with secrets masked,
+// as JSON to stdout. Nothing is written to disk.
+func printEffectiveConfig(configPath string, overrides []models.ConfigOverride) error {
+	configService, err := services.OpenAppConfigServiceReadOnly(configPath, overrides)
+	if err != nil {
+		return err
+	}
+	config, err := configService.GetAppConfig()
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(map[string]any{
+		"path":      configService.Path(),
+		"overrides": configService.Overrides(),
+		"config":    config.Masked(),
+	}); err != nil {
+		return err
+	}
+	return services.ValidateAppConfig(config)
+}
+
+func runHTTPServer(app *App, host string, port int) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	app.startup(ctx)
@@ -109,6 +157,7 @@ func runHTTPServer(host string, port int) error {
 		}
 	}
 	cancel()
+	app.shutdown(context.Background())
 	return server.Shutdown(context.Background())
 }
 